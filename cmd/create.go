@@ -25,6 +25,7 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/funktionio/funktion/pkg/funktion"
+	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
@@ -49,6 +50,16 @@ type createFunctionCmd struct {
 
 	envVars []string
 
+	replicas         int
+	cpuRequest       string
+	cpuLimit         string
+	memoryRequest    string
+	memoryLimit      string
+	nodeSelector     []string
+	tolerations      []string
+	affinityFile     string
+	imagePullSecrets []string
+
 	configMaps map[string]*v1.ConfigMap
 }
 
@@ -131,6 +142,15 @@ func newCreateFunctionCmd() *cobra.Command {
 	f.StringVarP(&p.name, "name", "n", "", "the name of the function to create")
 	f.StringVarP(&p.source, "source", "s", "", "the source code of the function to create")
 	f.StringVarP(&p.runtime, "runtime", "r", "nodejs", "the runtime to use. e.g. 'nodejs'")
+	f.IntVar(&p.replicas, "replicas", -1, "the number of pods running the function. Defaults to the replicas of the runtime")
+	f.StringVar(&p.cpuRequest, "cpu-request", "", "the CPU request of the function such as 250m")
+	f.StringVar(&p.cpuLimit, "cpu-limit", "", "the CPU limit of the function")
+	f.StringVar(&p.memoryRequest, "memory-request", "", "the memory request of the function such as 256Mi")
+	f.StringVar(&p.memoryLimit, "memory-limit", "", "the memory limit of the function")
+	f.StringArrayVar(&p.nodeSelector, "node-selector", []string{}, "the labels of the nodes to run the function on using the form KEY=VALUE")
+	f.StringArrayVar(&p.tolerations, "toleration", []string{}, "tolerate a taint of the nodes using the form KEY=VALUE:EFFECT or KEY:EFFECT")
+	f.StringVar(&p.affinityFile, "affinity-file", "", "the YAML file containing the affinity of the function")
+	f.StringArrayVar(&p.imagePullSecrets, "image-pull-secret", []string{}, "the name of a Secret used to pull the image of the runtime")
 	p.setupCommonFlags(f)
 	return cmd
 }
@@ -141,19 +161,11 @@ func (p *createFunctionCmd) createFromFile() error {
 	if len(file) == 0 {
 		return fmt.Errorf("No file argument specified!")
 	}
-	var matches []string
 	if isExistingDir(file) {
-		files, err := ioutil.ReadDir(file)
-		if err != nil {
-			return err
-		}
-		matches = []string{}
-		for _, fi := range files {
-			if !fi.IsDir() {
-				matches = append(matches, filepath.Join(file, fi.Name()))
-			}
-		}
+		// a folder is a single function project
+		err = p.applyFolder(file)
 	} else {
+		var matches []string
 		matches, err = filepath.Glob(file)
 		if err != nil {
 			return fmt.Errorf("Could not parse pattern %s due to %v", file, err)
@@ -162,11 +174,11 @@ func (p *createFunctionCmd) createFromFile() error {
 			fmt.Println("Please specify a file name that exists or specify the directory containing functions")
 			return fmt.Errorf("No suitable source file: %s", file)
 		}
-	}
-	for _, file := range matches {
-		err = p.applyFile(file)
-		if err != nil {
-			return err
+		for _, file := range matches {
+			err = p.applyFile(file)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	if err == nil && p.watch {
@@ -176,7 +188,7 @@ func (p *createFunctionCmd) createFromFile() error {
 }
 
 func (p *createFunctionCmd) setupCommonFlags(f *pflag.FlagSet) {
-	f.StringArrayVarP(&p.envVars, "env", "e", []string{}, "pass one or more environment variables using the form NAME=VALUE")
+	f.StringArrayVarP(&p.envVars, "env", "e", []string{}, "pass one or more environment variables using the form NAME=VALUE. Use NAME=secret:SECRET/KEY or NAME=configmap:CONFIGMAP/KEY to take the value from a key of a Secret or ConfigMap")
 	f.StringVar(&p.kubeConfigPath, "kubeconfig", "", "the directory to look for the kubernetes configuration")
 	f.StringVar(&p.namespace, "namespace", "", "the namespace to create the resource")
 	f.BoolVarP(&p.watch, "watch", "w", false, "whether to keep watching the files for changes to the function source code")
@@ -193,15 +205,18 @@ func (p *createFunctionCmd) createFunctionFromCLI() error {
 	if len(file) > 0 {
 		return p.createFromFile()
 	} else {
-		kubeclient := p.kubeclient
-		cms := kubeclient.ConfigMaps(p.namespace)
+		cms, err := createResourceClient(p.kubeclient, p.namespace, functionKind)
+		if err != nil {
+			return err
+		}
 		resources, err := cms.List(*listOpts)
 		if err != nil {
 			return err
 		}
 		p.configMaps = map[string]*v1.ConfigMap{}
-		for _, resource := range resources.Items {
-			p.configMaps[resource.Name] = &resource
+		for i := range resources.Items {
+			resource := &resources.Items[i]
+			p.configMaps[resource.Name] = resource
 		}
 
 		name := nameFromFile(file, p.name)
@@ -211,13 +226,14 @@ func (p *createFunctionCmd) createFunctionFromCLI() error {
 				return err
 			}
 		}
-		update := p.configMaps[name] != nil
+		old := p.configMaps[name]
 		cm, err := p.createFunction(name)
 		if err != nil {
 			return err
 		}
 		message := "created"
-		if update {
+		if old != nil {
+			preserveFunctionProperties(cm, old)
 			_, err = cms.Update(cm)
 			message = "updated"
 		} else {
@@ -257,12 +273,25 @@ func (p *createFunctionCmd) watchFiles() {
 	} else {
 		matches = []string{files}
 	}
+	folder := isExistingDir(files)
 	for _, file := range matches {
 		err = watcher.Add(file)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
+	if folder {
+		// lets watch the sub folders too as they are packaged into the function
+		err = walkFunctionFolder(files, func(path string, info os.FileInfo) error {
+			if info.IsDir() {
+				return watcher.Add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	for {
 		select {
@@ -277,7 +306,11 @@ func (p *createFunctionCmd) watchFiles() {
 					}
 				}
 			}
-			err = p.applyFile(event.Name)
+			if folder {
+				err = p.applyFolder(files)
+			} else {
+				err = p.applyFile(event.Name)
+			}
 			if err != nil {
 				fmt.Printf("Failed to apply function file %s due to %v\n", event.Name, err)
 			}
@@ -325,28 +358,10 @@ func (p *createFunctionCmd) applyFile(fileName string) error {
 	if len(runtime) == 0 {
 		return nil
 	}
-	listOpts, err := funktion.CreateFunctionListOptions()
-	if err != nil {
-		return err
-	}
 	name := nameFromFile(fileName, "")
 	if len(name) == 0 {
 		return fmt.Errorf("Could not generate a function name!")
 	}
-
-	kubeclient := p.kubeclient
-	cms := kubeclient.ConfigMaps(p.namespace)
-	resources, err := cms.List(*listOpts)
-	if err != nil {
-		return err
-	}
-	var old *v1.ConfigMap = nil
-	for _, resource := range resources.Items {
-		if resource.Name == name {
-			old = &resource
-			break
-		}
-	}
 	defaultLabels := map[string]string{}
 	abs, err := filepath.Abs(fileName)
 	if err == nil && len(abs) > 0 {
@@ -359,24 +374,165 @@ func (p *createFunctionCmd) applyFile(fileName string) error {
 	if err != nil {
 		return err
 	}
+	return p.applyFunction(cm)
+}
+
+// applyFolder packages the files of a project folder into a single function. The main source file is
+// the only file in the folder handled by a runtime or the one called `index`. Any flows in the folder
+// are applied separately
+func (p *createFunctionCmd) applyFolder(folder string) error {
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{}
+	sources := map[string]string{}
+	err = walkFunctionFolder(folder, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasSuffix(rel, flowExtension) {
+			if p.functionsOnly {
+				return nil
+			}
+			return p.applyFile(path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(data)
+		if !strings.Contains(rel, "/") {
+			runtime, err := p.findRuntimeFromFileName(rel)
+			if err != nil {
+				return err
+			}
+			if len(runtime) > 0 {
+				sources[rel] = runtime
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	main := ""
+	if len(sources) == 1 {
+		for file := range sources {
+			main = file
+		}
+	} else {
+		for file := range sources {
+			if nameFromFile(file, "") == "index" {
+				main = file
+			}
+		}
+		if len(main) == 0 {
+			return fmt.Errorf("Folder %s has several source files so please call the main one `index`", folder)
+		}
+	}
+	name := nameFromFile(filepath.Base(abs), p.name)
+	if len(name) == 0 {
+		return fmt.Errorf("Could not generate a function name!")
+	}
+	defaultLabels := map[string]string{}
+	if folderName := convertToSafeLabelValue(filepath.Base(abs)); len(folderName) > 0 {
+		defaultLabels[funktion.ProjectLabel] = folderName
+	}
+	cm, err := p.createFunctionFromSource(name, files[main], sources[main], defaultLabels)
+	if err != nil {
+		return err
+	}
+	delete(files, main)
+	funktion.SetFunctionFiles(cm.Data, files)
+	return p.applyFunction(cm)
+}
+
+// walkFunctionFolder walks the files of a function folder skipping hidden files and installed dependencies
+func walkFunctionFolder(folder string, fn func(path string, info os.FileInfo) error) error {
+	return filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != folder && (strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+// applyFunction creates the given function or updates it if any of the properties set by this command have changed
+func (p *createFunctionCmd) applyFunction(cm *v1.ConfigMap) error {
+	listOpts, err := funktion.CreateFunctionListOptions()
+	if err != nil {
+		return err
+	}
+	cms, err := createResourceClient(p.kubeclient, p.namespace, functionKind)
+	if err != nil {
+		return err
+	}
+	resources, err := cms.List(*listOpts)
+	if err != nil {
+		return err
+	}
+	var old *v1.ConfigMap = nil
+	for i := range resources.Items {
+		if resources.Items[i].Name == cm.Name {
+			old = &resources.Items[i]
+			break
+		}
+	}
 	message := "created"
 	if old != nil {
-		oldSource := old.Data[funktion.SourceProperty]
-		if source == oldSource && cm.Data[funktion.EnvVarsProperty] == old.Data[funktion.EnvVarsProperty] {
-			// source not changed so lets not update!
+		if !functionChanged(cm, old) {
+			// nothing changed so lets not update!
 			return nil
 		}
+		preserveFunctionProperties(cm, old)
 		_, err = cms.Update(cm)
 		message = "updated"
 	} else {
 		_, err = cms.Create(cm)
 	}
 	if err == nil {
-		log.Println("Function", name, message)
+		log.Println("Function", cm.Name, message)
 	}
 	return err
 }
 
+// functionChanged returns true if any of the properties set by this command, such as the source, files,
+// environment variables, pod overrides or runtime, differ from the old function. The source, files, environment
+// variables and debug flag are removed when not set whereas the other properties of the old function are kept
+func functionChanged(cm *v1.ConfigMap, old *v1.ConfigMap) bool {
+	for k, v := range cm.Labels {
+		if old.Labels[k] != v {
+			return true
+		}
+	}
+	for k, v := range cm.Data {
+		if old.Data[k] != v {
+			return true
+		}
+	}
+	for k := range old.Data {
+		if _, ok := cm.Data[k]; !ok && (k == funktion.EnvVarsProperty || k == funktion.DebugProperty || funktion.IsFileProperty(k)) {
+			return true
+		}
+	}
+	return false
+}
+
 // findRuntimeFromFileName returns the runtime to use for the given file name
 // or an empty string if the file does not map to a runtime function source file
 func (p *createFunctionCmd) findRuntimeFromFileName(fileName string) (string, error) {
@@ -385,8 +541,10 @@ func (p *createFunctionCmd) findRuntimeFromFileName(fileName string) (string, er
 	if err != nil {
 		return "", err
 	}
-	kubeclient := p.kubeclient
-	cms := kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(p.kubeclient, p.namespace, runtimeKind)
+	if err != nil {
+		return "", err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return "", err
@@ -484,6 +642,9 @@ func (p *createFunctionCmd) createFunctionFromSource(name, source, runtime strin
 	if len(p.envVars) > 0 {
 		data[funktion.EnvVarsProperty] = strings.Join(p.envVars, "\n")
 	}
+	if err := p.setPodOverrides(data); err != nil {
+		return nil, err
+	}
 	cm := &v1.ConfigMap{
 		ObjectMeta: v1.ObjectMeta{
 			Name:   name,
@@ -494,6 +655,104 @@ func (p *createFunctionCmd) createFunctionFromSource(name, source, runtime strin
 	return cm, nil
 }
 
+// setPodOverrides sets the properties of the Function which override the pod template of its runtime
+func (p *createFunctionCmd) setPodOverrides(data map[string]string) error {
+	if p.replicas >= 0 {
+		data[funktion.ReplicasProperty] = strconv.Itoa(p.replicas)
+	}
+	for key, value := range map[string]string{
+		funktion.CPURequestProperty:    p.cpuRequest,
+		funktion.CPULimitProperty:      p.cpuLimit,
+		funktion.MemoryRequestProperty: p.memoryRequest,
+		funktion.MemoryLimitProperty:   p.memoryLimit,
+	} {
+		if len(value) > 0 {
+			data[key] = value
+		}
+	}
+	if len(p.nodeSelector) > 0 {
+		data[funktion.NodeSelectorProperty] = strings.Join(p.nodeSelector, "\n")
+	}
+	if len(p.imagePullSecrets) > 0 {
+		data[funktion.ImagePullSecretsProperty] = strings.Join(p.imagePullSecrets, ",")
+	}
+	if len(p.tolerations) > 0 {
+		tolerations := []v1.Toleration{}
+		for _, text := range p.tolerations {
+			toleration, err := parseToleration(text)
+			if err != nil {
+				return err
+			}
+			tolerations = append(tolerations, toleration)
+		}
+		b, err := yaml.Marshal(tolerations)
+		if err != nil {
+			return err
+		}
+		data[funktion.TolerationsProperty] = string(b)
+	}
+	if len(p.affinityFile) > 0 {
+		b, err := ioutil.ReadFile(p.affinityFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load affinity file %s: %v", p.affinityFile, err)
+		}
+		data[funktion.AffinityProperty] = string(b)
+	}
+	return nil
+}
+
+// parseToleration parses a toleration of the form KEY=VALUE:EFFECT or KEY:EFFECT
+func parseToleration(text string) (v1.Toleration, error) {
+	idx := strings.LastIndex(text, ":")
+	if idx <= 0 {
+		return v1.Toleration{}, fmt.Errorf("Invalid toleration `%s`. Expecting KEY=VALUE:EFFECT or KEY:EFFECT", text)
+	}
+	toleration := v1.Toleration{
+		Effect: v1.TaintEffect(text[idx+1:]),
+	}
+	pair := strings.SplitN(text[:idx], "=", 2)
+	toleration.Key = pair[0]
+	if len(pair) == 2 {
+		toleration.Operator = v1.TolerationOpEqual
+		toleration.Value = pair[1]
+	} else {
+		toleration.Operator = v1.TolerationOpExists
+	}
+	return toleration, nil
+}
+
+// preserveFunctionProperties keeps the metadata of the old Function, such as its resourceVersion, finalizers,
+// status annotations and any labels or annotations added by users, replacing only the labels set by this
+// command. The properties of the old Function which are not set by this command are copied across too
+func preserveFunctionProperties(cm *v1.ConfigMap, old *v1.ConfigMap) {
+	objectMeta := old.ObjectMeta
+	objectMeta.Labels = map[string]string{}
+	for k, v := range old.Labels {
+		objectMeta.Labels[k] = v
+	}
+	for k, v := range cm.Labels {
+		objectMeta.Labels[k] = v
+	}
+	objectMeta.Annotations = map[string]string{}
+	for k, v := range old.Annotations {
+		objectMeta.Annotations[k] = v
+	}
+	cm.ObjectMeta = objectMeta
+
+	for k, v := range old.Data {
+		switch k {
+		case funktion.SourceProperty, funktion.EnvVarsProperty, funktion.DebugProperty:
+			continue
+		}
+		if funktion.IsFileProperty(k) {
+			continue
+		}
+		if _, ok := cm.Data[k]; !ok {
+			cm.Data[k] = v
+		}
+	}
+}
+
 func loadFileSource(fileName string) (string, error) {
 	data, err := ioutil.ReadFile(fileName)
 	if err != nil {
@@ -508,7 +767,11 @@ func (p *createFunctionCmd) checkRuntimeExists(name string) error {
 	if err != nil {
 		return err
 	}
-	cms, err := p.kubeclient.ConfigMaps(p.namespace).List(*listOpts)
+	runtimes, err := createResourceClient(p.kubeclient, p.namespace, runtimeKind)
+	if err != nil {
+		return err
+	}
+	cms, err := runtimes.List(*listOpts)
 	if err != nil {
 		return err
 	}
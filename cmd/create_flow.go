@@ -177,8 +177,12 @@ func (p *createCmdCommon) applyFlowWithConnector(name, funktionYml, connectorNam
 		},
 		Data: data,
 	}
+	flows, err := createResourceClient(p.kubeclient, p.namespace, flowKind)
+	if err != nil {
+		return err
+	}
 	update := false
-	old, err := p.kubeclient.ConfigMaps(p.namespace).Get(name)
+	old, err := flows.Get(name)
 	if err == nil {
 		update = true
 	}
@@ -192,10 +196,10 @@ func (p *createCmdCommon) applyFlowWithConnector(name, funktionYml, connectorNam
 			// source not changed so lets not update!
 			return nil
 		}
-		_, err = p.kubeclient.ConfigMaps(p.namespace).Update(&cm)
+		_, err = flows.Update(&cm)
 		action = "updated"
 	} else {
-		_, err = p.kubeclient.ConfigMaps(p.namespace).Create(&cm)
+		_, err = flows.Create(&cm)
 	}
 
 	if err == nil {
@@ -269,7 +273,10 @@ func (p *createCmdCommon) checkConnectorExists(name string) (*v1.ConfigMap, erro
 	if err != nil {
 		return nil, err
 	}
-	cms := p.kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(p.kubeclient, p.namespace, connectorKind)
+	if err != nil {
+		return nil, err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,133 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/funktionio/funktion/pkg/funktion"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+func TestPreserveFunctionProperties(t *testing.T) {
+	old := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:            "hello",
+			ResourceVersion: "42",
+			Finalizers:      []string{funktion.CleanupFinalizer},
+			Labels: map[string]string{
+				funktion.KindLabel:    funktion.FunctionKind,
+				funktion.RuntimeLabel: "nodejs",
+				"team":                "payments",
+			},
+			Annotations: map[string]string{
+				funktion.StatusPhaseAnnotation: funktion.PhaseReady,
+			},
+		},
+		Data: map[string]string{
+			funktion.SourceProperty:      "old",
+			funktion.IdleTimeoutProperty: "10m",
+		},
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "hello",
+			Labels: map[string]string{
+				funktion.KindLabel:    funktion.FunctionKind,
+				funktion.RuntimeLabel: "python",
+			},
+		},
+		Data: map[string]string{
+			funktion.SourceProperty: "new",
+		},
+	}
+	preserveFunctionProperties(cm, old)
+
+	if cm.ResourceVersion != "42" || len(cm.Finalizers) != 1 {
+		t.Errorf("expected the resourceVersion and finalizers to be kept but got %s and %v", cm.ResourceVersion, cm.Finalizers)
+	}
+	if cm.Labels["team"] != "payments" || cm.Labels[funktion.RuntimeLabel] != "python" {
+		t.Errorf("expected the user labels to be kept and the runtime to be replaced but got %v", cm.Labels)
+	}
+	if cm.Annotations[funktion.StatusPhaseAnnotation] != funktion.PhaseReady {
+		t.Errorf("expected the status annotations to be kept but got %v", cm.Annotations)
+	}
+	if cm.Data[funktion.SourceProperty] != "new" || cm.Data[funktion.IdleTimeoutProperty] != "10m" {
+		t.Errorf("expected the new source and the old idle timeout but got %v", cm.Data)
+	}
+	if old.Labels[funktion.RuntimeLabel] != "nodejs" {
+		t.Errorf("expected the old Function not to be modified")
+	}
+}
+
+func TestFunctionChanged(t *testing.T) {
+	old := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "hello",
+			Labels: map[string]string{
+				funktion.KindLabel:    funktion.FunctionKind,
+				funktion.RuntimeLabel: "nodejs",
+			},
+		},
+		Data: map[string]string{
+			funktion.SourceProperty:      "same",
+			funktion.ReplicasProperty:    "1",
+			funktion.IdleTimeoutProperty: "10m",
+		},
+	}
+	newFunction := func(data map[string]string) *v1.ConfigMap {
+		cm := &v1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "hello",
+				Labels: map[string]string{
+					funktion.KindLabel:    funktion.FunctionKind,
+					funktion.RuntimeLabel: "nodejs",
+				},
+			},
+			Data: map[string]string{funktion.SourceProperty: "same"},
+		}
+		for k, v := range data {
+			cm.Data[k] = v
+		}
+		return cm
+	}
+
+	if functionChanged(newFunction(nil), old) {
+		t.Errorf("expected the same source without any flags not to change the function")
+	}
+	if functionChanged(newFunction(map[string]string{funktion.ReplicasProperty: "1"}), old) {
+		t.Errorf("expected the same replicas not to change the function")
+	}
+	if !functionChanged(newFunction(map[string]string{funktion.ReplicasProperty: "3"}), old) {
+		t.Errorf("expected new replicas to change the function")
+	}
+	if !functionChanged(newFunction(map[string]string{funktion.MemoryLimitProperty: "512Mi"}), old) {
+		t.Errorf("expected a memory limit to change the function")
+	}
+	if !functionChanged(newFunction(map[string]string{funktion.SourceProperty: "changed"}), old) {
+		t.Errorf("expected new source to change the function")
+	}
+	cm := newFunction(nil)
+	cm.Labels[funktion.RuntimeLabel] = "python"
+	if !functionChanged(cm, old) {
+		t.Errorf("expected a new runtime to change the function")
+	}
+
+	old.Data[funktion.EnvVarsProperty] = "FOO=bar"
+	if !functionChanged(newFunction(nil), old) {
+		t.Errorf("expected removing the environment variables to change the function")
+	}
+}
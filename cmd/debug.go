@@ -149,7 +149,10 @@ func (p *debugCmd) createPortText(kindText, name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	cms := p.kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(p.kubeclient, p.namespace, kind)
+	if err != nil {
+		return "", err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return "", err
@@ -17,11 +17,10 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/funktionio/funktion/pkg/funktion"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/1.5/kubernetes"
-	"k8s.io/client-go/1.5/pkg/api"
 	"k8s.io/client-go/1.5/pkg/api/v1"
-	"strings"
 )
 
 type deleteCmd struct {
@@ -33,6 +32,8 @@ type deleteCmd struct {
 	namespace string
 	name      string
 	all       bool
+
+	resources *funktion.ResourceClient
 }
 
 func init() {
@@ -75,8 +76,11 @@ func (p *deleteCmd) run() error {
 	if err != nil {
 		return err
 	}
-	cms := p.kubeclient.ConfigMaps(p.namespace)
-	resources, err := cms.List(*listOpts)
+	p.resources, err = createResourceClient(p.kubeclient, p.namespace, kind)
+	if err != nil {
+		return err
+	}
+	resources, err := p.resources.List(*listOpts)
 	if err != nil {
 		return err
 	}
@@ -110,13 +114,9 @@ func (p *deleteCmd) run() error {
 }
 
 func (p *deleteCmd) deleteResource(cm *v1.ConfigMap) error {
-	kind := strings.ToLower(cm.Kind)
+	kind := p.kind
 	name := cm.Name
-	ns := cm.Namespace
-	if len(ns) == 0 {
-		ns = p.namespace
-	}
-	err := p.kubeclient.ConfigMaps(ns).Delete(name, &api.DeleteOptions{})
+	err := p.resources.Delete(name)
 	if err != nil {
 		return fmt.Errorf("Failed to delete %s \"%s\" due to: %v", kind, name, err)
 	}
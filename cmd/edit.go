@@ -114,8 +114,10 @@ func (p *editConnectorCmd) run() error {
 	if err != nil {
 		return err
 	}
-	kubeclient := p.kubeclient
-	cms := kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(p.kubeclient, p.namespace, connectorKind)
+	if err != nil {
+		return err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return err
@@ -268,7 +270,10 @@ func (p *editConnectorCmd) editConnector(name string, connector *v1.ConfigMap) e
 		p.applicationProperties.Write(w, properties.UTF8)
 		w.Flush()
 		propText := b.String()
-		cms := p.kubeclient.ConfigMaps(p.namespace)
+		cms, err := createResourceClient(p.kubeclient, p.namespace, connectorKind)
+		if err != nil {
+			return err
+		}
 		latestCon, err := cms.Get(name)
 		if err != nil {
 			return err
@@ -0,0 +1,208 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/funktionio/funktion/pkg/funktion"
+	"github.com/funktionio/funktion/pkg/k8sutil"
+	"github.com/spf13/cobra"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/types"
+)
+
+type eventsCmd struct {
+	kubeclient     *kubernetes.Clientset
+	cmd            *cobra.Command
+	kubeConfigPath string
+
+	namespace string
+	kind      string
+	name      string
+}
+
+func init() {
+	RootCmd.AddCommand(newEventsCmd())
+}
+
+func newEventsCmd() *cobra.Command {
+	p := &eventsCmd{}
+	cmd := &cobra.Command{
+		Use:   "events KIND NAME [flags]",
+		Short: "lists the events of the given function or flow",
+		Long:  `This command will list the events the operator has posted while creating, updating or deleting the function or flow`,
+		Run: func(cmd *cobra.Command, args []string) {
+			p.cmd = cmd
+			if len(args) < 1 {
+				handleError(fmt.Errorf("No resource kind argument supplied! Possible values ['fn', 'flow']"))
+				return
+			}
+			p.kind = args[0]
+			kind, _, err := listOptsForKind(p.kind)
+			if err != nil {
+				handleError(err)
+				return
+			}
+			if len(args) < 2 {
+				handleError(fmt.Errorf("No %s name specified!", kind))
+				return
+			}
+			p.name = args[1]
+			err = createKubernetesClient(cmd, p.kubeConfigPath, &p.kubeclient, &p.namespace)
+			if err != nil {
+				handleError(err)
+				return
+			}
+			handleError(p.run())
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&p.kubeConfigPath, "kubeconfig", "", "the directory to look for the kubernetes configuration")
+	f.StringVarP(&p.namespace, "namespace", "n", "", "the namespace to query")
+	return cmd
+}
+
+func (p *eventsCmd) run() error {
+	kind, _, err := listOptsForKind(p.kind)
+	if err != nil {
+		return err
+	}
+	// the events are posted on the ConfigMap or on the custom resource
+	kinds := map[string]bool{
+		"ConfigMap": true,
+	}
+	ownerKind := funktion.FunctionKind
+	if kind == flowKind {
+		ownerKind = funktion.FlowKind
+	}
+	kinds[ownerKind] = true
+	uids, err := p.involvedUIDs(kind, ownerKind)
+	if err != nil {
+		return err
+	}
+	events, err := p.kubeclient.Events(p.namespace).List(api.ListOptions{})
+	if err != nil {
+		return err
+	}
+	items := matchEvents(events.Items, uids, p.name, kinds)
+	if len(items) == 0 {
+		fmt.Printf("No events found for %s \"%s\"\n", p.kind, p.name)
+		return nil
+	}
+	sort.Sort(eventsByLastTimestamp(items))
+	printEventRow("LASTSEEN", "FIRSTSEEN", "COUNT", "TYPE", "REASON", "MESSAGE")
+	for _, event := range items {
+		printEventRow(ageText(event.LastTimestamp.Time), ageText(event.FirstTimestamp.Time), fmt.Sprintf("%d", event.Count), event.Type, event.Reason, event.Message)
+	}
+	return nil
+}
+
+// involvedUIDs returns the UIDs of the function or flow and of the Deployments and pods the operator created
+// for it or no UIDs if the resource no longer exists
+func (p *eventsCmd) involvedUIDs(kind string, ownerKind string) (map[types.UID]bool, error) {
+	uids := map[types.UID]bool{}
+	resources, err := createResourceClient(p.kubeclient, p.namespace, kind)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := resources.Get(p.name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return uids, nil
+		}
+		return nil, err
+	}
+	uids[resource.UID] = true
+	listOpts, err := funktion.CreateManagedListOptions()
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := p.kubeclient.Deployments(p.namespace).List(*listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		if funktion.OwnerName(deployment.ObjectMeta, ownerKind) != p.name || deployment.Spec.Selector == nil {
+			continue
+		}
+		uids[deployment.UID] = true
+		podOpts, err := k8sutil.V1BetaSelectorToListOptions(deployment.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		pods, err := p.kubeclient.Pods(p.namespace).List(*podOpts)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			uids[pod.UID] = true
+		}
+	}
+	return uids, nil
+}
+
+// matchEvents returns the events involving one of the given UIDs. The events posted for a resource after it was
+// deleted carry no UID so they are matched by the name and kind of the resource instead
+func matchEvents(events []v1.Event, uids map[types.UID]bool, name string, kinds map[string]bool) []v1.Event {
+	items := []v1.Event{}
+	for _, event := range events {
+		involved := event.InvolvedObject
+		if len(involved.UID) > 0 {
+			if uids[involved.UID] {
+				items = append(items, event)
+			}
+		} else if involved.Name == name && kinds[involved.Kind] {
+			items = append(items, event)
+		}
+	}
+	return items
+}
+
+func printEventRow(lastSeen, firstSeen, count, eventType, reason, message string) {
+	fmt.Printf("%-9s %-9s %-5s %-7s %-12s %s\n", lastSeen, firstSeen, count, eventType, reason, message)
+}
+
+// ageText returns a short human readable duration since the given time
+func ageText(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+type eventsByLastTimestamp []v1.Event
+
+func (e eventsByLastTimestamp) Len() int      { return len(e) }
+func (e eventsByLastTimestamp) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e eventsByLastTimestamp) Less(i, j int) bool {
+	return e[i].LastTimestamp.Time.Before(e[j].LastTimestamp.Time)
+}
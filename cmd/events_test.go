@@ -0,0 +1,67 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/funktionio/funktion/pkg/funktion"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/types"
+)
+
+func involvedEvent(reason string, kind string, name string, uid types.UID) v1.Event {
+	return v1.Event{
+		Reason: reason,
+		InvolvedObject: v1.ObjectReference{
+			Kind: kind,
+			Name: name,
+			UID:  uid,
+		},
+	}
+}
+
+func TestMatchEvents(t *testing.T) {
+	events := []v1.Event{
+		involvedEvent("Created", funktion.FunctionKind, "foo", "function-uid"),
+		involvedEvent("Scaled", "Deployment", "foo-1", "deployment-uid"),
+		involvedEvent("Pulled", "Pod", "foo-1-abcde", "pod-uid"),
+		involvedEvent("Deleted", "ConfigMap", "foo", ""),
+		involvedEvent("Created", "ConfigMap", "foo", "configmap-uid"),
+		involvedEvent("Created", funktion.FlowKind, "foo", "flow-uid"),
+		involvedEvent("Deleted", funktion.FlowKind, "bar", ""),
+	}
+	uids := map[types.UID]bool{
+		"function-uid":   true,
+		"deployment-uid": true,
+		"pod-uid":        true,
+	}
+	kinds := map[string]bool{
+		"ConfigMap":           true,
+		funktion.FunctionKind: true,
+	}
+	items := matchEvents(events, uids, "foo", kinds)
+
+	expected := []string{"Created", "Scaled", "Pulled", "Deleted"}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d events but got %d: %v", len(expected), len(items), items)
+	}
+	for i, reason := range expected {
+		if items[i].Reason != reason {
+			t.Errorf("expected event %d to be %s but got %s of %s %s", i, reason, items[i].Reason, items[i].InvolvedObject.Kind, items[i].InvolvedObject.Name)
+		}
+	}
+}
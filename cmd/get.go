@@ -17,6 +17,7 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
@@ -24,6 +25,7 @@ import (
 	"k8s.io/client-go/1.5/kubernetes"
 	"k8s.io/client-go/1.5/pkg/api"
 	"k8s.io/client-go/1.5/pkg/api/v1"
+	autoscaling "k8s.io/client-go/1.5/pkg/apis/autoscaling/v1"
 	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
 
 	"github.com/funktionio/funktion/pkg/funktion"
@@ -40,7 +42,9 @@ type getCmd struct {
 	name      string
 
 	deployments map[string]*v1beta1.Deployment
+	revisions   map[string][]*v1beta1.Deployment
 	services    map[string]*v1.Service
+	autoscalers map[string]*autoscaling.HorizontalPodAutoscaler
 }
 
 func init() {
@@ -84,7 +88,10 @@ func (p *getCmd) run() error {
 		return err
 	}
 	kubeclient := p.kubeclient
-	cms := kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(kubeclient, p.namespace, kind)
+	if err != nil {
+		return err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return err
@@ -95,23 +102,45 @@ func (p *getCmd) run() error {
 	if err != nil {
 		return err
 	}
+	resourceKind := funktion.FlowKind
+	if kind == functionKind {
+		resourceKind = funktion.FunctionKind
+	}
 	p.deployments = map[string]*v1beta1.Deployment{}
-	for _, item := range ds.Items {
-		// TODO lets assume the name of the Deployment is the name of the Flow
-		// but we may want to use a label instead to link them?
-		name := item.Name
-		p.deployments[name] = &item
+	p.revisions = map[string][]*v1beta1.Deployment{}
+	for i := range ds.Items {
+		item := &ds.Items[i]
+		name := funktion.OwnerName(item.ObjectMeta, resourceKind)
+		if len(name) == 0 {
+			continue
+		}
+		if funktion.RevisionNumber(item.ObjectMeta) > 0 {
+			p.revisions[name] = append(p.revisions[name], item)
+		} else {
+			p.deployments[name] = item
+		}
 	}
 	if kind == functionKind {
 		ss, err := kubeclient.Services(p.namespace).List(api.ListOptions{})
 		if err != nil {
 			return err
 		}
-		for _, item := range ss.Items {
-			// TODO lets assume the name of the Service is the name of the Function
-			// but we may want to use a label instead to link them?
-			name := item.Name
-			p.services[name] = &item
+		for i := range ss.Items {
+			item := &ss.Items[i]
+			if name := funktion.OwnerName(item.ObjectMeta, resourceKind); len(name) > 0 {
+				p.services[name] = item
+			}
+		}
+		hpas, err := kubeclient.Autoscaling().HorizontalPodAutoscalers(p.namespace).List(api.ListOptions{})
+		if err != nil {
+			return err
+		}
+		p.autoscalers = map[string]*autoscaling.HorizontalPodAutoscaler{}
+		for i := range hpas.Items {
+			item := &hpas.Items[i]
+			if name := funktion.OwnerName(item.ObjectMeta, resourceKind); len(name) > 0 {
+				p.autoscalers[name] = item
+			}
 		}
 	}
 	name := p.name
@@ -127,6 +156,12 @@ func (p *getCmd) run() error {
 			if resource.Name == name {
 				p.printHeader(kind)
 				p.printResource(&resource, kind)
+				if status := funktion.GetStatus(&resource); len(status.Message) > 0 {
+					fmt.Printf("\nMessage: %s\n", status.Message)
+				}
+				if kind == functionKind {
+					printEnvVars(&resource)
+				}
 				found = true
 				break
 			}
@@ -141,9 +176,9 @@ func (p *getCmd) run() error {
 func (p *getCmd) printHeader(kind string) {
 	switch kind {
 	case flowKind:
-		printFlowRow("NAME", "PODS", "STEPS")
+		printFlowRow("NAME", "STATUS", "PODS", "STEPS")
 	case functionKind:
-		printFunctionRow("NAME", "PODS", "URL")
+		printFunctionRow("NAME", "STATUS", "PODS", "REPLICAS", "TRAFFIC", "URL")
 	default:
 		printRuntimeRow("NAME", "VERSION")
 	}
@@ -152,34 +187,120 @@ func (p *getCmd) printHeader(kind string) {
 func (p *getCmd) printResource(cm *v1.ConfigMap, kind string) {
 	switch kind {
 	case functionKind:
-		printFunctionRow(cm.Name, p.podText(cm), p.functionURLText(cm))
+		printFunctionRow(cm.Name, statusText(cm), p.podText(cm), p.replicasText(cm), trafficText(cm), p.functionURLText(cm))
 	case flowKind:
-		printFlowRow(cm.Name, p.podText(cm), p.flowStepsText(cm))
+		printFlowRow(cm.Name, statusText(cm), p.podText(cm), p.flowStepsText(cm))
 	default:
 		printRuntimeRow(cm.Name, p.runtimeVersion(cm))
 	}
 }
 
-func printFunctionRow(name string, pod string, flow string) {
-	fmt.Printf("%-32s %-9s %s\n", name, pod, flow)
+func printFunctionRow(name string, status string, pod string, replicas string, traffic string, url string) {
+	fmt.Printf("%-32s %-9s %-9s %-14s %-14s %s\n", name, status, pod, replicas, traffic, url)
 }
 
-func printFlowRow(name string, pod string, flow string) {
-	fmt.Printf("%-32s %-9s %s\n", name, pod, flow)
+func printFlowRow(name string, status string, pod string, flow string) {
+	fmt.Printf("%-32s %-9s %-9s %s\n", name, status, pod, flow)
 }
 
 func printRuntimeRow(name string, version string) {
 	fmt.Printf("%-32s %s\n", name, version)
 }
 
+// deploymentsFor returns the Deployments of the given Function or Flow. A Function has a Deployment
+// for each of its revisions which receive traffic
+func (p *getCmd) deploymentsFor(cm *v1.ConfigMap) []*v1beta1.Deployment {
+	if revisions := p.revisions[cm.Name]; len(revisions) > 0 {
+		return revisions
+	}
+	if deployment := p.deployments[cm.Name]; deployment != nil {
+		return []*v1beta1.Deployment{deployment}
+	}
+	return nil
+}
+
 func (p *getCmd) podText(cm *v1.ConfigMap) string {
+	deployments := p.deploymentsFor(cm)
+	if len(deployments) == 0 {
+		return ""
+	}
+	var available, replicas int32
+	for _, deployment := range deployments {
+		available += deployment.Status.AvailableReplicas
+		replicas += deployment.Status.Replicas
+	}
+	return fmt.Sprintf("%d/%d", available, replicas)
+}
+
+// replicasText returns the current and desired replicas of the Function along with
+// the replica range of its HorizontalPodAutoscaler if it is autoscaled
+func (p *getCmd) replicasText(cm *v1.ConfigMap) string {
 	name := cm.Name
-	deployment := p.deployments[name]
-	if deployment == nil {
+	hpa := p.autoscalers[name]
+	if hpa != nil {
+		minReplicas := int32(1)
+		if hpa.Spec.MinReplicas != nil {
+			minReplicas = *hpa.Spec.MinReplicas
+		}
+		return fmt.Sprintf("%d/%d (%d-%d)", hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas, minReplicas, hpa.Spec.MaxReplicas)
+	}
+	deployments := p.deploymentsFor(cm)
+	if len(deployments) == 0 {
 		return ""
 	}
-	var status = deployment.Status
-	return fmt.Sprintf("%d/%d", status.AvailableReplicas, status.Replicas)
+	var current, desired int32
+	for _, deployment := range deployments {
+		current += deployment.Status.Replicas
+		if deployment.Spec.Replicas != nil {
+			desired += *deployment.Spec.Replicas
+		} else {
+			desired++
+		}
+	}
+	return fmt.Sprintf("%d/%d", current, desired)
+}
+
+// trafficText returns the split of the requests of the Function between its revisions
+func trafficText(cm *v1.ConfigMap) string {
+	traffic := cm.Data[funktion.TrafficProperty]
+	if len(traffic) == 0 {
+		return "latest"
+	}
+	return traffic
+}
+
+// printEnvVars prints the environment variables of the Function. Values which come from a Secret
+// are never printed, only the Secret and key they come from
+func printEnvVars(cm *v1.ConfigMap) {
+	lines := []string{}
+	for i, line := range strings.Split(cm.Data[funktion.EnvVarsProperty], "\n") {
+		l := strings.TrimSpace(line)
+		if len(l) == 0 {
+			continue
+		}
+		envVar, err := funktion.ParseEnvVar(l)
+		if err != nil {
+			// lets not print the line in case it holds a value which should have been a Secret
+			lines = append(lines, fmt.Sprintf("invalid environment variable on line %d", i+1))
+			continue
+		}
+		lines = append(lines, funktion.DescribeEnvVar(envVar))
+	}
+	if len(lines) > 0 {
+		fmt.Printf("\nEnvironment:\n")
+		for _, line := range lines {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// statusText returns the phase the operator last wrote onto the Function or Flow
+func statusText(cm *v1.ConfigMap) string {
+	status := funktion.GetStatus(cm)
+	if len(status.Phase) == 0 {
+		return funktion.PhasePending
+	}
+	return status.Phase
 }
 
 func (p *getCmd) functionURLText(cm *v1.ConfigMap) string {
@@ -245,7 +245,10 @@ func (p *installConnectorCmd) installConnectors(uri string, version string) erro
 	if err != nil {
 		return err
 	}
-	cms := p.kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(p.kubeclient, p.namespace, connectorKind)
+	if err != nil {
+		return err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return err
@@ -342,7 +345,10 @@ func (p *installRuntimeCmd) installRuntimes(uri string, version string) error {
 	if err != nil {
 		return err
 	}
-	cms := p.kubeclient.ConfigMaps(p.namespace)
+	cms, err := createResourceClient(p.kubeclient, p.namespace, runtimeKind)
+	if err != nil {
+		return err
+	}
 	resources, err := cms.List(*listOpts)
 	if err != nil {
 		return err
@@ -0,0 +1,189 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/funktionio/funktion/pkg/client"
+	"github.com/funktionio/funktion/pkg/funktion"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api"
+)
+
+// migrationOrder is the order in which resources are migrated so that the Runtimes and Connectors
+// exist as custom resources before the Functions and Flows using them. Rollbacks use the reverse order
+var migrationOrder = []string{runtimeKind, connectorKind, functionKind, flowKind}
+
+type migrateCmd struct {
+	kubeclient     *kubernetes.Clientset
+	tclient        *client.Client
+	cmd            *cobra.Command
+	kubeConfigPath string
+
+	namespace     string
+	allNamespaces bool
+	dryRun        bool
+	rollback      bool
+}
+
+func init() {
+	RootCmd.AddCommand(newMigrateCmd())
+}
+
+func newMigrateCmd() *cobra.Command {
+	p := &migrateCmd{}
+	cmd := &cobra.Command{
+		Use:   "migrate [flags]",
+		Short: "migrates the label based ConfigMaps to custom resources",
+		Long: `This command converts the Functions, Flows, Runtimes and Connectors stored as labelled ConfigMaps into custom resources keeping their labels and data.
+
+The operator adopts the existing Deployments and Services without recreating them as long as it runs with --custom-resources. Use --dry-run to see what would be migrated and --rollback to convert the custom resources back into ConfigMaps`,
+		Run: func(cmd *cobra.Command, args []string) {
+			p.cmd = cmd
+			err := createKubernetesClient(cmd, p.kubeConfigPath, &p.kubeclient, &p.namespace)
+			if err != nil {
+				handleError(err)
+				return
+			}
+			if p.allNamespaces {
+				p.namespace = api.NamespaceAll
+			}
+			handleError(p.run())
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&p.kubeConfigPath, "kubeconfig", "", "the directory to look for the kubernetes configuration")
+	f.StringVarP(&p.namespace, "namespace", "n", "", "the namespace to migrate")
+	f.BoolVar(&p.allNamespaces, "all-namespaces", false, "whether to migrate the resources in all namespaces")
+	f.BoolVar(&p.dryRun, "dry-run", false, "only report the resources which would be migrated")
+	f.BoolVar(&p.rollback, "rollback", false, "convert the custom resources back into label based ConfigMaps")
+	return cmd
+}
+
+func (p *migrateCmd) run() error {
+	p.tclient = client.NewForClientset(p.kubeclient)
+	registered, err := p.tclient.Registered()
+	if err != nil {
+		return err
+	}
+	if p.rollback {
+		if !registered {
+			fmt.Println("The custom resources are not registered so there is nothing to roll back")
+			return nil
+		}
+		return p.runRollback(p.kubeclient)
+	}
+	if !registered {
+		if p.dryRun {
+			fmt.Println("The custom resources are not registered yet and would be registered")
+		} else {
+			fmt.Println("Registering the custom resources")
+			if err := client.CreateThirdPartyResources(p.kubeclient); err != nil {
+				return err
+			}
+		}
+	}
+	return p.runMigrate(p.kubeclient)
+}
+
+func (p *migrateCmd) runMigrate(kclient kubernetes.Interface) error {
+	count := 0
+	failed := 0
+	for _, kind := range migrationOrder {
+		_, listOpts, err := listOptsForKind(kind)
+		if err != nil {
+			return err
+		}
+		cms, err := kclient.Core().ConfigMaps(p.namespace).List(*listOpts)
+		if err != nil {
+			return err
+		}
+		for i := range cms.Items {
+			cm := &cms.Items[i]
+			if _, err := funktion.FromConfigMap(cm); err != nil {
+				fmt.Printf("Cannot migrate %s %s/%s: %v\n", kind, cm.Namespace, cm.Name, err)
+				failed++
+				continue
+			}
+			if p.dryRun {
+				fmt.Printf("Would migrate %s %s/%s\n", kind, cm.Namespace, cm.Name)
+				count++
+				continue
+			}
+			if err := funktion.MigrateConfigMap(kclient, p.tclient, cm); err != nil {
+				fmt.Printf("%v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("Migrated %s %s/%s\n", kind, cm.Namespace, cm.Name)
+			count++
+		}
+	}
+	return p.report("migrate", "Migrated", count, failed)
+}
+
+func (p *migrateCmd) runRollback(kclient kubernetes.Interface) error {
+	count := 0
+	failed := 0
+	for i := len(migrationOrder) - 1; i >= 0; i-- {
+		kind := migrationOrder[i]
+		_, listOpts, err := listOptsForKind(kind)
+		if err != nil {
+			return err
+		}
+		resourceKind, err := resourceKindFor(kind)
+		if err != nil {
+			return err
+		}
+		list, err := funktion.NewResourceClient(kclient, p.tclient, p.namespace, resourceKind).List(*listOpts)
+		if err != nil {
+			return err
+		}
+		for j := range list.Items {
+			form := &list.Items[j]
+			if !funktion.IsCustomResource(form) {
+				continue
+			}
+			if p.dryRun {
+				fmt.Printf("Would roll back %s %s/%s\n", kind, form.Namespace, form.Name)
+				count++
+				continue
+			}
+			if err := funktion.RollbackCustomResource(kclient, p.tclient, form); err != nil {
+				fmt.Printf("%v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("Rolled back %s %s/%s\n", kind, form.Namespace, form.Name)
+			count++
+		}
+	}
+	return p.report("roll back", "Rolled back", count, failed)
+}
+
+func (p *migrateCmd) report(action string, done string, count int, failed int) error {
+	if p.dryRun {
+		fmt.Printf("Would %s %d resource(s)\n", action, count)
+	} else {
+		fmt.Printf("%s %d resource(s)\n", done, count)
+	}
+	if failed > 0 {
+		return fmt.Errorf("Could not %s %d resource(s)", action, failed)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	clientfake "github.com/funktionio/funktion/pkg/client/fake"
+	"github.com/funktionio/funktion/pkg/funktion"
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+func migrateTestConfigMaps() (*v1.ConfigMap, *v1.ConfigMap) {
+	runtime := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "default",
+			Labels: map[string]string{
+				funktion.KindLabel: funktion.RuntimeKind,
+			},
+		},
+		Data: map[string]string{
+			funktion.SourceMountPathProperty: "/funktion/source.js",
+		},
+	}
+	function := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "hello",
+			Namespace: "default",
+			Labels: map[string]string{
+				funktion.KindLabel:    funktion.FunctionKind,
+				funktion.RuntimeLabel: "nodejs",
+				"team":                "payments",
+			},
+		},
+		Data: map[string]string{
+			funktion.SourceProperty: "module.exports = function(context, callback) { callback(200, 'Hello'); };",
+		},
+	}
+	return runtime, function
+}
+
+// writeActions returns the verbs of the requests of the fake clientset which changed a resource
+func writeActions(kclient *fake.Clientset) []string {
+	verbs := []string{}
+	for _, action := range kclient.Actions() {
+		switch verb := action.GetVerb(); verb {
+		case "get", "list", "watch":
+		default:
+			verbs = append(verbs, verb+" "+action.GetResource().Resource)
+		}
+	}
+	return verbs
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	runtime, function := migrateTestConfigMaps()
+	kclient := fake.NewSimpleClientset(runtime, function)
+	server := clientfake.NewServer()
+	defer server.Close()
+	tclient := server.Client()
+	if _, err := tclient.Functions("default").Create(&spec.Function{
+		ObjectMeta: v1.ObjectMeta{Name: "greeter", Namespace: "default"},
+		Spec:       spec.FunctionSpec{Runtime: "nodejs"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	writes := len(server.Writes())
+
+	p := &migrateCmd{tclient: tclient, namespace: "default", dryRun: true}
+	if err := p.runMigrate(kclient); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.runRollback(kclient); err != nil {
+		t.Fatal(err)
+	}
+
+	if verbs := writeActions(kclient); len(verbs) > 0 {
+		t.Errorf("expected a dry run not to change any ConfigMaps but got %v", verbs)
+	}
+	if actual := server.Writes()[writes:]; len(actual) > 0 {
+		t.Errorf("expected a dry run not to change any custom resources but got %v", actual)
+	}
+	if _, err := tclient.Functions("default").Get("hello"); !errors.IsNotFound(err) {
+		t.Errorf("expected the Function hello not to be migrated but got %v", err)
+	}
+	if _, err := tclient.Functions("default").Get("greeter"); err != nil {
+		t.Errorf("expected the Function greeter not to be rolled back but got %v", err)
+	}
+}
+
+func TestMigrateAndRollback(t *testing.T) {
+	runtime, function := migrateTestConfigMaps()
+	kclient := fake.NewSimpleClientset(runtime, function)
+	server := clientfake.NewServer()
+	defer server.Close()
+	tclient := server.Client()
+
+	p := &migrateCmd{tclient: tclient, namespace: "default"}
+	if err := p.runMigrate(kclient); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tclient.Runtimes("default").Get("nodejs"); err != nil {
+		t.Errorf("expected the Runtime to be migrated but got %v", err)
+	}
+	if _, err := tclient.Functions("default").Get("hello"); err != nil {
+		t.Errorf("expected the Function to be migrated but got %v", err)
+	}
+
+	if err := p.runRollback(kclient); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []*v1.ConfigMap{runtime, function} {
+		cm, err := kclient.Core().ConfigMaps("default").Get(expected.Name)
+		if err != nil {
+			t.Errorf("expected the ConfigMap %s to be restored but got %v", expected.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cm.Labels, expected.Labels) {
+			t.Errorf("expected ConfigMap %s to have the labels %v but got %v", expected.Name, expected.Labels, cm.Labels)
+		}
+		for key, value := range expected.Data {
+			if cm.Data[key] != value {
+				t.Errorf("expected ConfigMap %s to have %s %s but got %s", expected.Name, key, value, cm.Data[key])
+			}
+		}
+	}
+	if list, err := tclient.Functions("default").List(api.ListOptions{}); err != nil || len(list.Items) > 0 {
+		t.Errorf("expected the Functions to be deleted but got %v %v", list, err)
+	}
+}
@@ -16,49 +16,95 @@ package cmd
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/funktionio/funktion/pkg/funktion"
+	"github.com/funktionio/funktion/pkg/k8sutil"
 
 	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
-	flag "github.com/spf13/pflag"
 	"k8s.io/client-go/1.5/tools/clientcmd"
 )
 
+type operateCmd struct {
+	cmd            *cobra.Command
+	kubeConfigPath string
+	overrides      clientcmd.ConfigOverrides
+	listenAddress  string
+
+	admissionListenAddress string
+	admissionTLSCertFile   string
+	admissionTLSKeyFile    string
+
+	activatorListenAddress string
+
+	watchNamespaces   []string
+	watchOwnNamespace bool
+	workers           int
+	customResources   bool
+
+	leaderElect             bool
+	leaderElectionNamespace string
+	leaderElectionName      string
+	leaseDuration           time.Duration
+	renewDeadline           time.Duration
+	retryPeriod             time.Duration
+}
+
 func init() {
-	RootCmd.AddCommand(versionCmd)
+	RootCmd.AddCommand(newOperateCmd())
 }
 
-var versionCmd = &cobra.Command{
-	Use:   "operate",
-	Short: "Runs the funktion operator",
-	Long:  `This command will startup the operator for funktion`,
-	RunE:  operateCommand,
+func newOperateCmd() *cobra.Command {
+	p := &operateCmd{}
+	cmd := &cobra.Command{
+		Use:   "operate",
+		Short: "Runs the funktion operator",
+		Long:  `This command will startup the operator for funktion`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p.cmd = cmd
+			return p.run()
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&p.kubeConfigPath, "kubeconfig", "", "Path to the config file to use for CLI requests.")
+	clientcmd.BindOverrideFlags(&p.overrides, f, clientcmd.RecommendedConfigOverrideFlags(""))
+	f.StringVar(&p.listenAddress, "listen-address", ":8080", "The address the HTTP server exposing /metrics, /healthz and /readyz listens on. An empty value disables it")
+	f.StringVar(&p.admissionListenAddress, "admission-listen-address", "", "The address the validating admission webhook for Functions and Flows listens on at /validate. An empty value disables it")
+	f.StringVar(&p.admissionTLSCertFile, "admission-tls-cert-file", "", "The TLS certificate of the admission webhook. The webhook uses plain HTTP if not specified")
+	f.StringVar(&p.admissionTLSKeyFile, "admission-tls-key-file", "", "The TLS private key of the admission webhook")
+	f.StringVar(&p.activatorListenAddress, "activator-listen-address", "", "The address the activator listens on which proxies requests at /functions/{namespace}/{name}/ to Functions and wakes up Functions scaled to zero when idle. An empty value disables it")
+	f.StringSliceVar(&p.watchNamespaces, "watch-namespaces", []string{}, "A comma separated list of namespaces to watch. Watches all namespaces if not specified")
+	f.BoolVar(&p.watchOwnNamespace, "watch-own-namespace", false, "Only watch the namespace the operator is running in")
+	f.IntVar(&p.workers, "workers", 1, "The number of functions and flows to reconcile concurrently")
+	f.BoolVar(&p.customResources, "custom-resources", false, "Whether to register and watch the Function, Flow, Runtime and Connector custom resources as well as the ConfigMaps. Requires permission to create ThirdPartyResources")
+	f.BoolVar(&p.leaderElect, "leader-elect", false, "Enable leader election so that only one replica of the operator reconciles resources at a time")
+	f.StringVar(&p.leaderElectionNamespace, "leader-elect-namespace", "", "The namespace of the ConfigMap used for leader election. Defaults to the namespace of the operator")
+	f.StringVar(&p.leaderElectionName, "leader-elect-name", "funktion-operator", "The name of the ConfigMap used for leader election")
+	f.DurationVar(&p.leaseDuration, "leader-elect-lease-duration", 15*time.Second, "How long followers wait after the last renewal before taking over the leader lease")
+	f.DurationVar(&p.renewDeadline, "leader-elect-renew-deadline", 10*time.Second, "How long the leader keeps retrying to renew its lease before giving up leadership")
+	f.DurationVar(&p.retryPeriod, "leader-elect-retry-period", 2*time.Second, "How long to wait between attempts to acquire or renew the leader lease")
+	return cmd
 }
 
-func operateCommand(cmd *cobra.Command, args []string) error {
+func (p *operateCmd) run() error {
 	fmt.Println("Funktion operator is starting")
 
 	logger := log.NewContext(log.NewLogfmtLogger(os.Stdout)).
 		With("ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller).
 		With("operator", "funktion")
 
-	flagset := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	flagset.StringVar(&loadingRules.ExplicitPath, "kubeconfig", "", "Path to the config file to use for CLI requests.")
+	loadingRules.ExplicitPath = p.kubeConfigPath
 
-	overrides := &clientcmd.ConfigOverrides{}
-	overrideFlags := clientcmd.RecommendedConfigOverrideFlags("")
-	clientcmd.BindOverrideFlags(overrides, flagset, overrideFlags)
-
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
-
-	flagset.Parse(os.Args[1:])
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &p.overrides)
 
 	cfg, err := kubeConfig.ClientConfig()
 	if err != nil {
@@ -66,16 +112,85 @@ func operateCommand(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	ko, err := funktion.New(cfg, logger)
+	opts := funktion.Options{
+		Namespaces:      p.watchNamespaces,
+		Workers:         p.workers,
+		CustomResources: p.customResources,
+		Activator:       len(p.activatorListenAddress) > 0,
+	}
+	if p.watchOwnNamespace {
+		ns, _, err := kubeConfig.Namespace()
+		if err != nil {
+			logger.Log("msg", "failed to find the operator namespace", "error", err)
+			return err
+		}
+		opts.Namespaces = append(opts.Namespaces, ns)
+	}
+	if p.leaderElect {
+		le, err := p.leaderElectionConfig(kubeConfig)
+		if err != nil {
+			logger.Log("msg", "failed to configure leader election", "error", err)
+			return err
+		}
+		opts.LeaderElection = le
+	}
+
+	ko, err := funktion.New(cfg, opts, logger)
 	if err != nil {
 		logger.Log("error", err)
 		return err
 	}
 
 	stopc := make(chan struct{})
-	errc := make(chan error)
+	// the servers and the operator may all fail so lets not block the ones which are not received
+	errc := make(chan error, 4)
 	var wg sync.WaitGroup
 
+	if len(p.listenAddress) > 0 {
+		if err := ko.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+			logger.Log("msg", "failed to register metrics", "error", err)
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		mux.Handle("/healthz", ko.HealthzHandler())
+		mux.Handle("/readyz", ko.ReadyzHandler())
+		go func() {
+			logger.Log("msg", "starting HTTP server", "address", p.listenAddress)
+			if err := http.ListenAndServe(p.listenAddress, mux); err != nil {
+				errc <- err
+			}
+		}()
+	}
+
+	if len(p.admissionListenAddress) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/validate", ko.AdmissionHandler())
+		go func() {
+			logger.Log("msg", "starting admission webhook", "address", p.admissionListenAddress)
+			var err error
+			if len(p.admissionTLSCertFile) > 0 {
+				err = http.ListenAndServeTLS(p.admissionListenAddress, p.admissionTLSCertFile, p.admissionTLSKeyFile, mux)
+			} else {
+				err = http.ListenAndServe(p.admissionListenAddress, mux)
+			}
+			if err != nil {
+				errc <- err
+			}
+		}()
+	}
+
+	if len(p.activatorListenAddress) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/functions/", http.StripPrefix("/functions", ko.ActivatorHandler()))
+		go func() {
+			logger.Log("msg", "starting activator", "address", p.activatorListenAddress)
+			if err := http.ListenAndServe(p.activatorListenAddress, mux); err != nil {
+				errc <- err
+			}
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		if err := ko.Run(stopc); err != nil {
@@ -100,3 +215,26 @@ func operateCommand(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+func (p *operateCmd) leaderElectionConfig(kubeConfig clientcmd.ClientConfig) (*k8sutil.LeaderElectionConfig, error) {
+	ns := p.leaderElectionNamespace
+	if len(ns) == 0 {
+		var err error
+		ns, _, err = kubeConfig.Namespace()
+		if err != nil {
+			return nil, fmt.Errorf("Could not deduce the leader election namespace due to: %v", err)
+		}
+	}
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("Could not find the hostname to use as the leader election identity: %v", err)
+	}
+	return &k8sutil.LeaderElectionConfig{
+		Namespace:     ns,
+		Name:          p.leaderElectionName,
+		Identity:      identity,
+		LeaseDuration: p.leaseDuration,
+		RenewDeadline: p.renewDeadline,
+		RetryPeriod:   p.retryPeriod,
+	}, nil
+}
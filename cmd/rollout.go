@@ -0,0 +1,178 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/funktionio/funktion/pkg/funktion"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const (
+	rolloutPromote = "promote"
+	rolloutAbort   = "abort"
+)
+
+type rolloutCmd struct {
+	kubeclient     *kubernetes.Clientset
+	cmd            *cobra.Command
+	kubeConfigPath string
+
+	namespace string
+	name      string
+	action    string
+	revision  int
+	percent   int
+}
+
+func init() {
+	RootCmd.AddCommand(newRolloutCmd())
+}
+
+func newRolloutCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "gradually rolls out a new revision of a function",
+		Long: `This command splits the requests of a function between its revisions so that a new revision can be tried out on a percentage of the requests before it is promoted or aborted.
+
+Every change to the source, environment variables or debug mode of a function creates a new revision. Once a function has a traffic split new revisions receive no requests until they are rolled out.
+
+Requests are split in proportion to the number of pods of each revision so the split is only as accurate as the number of replicas of the function allows.`,
+	}
+	cmd.AddCommand(newRolloutFunctionCmd())
+	cmd.AddCommand(newRolloutActionCmd(rolloutPromote, "sends all the requests of a function to the revision being rolled out"))
+	cmd.AddCommand(newRolloutActionCmd(rolloutAbort, "sends all the requests of a function back to the revision it is being rolled out from"))
+	return cmd
+}
+
+func newRolloutFunctionCmd() *cobra.Command {
+	p := &rolloutCmd{}
+	cmd := &cobra.Command{
+		Use:   "fn NAME --to-revision N [--percent P]",
+		Short: "sends a percentage of the requests of a function to one of its revisions",
+		Long:  `This command sends a percentage of the requests of a function to one of its revisions and the rest to the revision which currently receives the most requests`,
+		Run: func(cmd *cobra.Command, args []string) {
+			p.cmd = cmd
+			if len(args) == 0 {
+				handleError(fmt.Errorf("No function name argument supplied!"))
+				return
+			}
+			p.name = args[0]
+			if p.revision < 1 {
+				handleError(fmt.Errorf("No revision supplied! Please pass `--to-revision N`"))
+				return
+			}
+			err := createKubernetesClient(cmd, p.kubeConfigPath, &p.kubeclient, &p.namespace)
+			if err != nil {
+				handleError(err)
+				return
+			}
+			handleError(p.run())
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&p.kubeConfigPath, "kubeconfig", "", "the directory to look for the kubernetes configuration")
+	f.StringVarP(&p.namespace, "namespace", "n", "", "the namespace of the function")
+	f.IntVar(&p.revision, "to-revision", 0, "the revision of the function to roll out")
+	f.IntVar(&p.percent, "percent", 10, "the percentage of the requests to send to the revision")
+	return cmd
+}
+
+func newRolloutActionCmd(action string, description string) *cobra.Command {
+	p := &rolloutCmd{
+		action: action,
+	}
+	cmd := &cobra.Command{
+		Use:   action + " NAME",
+		Short: description,
+		Long:  fmt.Sprintf("This command %s", description),
+		Run: func(cmd *cobra.Command, args []string) {
+			p.cmd = cmd
+			if len(args) == 0 {
+				handleError(fmt.Errorf("No function name argument supplied!"))
+				return
+			}
+			p.name = args[0]
+			err := createKubernetesClient(cmd, p.kubeConfigPath, &p.kubeclient, &p.namespace)
+			if err != nil {
+				handleError(err)
+				return
+			}
+			handleError(p.run())
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&p.kubeConfigPath, "kubeconfig", "", "the directory to look for the kubernetes configuration")
+	f.StringVarP(&p.namespace, "namespace", "n", "", "the namespace of the function")
+	return cmd
+}
+
+func (p *rolloutCmd) run() error {
+	resources, err := createResourceClient(p.kubeclient, p.namespace, functionKind)
+	if err != nil {
+		return err
+	}
+	cm, err := resources.Get(p.name)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	current, err := funktion.ParseTraffic(cm.Data[funktion.TrafficProperty])
+	if err != nil {
+		return fmt.Errorf("Function %s has an invalid traffic split: %v", p.name, err)
+	}
+	var traffic []funktion.TrafficTarget
+	switch p.action {
+	case rolloutPromote:
+		traffic, err = funktion.PromoteTraffic(current)
+	case rolloutAbort:
+		traffic, err = funktion.AbortTraffic(current)
+	default:
+		var revisions []*v1.ConfigMap
+		revisions, err = funktion.ListRevisions(p.kubeclient, p.namespace, p.name)
+		if err != nil {
+			return err
+		}
+		numbers := []int{}
+		for _, revision := range revisions {
+			numbers = append(numbers, funktion.RevisionNumber(revision.ObjectMeta))
+		}
+		traffic, err = funktion.RolloutTraffic(current, numbers, p.revision, p.percent)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not update the traffic of Function %s: %v", p.name, err)
+	}
+	cm.Data[funktion.TrafficProperty] = funktion.FormatTraffic(traffic)
+	if _, err := resources.Update(cm); err != nil {
+		return err
+	}
+	fmt.Printf("Function %s now sends %s\n", p.name, describeTraffic(traffic))
+	return nil
+}
+
+// describeTraffic describes the traffic split of a function
+func describeTraffic(traffic []funktion.TrafficTarget) string {
+	items := []string{}
+	for _, t := range traffic {
+		items = append(items, fmt.Sprintf("%d%% to revision %d", t.Percent, t.Revision))
+	}
+	return strings.Join(items, " and ")
+}
@@ -23,6 +23,7 @@ import (
 	"k8s.io/client-go/1.5/pkg/api"
 	"k8s.io/client-go/1.5/tools/clientcmd"
 
+	"github.com/funktionio/funktion/pkg/client"
 	"github.com/funktionio/funktion/pkg/config"
 	"github.com/funktionio/funktion/pkg/constants"
 	"github.com/funktionio/funktion/pkg/funktion"
@@ -138,17 +139,90 @@ func listOptsForKind(kind string) (string, *api.ListOptions, error) {
 	}
 }
 
+// customResourcesRegistered caches whether the funktion custom resources are registered on the cluster
+var customResourcesRegistered *bool
+
+// createResourceClient returns the client for the Functions, Flows, Runtimes or Connectors in the given namespace.
+// The kind is one of the kinds used by the commands such as `function`. The custom resources are used if they
+// are registered on the cluster otherwise the label based ConfigMaps are used
+func createResourceClient(kubeclient *kubernetes.Clientset, namespace string, kind string) (*funktion.ResourceClient, error) {
+	resourceKind, err := resourceKindFor(kind)
+	if err != nil {
+		return nil, err
+	}
+	tclient := client.NewForClientset(kubeclient)
+	if customResourcesRegistered == nil {
+		registered, err := tclient.Registered()
+		if err != nil {
+			return nil, fmt.Errorf("Could not detect the funktion custom resources due to: %v", err)
+		}
+		customResourcesRegistered = &registered
+	}
+	if !*customResourcesRegistered {
+		tclient = nil
+	}
+	return funktion.NewResourceClient(kubeclient, tclient, namespace, resourceKind), nil
+}
+
+// resourceKindFor returns the kind of the Functions, Flows, Runtimes or Connectors for the given kind used by the commands
+func resourceKindFor(kind string) (string, error) {
+	switch kind {
+	case flowKind:
+		return funktion.FlowKind, nil
+	case connectorKind:
+		return funktion.ConnectorKind, nil
+	case runtimeKind:
+		return funktion.RuntimeKind, nil
+	case functionKind:
+		return funktion.FunctionKind, nil
+	default:
+		return "", fmt.Errorf("Unknown kind `%s`", kind)
+	}
+}
+
+// nameForDeployment returns the name of the Deployment of the given Function or Flow. The operator records the
+// name on the status of the Function or Flow as it differs from the name of the Function or Flow if the name
+// was already taken or if the Function has revisions
 func nameForDeployment(kube *kubernetes.Clientset, namespace string, kind string, name string) (string, error) {
-	// TODO we may need to map a function or flow to a different named resource if we have a naming clash
-	// so we may need to look at a label or annotation on the function / flow
+	status, err := statusFor(kube, namespace, kind, name)
+	if err != nil {
+		return "", err
+	}
+	if len(status.Deployment) > 0 {
+		return status.Deployment, nil
+	}
 	return name, nil
 }
+
+// nameForService returns the name of the Service of the given Function or Flow which the operator
+// records on the status of the Function or Flow
 func nameForService(kube *kubernetes.Clientset, namespace string, kind string, name string) (string, error) {
-	// TODO we may need to map a function or flow to a different named resource if we have a naming clash
-	// so we may need to look at a label or annotation on the function / flow
+	status, err := statusFor(kube, namespace, kind, name)
+	if err != nil {
+		return "", err
+	}
+	if len(status.Service) > 0 {
+		return status.Service, nil
+	}
 	return name, nil
 }
 
+func statusFor(kube *kubernetes.Clientset, namespace string, kind string, name string) (funktion.Status, error) {
+	kind, _, err := listOptsForKind(kind)
+	if err != nil {
+		return funktion.Status{}, err
+	}
+	resources, err := createResourceClient(kube, namespace, kind)
+	if err != nil {
+		return funktion.Status{}, err
+	}
+	cm, err := resources.Get(name)
+	if err != nil {
+		return funktion.Status{}, err
+	}
+	return funktion.GetStatus(cm), nil
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	configPath := constants.ConfigFile
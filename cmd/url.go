@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/funktionio/funktion/pkg/funktion"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
 
@@ -85,13 +86,55 @@ func newLocationCom() *cobra.Command {
 }
 
 func (p *locationCom) run() error {
-	name, err := nameForService(p.kubeclient, p.namespace, p.kind, p.name)
+	kind, _, err := listOptsForKind(p.kind)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case functionKind:
+	case flowKind:
+		if err := p.checkFlowHasService(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unsupported kind `%s` when supported kinds are (`fn`, `flow`)", p.kind)
+	}
+	name, err := nameForService(p.kubeclient, p.namespace, kind, p.name)
 	if err != nil {
 		return err
 	}
 	return p.openService(name)
 }
 
+// checkFlowHasService returns an error if the Connector of the Flow does not have a service template
+// in which case the operator does not create a Service for the Flow
+func (p *locationCom) checkFlowHasService() error {
+	flows, err := createResourceClient(p.kubeclient, p.namespace, flowKind)
+	if err != nil {
+		return err
+	}
+	flow, err := flows.Get(p.name)
+	if err != nil {
+		return fmt.Errorf("No flow %s in namespace %s: %v", p.name, p.namespace, err)
+	}
+	connectorName := flow.Labels[funktion.ConnectorLabel]
+	if len(connectorName) == 0 {
+		return fmt.Errorf("Flow %s does not have label %s", p.name, funktion.ConnectorLabel)
+	}
+	connectors, err := createResourceClient(p.kubeclient, p.namespace, connectorKind)
+	if err != nil {
+		return err
+	}
+	connector, err := connectors.Get(connectorName)
+	if err != nil {
+		return fmt.Errorf("No connector %s in namespace %s: %v", connectorName, p.namespace, err)
+	}
+	if len(connector.Data[funktion.ServiceYmlProperty]) == 0 {
+		return fmt.Errorf("Flow %s uses connector %s which does not accept inbound HTTP so it has no URL", p.name, connectorName)
+	}
+	return nil
+}
+
 func (p *locationCom) openService(serviceName string) error {
 	c := p.kubeclient
 	ns := p.namespace
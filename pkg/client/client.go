@@ -0,0 +1,228 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/watch"
+	"k8s.io/client-go/1.5/rest"
+)
+
+const (
+	// FunctionsResource is the resource name of Functions
+	FunctionsResource = "functions"
+	// FlowsResource is the resource name of Flows
+	FlowsResource = "flows"
+	// RuntimesResource is the resource name of Runtimes
+	RuntimesResource = "runtimes"
+	// ConnectorsResource is the resource name of Connectors
+	ConnectorsResource = "connectors"
+)
+
+// Client reads and writes the funktion custom resources
+type Client struct {
+	restClient *rest.RESTClient
+}
+
+// New creates a Client which uses the given REST client to talk to the API server
+func New(restClient *rest.RESTClient) *Client {
+	return &Client{
+		restClient: restClient,
+	}
+}
+
+// NewForClientset creates a Client which talks to the same API server as the given Clientset
+func NewForClientset(kclient *kubernetes.Clientset) *Client {
+	return New(kclient.Core().GetRESTClient())
+}
+
+// Registered returns true if the funktion custom resources are registered on the cluster
+func (c *Client) Registered() (bool, error) {
+	err := c.restClient.Get().AbsPath("/apis", spec.Group, spec.Version).Do().Error()
+	if err == nil {
+		return true, nil
+	}
+	if se, ok := err.(*errors.StatusError); ok && se.Status().Code == http.StatusNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// Functions returns the interface to the Functions in the given namespace or all namespaces if empty
+func (c *Client) Functions(namespace string) FunctionInterface {
+	return &functions{c.resource(namespace, FunctionsResource)}
+}
+
+// Flows returns the interface to the Flows in the given namespace or all namespaces if empty
+func (c *Client) Flows(namespace string) FlowInterface {
+	return &flows{c.resource(namespace, FlowsResource)}
+}
+
+// Runtimes returns the interface to the Runtimes in the given namespace or all namespaces if empty
+func (c *Client) Runtimes(namespace string) RuntimeInterface {
+	return &runtimes{c.resource(namespace, RuntimesResource)}
+}
+
+// Connectors returns the interface to the Connectors in the given namespace or all namespaces if empty
+func (c *Client) Connectors(namespace string) ConnectorInterface {
+	return &connectors{c.resource(namespace, ConnectorsResource)}
+}
+
+func (c *Client) resource(namespace string, resource string) *resourceClient {
+	return &resourceClient{
+		restClient: c.restClient,
+		namespace:  namespace,
+		resource:   resource,
+	}
+}
+
+// resourceClient performs the REST calls for one kind of custom resource encoding the objects as JSON
+type resourceClient struct {
+	restClient *rest.RESTClient
+	namespace  string
+	resource   string
+}
+
+func (r *resourceClient) path(name string) []string {
+	path := []string{"/apis", spec.Group, spec.Version}
+	if len(r.namespace) > 0 {
+		path = append(path, "namespaces", r.namespace)
+	}
+	path = append(path, r.resource)
+	if len(name) > 0 {
+		path = append(path, name)
+	}
+	return path
+}
+
+func (r *resourceClient) list(opts api.ListOptions, into interface{}) error {
+	req := r.restClient.Get().AbsPath(r.path("")...)
+	if opts.LabelSelector != nil && !opts.LabelSelector.Empty() {
+		req = req.Param("labelSelector", opts.LabelSelector.String())
+	}
+	data, err := req.DoRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func (r *resourceClient) get(name string, into interface{}) error {
+	data, err := r.restClient.Get().AbsPath(r.path(name)...).DoRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func (r *resourceClient) create(obj interface{}, into interface{}) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	data, err := r.restClient.Post().AbsPath(r.path("")...).Body(body).DoRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func (r *resourceClient) update(name string, obj interface{}, into interface{}) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	data, err := r.restClient.Put().AbsPath(r.path(name)...).Body(body).DoRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func (r *resourceClient) delete(name string, opts *api.DeleteOptions) error {
+	req := r.restClient.Delete().AbsPath(r.path(name)...)
+	if opts != nil {
+		o := *opts
+		o.Kind = "DeleteOptions"
+		o.APIVersion = "v1"
+		body, err := json.Marshal(&o)
+		if err != nil {
+			return err
+		}
+		req = req.Body(body)
+	}
+	return req.Do().Error()
+}
+
+func (r *resourceClient) watch(opts api.ListOptions, newObject func() runtime.Object) (watch.Interface, error) {
+	req := r.restClient.Get().AbsPath(r.path("")...).Param("watch", "true")
+	if len(opts.ResourceVersion) > 0 {
+		req = req.Param("resourceVersion", opts.ResourceVersion)
+	}
+	if opts.LabelSelector != nil && !opts.LabelSelector.Empty() {
+		req = req.Param("labelSelector", opts.LabelSelector.String())
+	}
+	stream, err := req.Stream()
+	if err != nil {
+		return nil, err
+	}
+	return watch.NewStreamWatcher(&watchDecoder{
+		dec:       json.NewDecoder(stream),
+		close:     stream.Close,
+		newObject: newObject,
+	}), nil
+}
+
+// watchDecoder decodes the JSON watch events of a custom resource
+type watchDecoder struct {
+	dec       *json.Decoder
+	close     func() error
+	newObject func() runtime.Object
+}
+
+func (d *watchDecoder) Close() {
+	d.close()
+}
+
+func (d *watchDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	var e struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := d.dec.Decode(&e); err != nil {
+		return watch.Error, nil, err
+	}
+	if e.Type == watch.Error {
+		status := &unversioned.Status{}
+		if err := json.Unmarshal(e.Object, status); err != nil {
+			return watch.Error, nil, err
+		}
+		return e.Type, status, nil
+	}
+	obj := d.newObject()
+	if err := json.Unmarshal(e.Object, obj); err != nil {
+		return watch.Error, nil, err
+	}
+	return e.Type, obj, nil
+}
@@ -0,0 +1,238 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Package fake provides an in memory API server for the funktion custom resources to test code using a client.Client
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/funktionio/funktion/pkg/client"
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/labels"
+	"k8s.io/client-go/1.5/rest"
+)
+
+// Server serves the funktion custom resources from memory. It does not support watches
+type Server struct {
+	*httptest.Server
+
+	lock            sync.Mutex
+	objects         map[string]map[string]map[string]interface{}
+	writes          []string
+	resourceVersion int
+}
+
+// NewServer starts a new Server which must be closed once the test is done
+func NewServer() *Server {
+	s := &Server{
+		objects: map[string]map[string]map[string]interface{}{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a client.Client talking to the server
+func (s *Server) Client() *client.Client {
+	restClient, err := rest.RESTClientFor(&rest.Config{
+		Host: s.URL,
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &unversioned.GroupVersion{Group: spec.Group, Version: spec.Version},
+			NegotiatedSerializer: api.Codecs,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return client.New(restClient)
+}
+
+// Writes returns the method and path of each request which created, updated or deleted a resource
+func (s *Server) Writes() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.writes...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prefix := "/apis/" + spec.Group + "/" + spec.Version
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		writeStatus(w, http.StatusNotFound, unversioned.StatusReasonNotFound, req.URL.Path+" not found")
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, prefix), "/"), "/")
+	if len(parts) == 1 && len(parts[0]) == 0 {
+		// the discovery of the API group used to detect whether the custom resources are registered
+		writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "APIResourceList", "groupVersion": spec.APIVersion})
+		return
+	}
+	namespace := ""
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		namespace = parts[1]
+		parts = parts[2:]
+	}
+	resource := parts[0]
+	name := ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	objects := s.objects[resource]
+	if objects == nil {
+		objects = map[string]map[string]interface{}{}
+		s.objects[resource] = objects
+	}
+	key := namespace + "/" + name
+
+	if req.Method != "GET" {
+		s.writes = append(s.writes, req.Method+" "+req.URL.Path)
+	}
+	switch req.Method {
+	case "GET":
+		if len(name) == 0 {
+			s.list(w, req, objects, namespace)
+			return
+		}
+		if obj, ok := objects[key]; ok {
+			writeJSON(w, http.StatusOK, obj)
+			return
+		}
+	case "POST":
+		obj, err := readObject(req)
+		if err != nil {
+			writeStatus(w, http.StatusBadRequest, unversioned.StatusReasonBadRequest, err.Error())
+			return
+		}
+		metadata := obj["metadata"].(map[string]interface{})
+		name, _ = metadata["name"].(string)
+		key = namespace + "/" + name
+		if _, ok := objects[key]; ok {
+			writeStatus(w, http.StatusConflict, unversioned.StatusReasonAlreadyExists, fmt.Sprintf("%s %q already exists", resource, name))
+			return
+		}
+		metadata["namespace"] = namespace
+		metadata["uid"] = fmt.Sprintf("%s-%s-uid", resource, name)
+		objects[key] = s.store(obj)
+		writeJSON(w, http.StatusCreated, obj)
+		return
+	case "PUT":
+		if _, ok := objects[key]; ok {
+			obj, err := readObject(req)
+			if err != nil {
+				writeStatus(w, http.StatusBadRequest, unversioned.StatusReasonBadRequest, err.Error())
+				return
+			}
+			objects[key] = s.store(obj)
+			writeJSON(w, http.StatusOK, obj)
+			return
+		}
+	case "DELETE":
+		if _, ok := objects[key]; ok {
+			delete(objects, key)
+			writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "Status", "apiVersion": "v1", "status": unversioned.StatusSuccess})
+			return
+		}
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, unversioned.StatusReasonMethodNotAllowed, req.Method+" is not supported")
+		return
+	}
+	writeStatus(w, http.StatusNotFound, unversioned.StatusReasonNotFound, fmt.Sprintf("%s %q not found", resource, name))
+}
+
+func (s *Server) list(w http.ResponseWriter, req *http.Request, objects map[string]map[string]interface{}, namespace string) {
+	selector := labels.Everything()
+	if text := req.URL.Query().Get("labelSelector"); len(text) > 0 {
+		var err error
+		if selector, err = labels.Parse(text); err != nil {
+			writeStatus(w, http.StatusBadRequest, unversioned.StatusReasonBadRequest, err.Error())
+			return
+		}
+	}
+	keys := []string{}
+	for key := range objects {
+		if len(namespace) == 0 || strings.HasPrefix(key, namespace+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	items := []interface{}{}
+	for _, key := range keys {
+		obj := objects[key]
+		set := labels.Set{}
+		if values, ok := obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{}); ok {
+			for k, v := range values {
+				set[k], _ = v.(string)
+			}
+		}
+		if selector.Matches(set) {
+			items = append(items, obj)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": strconv.Itoa(s.resourceVersion)},
+		"items":    items,
+	})
+}
+
+// store gives the object a new resourceVersion
+func (s *Server) store(obj map[string]interface{}) map[string]interface{} {
+	s.resourceVersion++
+	obj["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(s.resourceVersion)
+	return obj
+}
+
+func readObject(req *http.Request) (map[string]interface{}, error) {
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	if _, ok := obj["metadata"].(map[string]interface{}); !ok {
+		obj["metadata"] = map[string]interface{}{}
+	}
+	return obj, nil
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason unversioned.StatusReason, message string) {
+	writeJSON(w, code, map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     unversioned.StatusFailure,
+		"reason":     reason,
+		"message":    message,
+		"code":       code,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
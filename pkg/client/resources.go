@@ -0,0 +1,207 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/watch"
+)
+
+// FunctionInterface reads and writes Functions
+type FunctionInterface interface {
+	List(opts api.ListOptions) (*spec.FunctionList, error)
+	Get(name string) (*spec.Function, error)
+	Create(fn *spec.Function) (*spec.Function, error)
+	Update(fn *spec.Function) (*spec.Function, error)
+	Delete(name string, opts *api.DeleteOptions) error
+	Watch(opts api.ListOptions) (watch.Interface, error)
+}
+
+type functions struct {
+	r *resourceClient
+}
+
+func (c *functions) List(opts api.ListOptions) (*spec.FunctionList, error) {
+	list := &spec.FunctionList{}
+	return list, c.r.list(opts, list)
+}
+
+func (c *functions) Get(name string) (*spec.Function, error) {
+	answer := &spec.Function{}
+	return answer, c.r.get(name, answer)
+}
+
+func (c *functions) Create(fn *spec.Function) (*spec.Function, error) {
+	fn.Kind = "Function"
+	fn.APIVersion = spec.APIVersion
+	answer := &spec.Function{}
+	return answer, c.r.create(fn, answer)
+}
+
+func (c *functions) Update(fn *spec.Function) (*spec.Function, error) {
+	fn.Kind = "Function"
+	fn.APIVersion = spec.APIVersion
+	answer := &spec.Function{}
+	return answer, c.r.update(fn.Name, fn, answer)
+}
+
+func (c *functions) Delete(name string, opts *api.DeleteOptions) error {
+	return c.r.delete(name, opts)
+}
+
+func (c *functions) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return c.r.watch(opts, func() runtime.Object { return &spec.Function{} })
+}
+
+// FlowInterface reads and writes Flows
+type FlowInterface interface {
+	List(opts api.ListOptions) (*spec.FlowList, error)
+	Get(name string) (*spec.Flow, error)
+	Create(flow *spec.Flow) (*spec.Flow, error)
+	Update(flow *spec.Flow) (*spec.Flow, error)
+	Delete(name string, opts *api.DeleteOptions) error
+	Watch(opts api.ListOptions) (watch.Interface, error)
+}
+
+type flows struct {
+	r *resourceClient
+}
+
+func (c *flows) List(opts api.ListOptions) (*spec.FlowList, error) {
+	list := &spec.FlowList{}
+	return list, c.r.list(opts, list)
+}
+
+func (c *flows) Get(name string) (*spec.Flow, error) {
+	answer := &spec.Flow{}
+	return answer, c.r.get(name, answer)
+}
+
+func (c *flows) Create(flow *spec.Flow) (*spec.Flow, error) {
+	flow.Kind = "Flow"
+	flow.APIVersion = spec.APIVersion
+	answer := &spec.Flow{}
+	return answer, c.r.create(flow, answer)
+}
+
+func (c *flows) Update(flow *spec.Flow) (*spec.Flow, error) {
+	flow.Kind = "Flow"
+	flow.APIVersion = spec.APIVersion
+	answer := &spec.Flow{}
+	return answer, c.r.update(flow.Name, flow, answer)
+}
+
+func (c *flows) Delete(name string, opts *api.DeleteOptions) error {
+	return c.r.delete(name, opts)
+}
+
+func (c *flows) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return c.r.watch(opts, func() runtime.Object { return &spec.Flow{} })
+}
+
+// RuntimeInterface reads and writes Runtimes
+type RuntimeInterface interface {
+	List(opts api.ListOptions) (*spec.RuntimeList, error)
+	Get(name string) (*spec.Runtime, error)
+	Create(rt *spec.Runtime) (*spec.Runtime, error)
+	Update(rt *spec.Runtime) (*spec.Runtime, error)
+	Delete(name string, opts *api.DeleteOptions) error
+	Watch(opts api.ListOptions) (watch.Interface, error)
+}
+
+type runtimes struct {
+	r *resourceClient
+}
+
+func (c *runtimes) List(opts api.ListOptions) (*spec.RuntimeList, error) {
+	list := &spec.RuntimeList{}
+	return list, c.r.list(opts, list)
+}
+
+func (c *runtimes) Get(name string) (*spec.Runtime, error) {
+	answer := &spec.Runtime{}
+	return answer, c.r.get(name, answer)
+}
+
+func (c *runtimes) Create(rt *spec.Runtime) (*spec.Runtime, error) {
+	rt.Kind = "Runtime"
+	rt.APIVersion = spec.APIVersion
+	answer := &spec.Runtime{}
+	return answer, c.r.create(rt, answer)
+}
+
+func (c *runtimes) Update(rt *spec.Runtime) (*spec.Runtime, error) {
+	rt.Kind = "Runtime"
+	rt.APIVersion = spec.APIVersion
+	answer := &spec.Runtime{}
+	return answer, c.r.update(rt.Name, rt, answer)
+}
+
+func (c *runtimes) Delete(name string, opts *api.DeleteOptions) error {
+	return c.r.delete(name, opts)
+}
+
+func (c *runtimes) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return c.r.watch(opts, func() runtime.Object { return &spec.Runtime{} })
+}
+
+// ConnectorInterface reads and writes Connectors
+type ConnectorInterface interface {
+	List(opts api.ListOptions) (*spec.ConnectorList, error)
+	Get(name string) (*spec.Connector, error)
+	Create(connector *spec.Connector) (*spec.Connector, error)
+	Update(connector *spec.Connector) (*spec.Connector, error)
+	Delete(name string, opts *api.DeleteOptions) error
+	Watch(opts api.ListOptions) (watch.Interface, error)
+}
+
+type connectors struct {
+	r *resourceClient
+}
+
+func (c *connectors) List(opts api.ListOptions) (*spec.ConnectorList, error) {
+	list := &spec.ConnectorList{}
+	return list, c.r.list(opts, list)
+}
+
+func (c *connectors) Get(name string) (*spec.Connector, error) {
+	answer := &spec.Connector{}
+	return answer, c.r.get(name, answer)
+}
+
+func (c *connectors) Create(connector *spec.Connector) (*spec.Connector, error) {
+	connector.Kind = "Connector"
+	connector.APIVersion = spec.APIVersion
+	answer := &spec.Connector{}
+	return answer, c.r.create(connector, answer)
+}
+
+func (c *connectors) Update(connector *spec.Connector) (*spec.Connector, error) {
+	connector.Kind = "Connector"
+	connector.APIVersion = spec.APIVersion
+	answer := &spec.Connector{}
+	return answer, c.r.update(connector.Name, connector, answer)
+}
+
+func (c *connectors) Delete(name string, opts *api.DeleteOptions) error {
+	return c.r.delete(name, opts)
+}
+
+func (c *connectors) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return c.r.watch(opts, func() runtime.Object { return &spec.Connector{} })
+}
@@ -0,0 +1,65 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/funktionio/funktion/pkg/k8sutil"
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+// thirdPartyResources are the ThirdPartyResources registering the funktion custom resources by resource name
+var thirdPartyResources = map[string]*v1beta1.ThirdPartyResource{
+	FunctionsResource:  newThirdPartyResource("function", "A function deployed using a Runtime"),
+	FlowsResource:      newThirdPartyResource("flow", "A flow binding a Connector to steps such as invoking a Function"),
+	RuntimesResource:   newThirdPartyResource("runtime", "The templates used to deploy Functions"),
+	ConnectorsResource: newThirdPartyResource("connector", "The templates used to deploy Flows"),
+}
+
+func newThirdPartyResource(name string, description string) *v1beta1.ThirdPartyResource {
+	return &v1beta1.ThirdPartyResource{
+		ObjectMeta: v1.ObjectMeta{
+			Name: name + "." + spec.Group,
+		},
+		Description: description,
+		Versions: []v1beta1.APIVersion{
+			{Name: spec.Version},
+		},
+	}
+}
+
+// CreateThirdPartyResources registers the funktion custom resources unless they already exist
+// and waits until they can be used
+func CreateThirdPartyResources(kclient kubernetes.Interface) error {
+	tprClient := kclient.Extensions().ThirdPartyResources()
+	for _, tpr := range thirdPartyResources {
+		if _, err := tprClient.Create(tpr); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("Failed to create ThirdPartyResource %s: %v", tpr.Name, err)
+		}
+	}
+	// we have to wait for the resources to be ready otherwise the initial watches may fail
+	for resource := range thirdPartyResources {
+		if err := k8sutil.WaitForTPRReady(kclient.Core().GetRESTClient(), spec.Group, spec.Version, resource); err != nil {
+			return fmt.Errorf("Failed waiting for %s to be ready: %v", resource, err)
+		}
+	}
+	return nil
+}
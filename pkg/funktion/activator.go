@@ -0,0 +1,261 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const (
+	// maxBufferedBody is the largest request body the activator buffers while a Function wakes up
+	maxBufferedBody = 10 * 1024 * 1024
+
+	defaultWakeTimeout      = 2 * time.Minute
+	defaultWakePollInterval = time.Second
+	defaultActivityInterval = 30 * time.Second
+)
+
+// Activator is an HTTP handler which proxies requests of the form `/{namespace}/{name}/{path}` to the
+// Service of the Function. It records the activity of the Function so that it is not scaled to zero
+// while receiving requests and it scales an idle Function back up, holding the request until it is available
+type Activator struct {
+	kclient kubernetes.Interface
+	logger  log.Logger
+
+	// deployments and services are the caches of the managed Deployments and Services indexed by their owner
+	deployments cache.Indexer
+	services    cache.Indexer
+
+	// resolve returns the URL of the Service of the given Function
+	resolve func(namespace string, name string) (*url.URL, error)
+	now     func() time.Time
+
+	wakeTimeout      time.Duration
+	wakePollInterval time.Duration
+	activityInterval time.Duration
+
+	lock     sync.Mutex
+	recorded map[string]time.Time
+}
+
+// NewActivator creates an Activator for the Functions accessible with the given client which looks up
+// their Deployments and Services in the given caches rather than querying the API server on every request
+func NewActivator(kclient kubernetes.Interface, deployments cache.Indexer, services cache.Indexer, logger log.Logger) *Activator {
+	a := &Activator{
+		kclient:          kclient,
+		logger:           logger,
+		deployments:      deployments,
+		services:         services,
+		now:              time.Now,
+		wakeTimeout:      defaultWakeTimeout,
+		wakePollInterval: defaultWakePollInterval,
+		activityInterval: defaultActivityInterval,
+		recorded:         map[string]time.Time{},
+	}
+	a.resolve = a.serviceURL
+	return a
+}
+
+// ActivatorHandler returns the HTTP handler proxying requests to Functions which wakes up idle Functions
+func (c *Operator) ActivatorHandler() http.Handler {
+	return NewActivator(c.kclient, c.deploymentInf.GetIndexer(), c.serviceInf.GetIndexer(), c.logger)
+}
+
+func (a *Activator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		http.Error(w, "expected a path of the form /{namespace}/{function}/{path}", http.StatusNotFound)
+		return
+	}
+	namespace := parts[0]
+	name := parts[1]
+	path := "/"
+	if len(parts) == 3 {
+		path += parts[2]
+	}
+
+	// lets buffer the body so that the request can be replayed once the Function is available
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBufferedBody+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read request body: %s", err), http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxBufferedBody {
+			http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+
+	status, err := a.activate(namespace, name)
+	if err != nil {
+		a.logger.Log("msg", "failed to activate function", "namespace", namespace, "name", name, "err", err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	target, err := a.resolve(namespace, name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to find the service of function %s: %s", name, err), http.StatusBadGateway)
+		return
+	}
+	r.URL.Path = path
+	r.Host = target.Host
+	httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+}
+
+// activate records the activity of the given Function and scales up the Deployments of its revisions which
+// have been scaled to zero, waiting until one of them is available. The HTTP status to respond with is
+// returned with any error
+func (a *Activator) activate(namespace string, name string) (int, error) {
+	deployments, err := a.functionDeployments(namespace, name)
+	if err != nil {
+		return http.StatusBadGateway, err
+	}
+	if len(deployments) == 0 {
+		return http.StatusNotFound, fmt.Errorf("function %s does not exist in namespace %s", name, namespace)
+	}
+
+	deploymentClient := a.kclient.Extensions().Deployments(namespace)
+	record := a.shouldRecordActivity(namespace, name)
+	available := false
+	for _, deployment := range deployments {
+		if deploymentReplicas(deployment) == 0 {
+			a.logger.Log("msg", "scaling up idle function", "namespace", namespace, "name", deployment.Name)
+			updated := a.withActivity(deployment)
+			replicas := int32(1)
+			updated.Spec.Replicas = &replicas
+			if _, err := deploymentClient.Update(updated); err != nil && !errors.IsConflict(err) {
+				return http.StatusBadGateway, fmt.Errorf("failed to scale up function %s: %s", name, err)
+			}
+			continue
+		}
+		if deployment.Status.AvailableReplicas > 0 {
+			available = true
+		}
+		if record {
+			if _, err := deploymentClient.Update(a.withActivity(deployment)); err != nil {
+				// the request can still be served so lets try again on the next request
+				a.forgetActivity(namespace, name)
+				a.logger.Log("msg", "failed to record activity", "namespace", namespace, "name", deployment.Name, "err", err)
+			}
+		}
+	}
+	if available {
+		return http.StatusOK, nil
+	}
+	return a.awaitAvailable(namespace, name)
+}
+
+// awaitAvailable polls the Deployments of the Function until one has an available replica or the wake timeout expires
+func (a *Activator) awaitAvailable(namespace string, name string) (int, error) {
+	deadline := a.now().Add(a.wakeTimeout)
+	for {
+		deployments, err := a.functionDeployments(namespace, name)
+		if err != nil {
+			return http.StatusBadGateway, err
+		}
+		for _, deployment := range deployments {
+			if deployment.Status.AvailableReplicas > 0 {
+				return http.StatusOK, nil
+			}
+		}
+		if !a.now().Before(deadline) {
+			return http.StatusGatewayTimeout, fmt.Errorf("function %s did not become available within %v", name, a.wakeTimeout)
+		}
+		time.Sleep(a.wakePollInterval)
+	}
+}
+
+// functionDeployments returns the cached Deployments running the revisions of the given Function. Only
+// the Deployments managed for Functions are indexed by a Function owner so that the activator cannot be
+// used to scale up anything else
+func (a *Activator) functionDeployments(namespace string, name string) ([]*v1beta1.Deployment, error) {
+	objs, err := a.deployments.ByIndex(ownerIndex, ownerIndexKey(FunctionKind, referenceKey(namespace, name)))
+	if err != nil {
+		return nil, err
+	}
+	answer := []*v1beta1.Deployment{}
+	for _, obj := range objs {
+		answer = append(answer, obj.(*v1beta1.Deployment))
+	}
+	return answer, nil
+}
+
+// withActivity returns a copy of the cached Deployment recording the current time as its last activity
+func (a *Activator) withActivity(deployment *v1beta1.Deployment) *v1beta1.Deployment {
+	answer := *deployment
+	answer.Annotations = copyStringMap(deployment.Annotations)
+	answer.Annotations[LastActivityAnnotation] = a.now().UTC().Format(time.RFC3339)
+	return &answer
+}
+
+// shouldRecordActivity returns true if the activity of the Function has not been recorded recently
+// so that we don't update the Deployment on every request
+func (a *Activator) shouldRecordActivity(namespace string, name string) bool {
+	key := referenceKey(namespace, name)
+	now := a.now()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if last, ok := a.recorded[key]; ok && now.Sub(last) < a.activityInterval {
+		return false
+	}
+	a.recorded[key] = now
+	return true
+}
+
+func (a *Activator) forgetActivity(namespace string, name string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.recorded, referenceKey(namespace, name))
+}
+
+// serviceURL returns the cluster URL of the Service of the given Function. The Service is looked up by
+// its owner as it is not named after the Function if the name was already taken
+func (a *Activator) serviceURL(namespace string, name string) (*url.URL, error) {
+	objs, err := a.services.ByIndex(ownerIndex, ownerIndexKey(FunctionKind, referenceKey(namespace, name)))
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("function %s has no service", name)
+	}
+	service := objs[0].(*v1.Service)
+	if len(service.Spec.Ports) == 0 {
+		return nil, fmt.Errorf("service %s has no ports", service.Name)
+	}
+	return &url.URL{
+		Scheme: "http",
+		Host:   fmt.Sprintf("%s.%s:%d", service.Name, namespace, service.Spec.Ports[0].Port),
+	}, nil
+}
@@ -0,0 +1,235 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+// newTestDeployments returns a fake client and a cache of the managed Deployments holding the given Deployments
+func newTestDeployments(deployments ...*v1beta1.Deployment) (*fake.Clientset, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{ownerIndex: ownerIndexFunc})
+	objects := []runtime.Object{}
+	for _, d := range deployments {
+		indexer.Add(d)
+		objects = append(objects, d)
+	}
+	return fake.NewSimpleClientset(objects...), indexer
+}
+
+// newTestActivator returns an Activator proxying to a stub of the function which echoes the path and body
+func newTestActivator(kclient *fake.Clientset, deployments cache.Indexer) (*Activator, *httptest.Server) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.URL.Path + " " + string(body)))
+	}))
+	services := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{ownerIndex: ownerIndexFunc})
+	a := NewActivator(kclient, deployments, services, log.NewNopLogger())
+	a.now = func() time.Time { return idleTestNow }
+	a.wakePollInterval = time.Millisecond
+	a.resolve = func(namespace string, name string) (*url.URL, error) {
+		return url.Parse(backend.URL)
+	}
+	return a, backend
+}
+
+func activatorPost(t *testing.T, a *Activator, path string, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	return w
+}
+
+func TestActivatorWakesIdleFunction(t *testing.T) {
+	kclient, cached := newTestDeployments(functionDeployment(0, idleTestNow.Add(-time.Hour)))
+	a, backend := newTestActivator(kclient, cached)
+	defer backend.Close()
+
+	// lets pretend to be the deployment controller making the pod available once scaled up
+	// and the informer updating the cache
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		deployments := kclient.Extensions().Deployments("default")
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			d, err := deployments.Get("hello-v1")
+			if err == nil && deploymentReplicas(d) > 0 && d.Status.AvailableReplicas == 0 {
+				d.Status.AvailableReplicas = 1
+				if d, err = deployments.Update(d); err == nil {
+					cached.Update(d)
+				}
+			}
+		}
+	}()
+
+	w := activatorPost(t, a, "/default/hello/greet", "world")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+	}
+	if actual := w.Body.String(); actual != "/greet world" {
+		t.Errorf("expected the buffered request to be proxied but got %s", actual)
+	}
+	d, err := kclient.Extensions().Deployments("default").Get("hello-v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := deploymentReplicas(d); actual != 1 {
+		t.Errorf("expected the function to be scaled to 1 replica but got %d", actual)
+	}
+	if actual := lastActivity(d); !actual.Equal(idleTestNow) {
+		t.Errorf("expected the last activity to be %v but got %v", idleTestNow, actual)
+	}
+}
+
+func TestActivatorTimesOut(t *testing.T) {
+	kclient, deployments := newTestDeployments(functionDeployment(0, idleTestNow.Add(-time.Hour)))
+	a, backend := newTestActivator(kclient, deployments)
+	defer backend.Close()
+	a.wakeTimeout = 0
+
+	w := activatorPost(t, a, "/default/hello/", "")
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d but got %d", http.StatusGatewayTimeout, w.Code)
+	}
+}
+
+func TestActivatorRecordsActivity(t *testing.T) {
+	deployment := functionDeployment(1, idleTestNow.Add(-time.Hour))
+	deployment.Status.AvailableReplicas = 1
+	kclient, deployments := newTestDeployments(deployment)
+	a, backend := newTestActivator(kclient, deployments)
+	defer backend.Close()
+
+	w := activatorPost(t, a, "/default/hello", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", w.Code, w.Body.String())
+	}
+	d, err := kclient.Extensions().Deployments("default").Get("hello-v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := lastActivity(d); !actual.Equal(idleTestNow) {
+		t.Errorf("expected the last activity to be %v but got %v", idleTestNow, actual)
+	}
+	if a.shouldRecordActivity("default", "hello") {
+		t.Errorf("expected the activity not to be recorded again within the activity interval")
+	}
+	if actual := lastActivity(deployment); !actual.Equal(idleTestNow.Add(-time.Hour)) {
+		t.Errorf("expected the cached deployment not to be modified but its last activity is %v", actual)
+	}
+}
+
+func TestActivatorRejectsUnknownFunctions(t *testing.T) {
+	other := functionDeployment(0, idleTestNow)
+	other.Name = "other"
+	other.Labels = nil
+	kclient, deployments := newTestDeployments(other)
+	a, backend := newTestActivator(kclient, deployments)
+	defer backend.Close()
+
+	for _, path := range []string{"/default/missing/", "/default/other/", "/default"} {
+		if w := activatorPost(t, a, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404 but got %d", path, w.Code)
+		}
+	}
+	d, err := kclient.Extensions().Deployments("default").Get("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := deploymentReplicas(d); actual != 0 {
+		t.Errorf("expected a deployment which is not a function not to be scaled up but got %d replicas", actual)
+	}
+}
+
+func TestReconcileRestoresReplicasOfWokenFunction(t *testing.T) {
+	runtime := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "nodejs"},
+		Data: map[string]string{
+			DeploymentProperty:  overridesTestDeployment,
+			IdleTimeoutProperty: "10m",
+		},
+	}
+	function := functionConfigMap("nodejs", map[string]string{
+		SourceProperty:   "module.exports = function(context, callback) {}",
+		ReplicasProperty: "3",
+	})
+	revision := &functionRevision{Number: 1, Data: makeRevision(function, 1, "")}
+	kclient, cached := newTestDeployments()
+	c := &Operator{
+		kclient:       kclient,
+		logger:        log.NewNopLogger(),
+		recorder:      newEventRecorder(kclient, log.NewNopLogger()),
+		deploymentInf: &testInformer{synced: true, indexer: cached},
+	}
+	reconcile := func(expected int32) {
+		d, err := c.reconcileFunctionDeployment(function, runtime, revision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := deploymentReplicas(d); actual != expected {
+			t.Errorf("expected %d replicas but got %d", expected, actual)
+		}
+		cached.Update(d)
+	}
+	reconcile(3)
+
+	// lets pretend the idle scaler scaled the Function to zero
+	deployments := kclient.Extensions().Deployments("default")
+	d, err := deployments.Get(RevisionName("hello", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicas := int32(0)
+	d.Spec.Replicas = &replicas
+	if d, err = deployments.Update(d); err != nil {
+		t.Fatal(err)
+	}
+	cached.Update(d)
+	reconcile(0)
+
+	a, backend := newTestActivator(kclient, cached)
+	defer backend.Close()
+	a.wakeTimeout = 0
+	activatorPost(t, a, "/default/hello/", "")
+	if d, err = deployments.Get(RevisionName("hello", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if actual := deploymentReplicas(d); actual != 1 {
+		t.Fatalf("expected the activator to wake the function with 1 replica but got %d", actual)
+	}
+	cached.Update(d)
+	reconcile(3)
+}
@@ -0,0 +1,254 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/funktionio/funktion/pkg/spec"
+	"github.com/ghodss/yaml"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+// AdmissionReview is the request and response of a validating admission webhook
+type AdmissionReview struct {
+	APIVersion string             `json:"apiVersion,omitempty"`
+	Kind       string             `json:"kind,omitempty"`
+	Request    *AdmissionRequest  `json:"request,omitempty"`
+	Response   *AdmissionResponse `json:"response,omitempty"`
+}
+
+// AdmissionRequest describes the resource being admitted
+type AdmissionRequest struct {
+	UID       string           `json:"uid"`
+	Kind      GroupVersionKind `json:"kind"`
+	Namespace string           `json:"namespace,omitempty"`
+	Operation string           `json:"operation"`
+	Object    json.RawMessage  `json:"object,omitempty"`
+	OldObject json.RawMessage  `json:"oldObject,omitempty"`
+}
+
+// GroupVersionKind identifies the kind of the resource being admitted
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// AdmissionResponse tells the API server whether the resource is allowed
+type AdmissionResponse struct {
+	UID     string           `json:"uid"`
+	Allowed bool             `json:"allowed"`
+	Result  *AdmissionResult `json:"status,omitempty"`
+}
+
+// AdmissionResult holds the reason a resource was rejected
+type AdmissionResult struct {
+	Message string `json:"message,omitempty"`
+}
+
+// admissionValidator validates Functions and Flows using the given lookups of the Runtimes and Connectors
+type admissionValidator struct {
+	runtimeExists   func(namespace string, name string) bool
+	connectorExists func(namespace string, name string) bool
+}
+
+// AdmissionHandler returns the HTTP handler of the validating admission webhook which rejects
+// Functions and Flows referring to unknown Runtimes or Connectors or holding invalid data
+func (c *Operator) AdmissionHandler() http.Handler {
+	return newAdmissionHandler(&admissionValidator{
+		runtimeExists:   informerContains(c.runtimeInf),
+		connectorExists: informerContains(c.connectorInf),
+	})
+}
+
+// informerContains returns a lookup of the resources in the informer. Until the informer has synced
+// every resource is assumed to exist so that we don't reject valid resources while starting up
+func informerContains(inf cache.SharedIndexInformer) func(namespace string, name string) bool {
+	return func(namespace string, name string) bool {
+		if !inf.HasSynced() {
+			return true
+		}
+		_, exists, err := inf.GetStore().GetByKey(referenceKey(namespace, name))
+		return err != nil || exists
+	}
+}
+
+func newAdmissionHandler(v *admissionValidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "expected a POST of an AdmissionReview", http.StatusMethodNotAllowed)
+			return
+		}
+		review := AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode AdmissionReview: %s", err), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "AdmissionReview has no request", http.StatusBadRequest)
+			return
+		}
+		response := &AdmissionResponse{
+			UID:     review.Request.UID,
+			Allowed: true,
+		}
+		if err := v.validate(review.Request); err != nil {
+			response.Allowed = false
+			response.Result = &AdmissionResult{
+				Message: err.Error(),
+			}
+		}
+		review.Request = nil
+		review.Response = response
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&review)
+	})
+}
+
+// validate returns an error if the resource being created or updated is an invalid Function or Flow.
+// Updates which leave the data and labels alone are always allowed so that adding or removing finalizers
+// and writing the status keeps working for resources whose Runtime or Connector has since gone
+func (v *admissionValidator) validate(req *AdmissionRequest) error {
+	if req.Operation != "CREATE" && req.Operation != "UPDATE" {
+		return nil
+	}
+	cm, err := admissionConfigMap(req.Kind, req.Object)
+	if err != nil || cm == nil {
+		return err
+	}
+	if cm.DeletionTimestamp != nil {
+		return nil
+	}
+	if req.Operation == "UPDATE" && len(req.OldObject) > 0 {
+		old, err := admissionConfigMap(req.Kind, req.OldObject)
+		if err == nil && old != nil && reflect.DeepEqual(old.Data, cm.Data) && reflect.DeepEqual(old.Labels, cm.Labels) {
+			return nil
+		}
+	}
+	if len(cm.Namespace) == 0 {
+		cm.Namespace = req.Namespace
+	}
+	switch cm.Labels[KindLabel] {
+	case FunctionKind:
+		return v.validateFunction(cm)
+	case FlowKind:
+		return v.validateFlow(cm)
+	}
+	return nil
+}
+
+// admissionConfigMap returns the ConfigMap form of the Function or Flow being admitted
+// or nil if the resource is not a Function or Flow
+func admissionConfigMap(kind GroupVersionKind, object json.RawMessage) (*v1.ConfigMap, error) {
+	switch {
+	case kind.Group == "" && kind.Kind == "ConfigMap":
+		cm := &v1.ConfigMap{}
+		if err := json.Unmarshal(object, cm); err != nil {
+			return nil, fmt.Errorf("Failed to parse ConfigMap: %s", err)
+		}
+		return cm, nil
+	case kind.Group == spec.Group && kind.Kind == FunctionKind:
+		fn := &spec.Function{}
+		if err := json.Unmarshal(object, fn); err != nil {
+			return nil, fmt.Errorf("Failed to parse Function: %s", err)
+		}
+		return FunctionToConfigMap(fn)
+	case kind.Group == spec.Group && kind.Kind == FlowKind:
+		flow := &spec.Flow{}
+		if err := json.Unmarshal(object, flow); err != nil {
+			return nil, fmt.Errorf("Failed to parse Flow: %s", err)
+		}
+		return FlowToConfigMap(flow)
+	}
+	return nil, nil
+}
+
+func (v *admissionValidator) validateFunction(cm *v1.ConfigMap) error {
+	runtime := cm.Labels[RuntimeLabel]
+	if len(runtime) == 0 {
+		return fmt.Errorf("Function %s does not have label %s", cm.Name, RuntimeLabel)
+	}
+	if !v.runtimeExists(cm.Namespace, runtime) {
+		return fmt.Errorf("Function %s refers to unknown runtime `%s`", cm.Name, runtime)
+	}
+	if len(strings.TrimSpace(cm.Data[SourceProperty])) == 0 {
+		return fmt.Errorf("Function %s has no `%s`", cm.Name, SourceProperty)
+	}
+	if _, err := autoscalingForFunction(cm); err != nil {
+		return err
+	}
+	if _, err := idleTimeoutForFunction(cm, nil); err != nil {
+		return err
+	}
+	if _, err := ParseTraffic(cm.Data[TrafficProperty]); err != nil {
+		return fmt.Errorf("Function %s: %s", cm.Name, err)
+	}
+	if _, err := replicasForFunction(cm); err != nil {
+		return err
+	}
+	if _, err := GetFunctionFiles(cm.Data); err != nil {
+		return fmt.Errorf("Function %s: %s", cm.Name, err)
+	}
+	if _, err := podOverridesForFunction(cm.Name, cm.Data); err != nil {
+		return err
+	}
+	return validateEnvVars(cm.Data[EnvVarsProperty])
+}
+
+func (v *admissionValidator) validateFlow(cm *v1.ConfigMap) error {
+	connector := cm.Labels[ConnectorLabel]
+	if len(connector) == 0 {
+		return fmt.Errorf("Flow %s does not have label %s", cm.Name, ConnectorLabel)
+	}
+	if !v.connectorExists(cm.Namespace, connector) {
+		return fmt.Errorf("Flow %s refers to unknown connector `%s`", cm.Name, connector)
+	}
+	config := spec.FunkionConfig{}
+	if err := yaml.Unmarshal([]byte(cm.Data[FunktionYmlProperty]), &config); err != nil {
+		return fmt.Errorf("Failed to parse `%s` of Flow %s: %s", FunktionYmlProperty, cm.Name, err)
+	}
+	for _, flow := range config.Flows {
+		for i, step := range flow.Steps {
+			switch step.Kind {
+			case spec.EndpointKind, spec.FunctionKind, spec.SetBodyKind, spec.SetHeadersKind:
+			default:
+				return fmt.Errorf("Step %d of flow `%s` in Flow %s has unknown kind `%s`", i+1, flow.Name, cm.Name, step.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEnvVars returns an error unless every non blank line is a `NAME=VALUE` pair or a reference
+// to the key of a Secret or ConfigMap
+func validateEnvVars(text string) error {
+	for i, line := range strings.Split(text, "\n") {
+		l := strings.TrimSpace(line)
+		if len(l) == 0 {
+			continue
+		}
+		if _, err := ParseEnvVar(l); err != nil {
+			return fmt.Errorf("Invalid `%s` line %d. %s", EnvVarsProperty, i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const validFunktionYml = `flows:
+- name: timer
+  steps:
+  - kind: endpoint
+    uri: timer://foo
+  - kind: function
+    name: hello
+`
+
+func newTestAdmissionServer() *httptest.Server {
+	return httptest.NewServer(newAdmissionHandler(&admissionValidator{
+		runtimeExists: func(namespace string, name string) bool {
+			return namespace == "default" && name == "nodejs"
+		},
+		connectorExists: func(namespace string, name string) bool {
+			return namespace == "default" && name == "timer"
+		},
+	}))
+}
+
+func functionConfigMap(runtime string, data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "hello",
+			Namespace: "default",
+			Labels: map[string]string{
+				KindLabel:    FunctionKind,
+				RuntimeLabel: runtime,
+			},
+		},
+		Data: data,
+	}
+}
+
+func flowConfigMap(connector string, funktionYml string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "timer-flow",
+			Namespace: "default",
+			Labels: map[string]string{
+				KindLabel:      FlowKind,
+				ConnectorLabel: connector,
+			},
+		},
+		Data: map[string]string{
+			FunktionYmlProperty: funktionYml,
+		},
+	}
+}
+
+func admit(t *testing.T, server *httptest.Server, operation string, kind GroupVersionKind, obj interface{}) *AdmissionResponse {
+	return admitUpdate(t, server, operation, kind, nil, obj)
+}
+
+func admitUpdate(t *testing.T, server *httptest.Server, operation string, kind GroupVersionKind, oldObj interface{}, obj interface{}) *AdmissionResponse {
+	object, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := AdmissionReview{
+		APIVersion: "admission.k8s.io/v1beta1",
+		Kind:       "AdmissionReview",
+		Request: &AdmissionRequest{
+			UID:       "1234",
+			Kind:      kind,
+			Namespace: "default",
+			Operation: operation,
+			Object:    object,
+		},
+	}
+	if oldObj != nil {
+		oldObject, err := json.Marshal(oldObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		review.Request.OldObject = oldObject
+	}
+	body, err := json.Marshal(&review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", resp.StatusCode)
+	}
+	answer := AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if answer.Response == nil {
+		t.Fatal("expected a response in the AdmissionReview")
+	}
+	if answer.Response.UID != "1234" {
+		t.Errorf("expected the response uid to be 1234 but got %s", answer.Response.UID)
+	}
+	return answer.Response
+}
+
+var configMapKind = GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+func assertAllowed(t *testing.T, name string, resp *AdmissionResponse) {
+	if !resp.Allowed {
+		t.Errorf("%s: expected to be allowed but was rejected with %v", name, resp.Result)
+	}
+}
+
+func assertRejected(t *testing.T, name string, resp *AdmissionResponse, message string) {
+	if resp.Allowed {
+		t.Errorf("%s: expected to be rejected but was allowed", name)
+		return
+	}
+	if resp.Result == nil || !strings.Contains(resp.Result.Message, message) {
+		t.Errorf("%s: expected the message to contain `%s` but got %v", name, message, resp.Result)
+	}
+}
+
+func TestAdmissionFunctions(t *testing.T) {
+	server := newTestAdmissionServer()
+	defer server.Close()
+
+	assertAllowed(t, "valid function", admit(t, server, "CREATE", configMapKind, functionConfigMap("nodejs", map[string]string{
+		SourceProperty:  "module.exports = function(context, callback) {}",
+		EnvVarsProperty: "FOO=bar\nEMPTY=\n",
+	})))
+	assertRejected(t, "unknown runtime", admit(t, server, "CREATE", configMapKind, functionConfigMap("nodjs", map[string]string{
+		SourceProperty: "module.exports = function(context, callback) {}",
+	})), "unknown runtime `nodjs`")
+	assertRejected(t, "empty source", admit(t, server, "UPDATE", configMapKind, functionConfigMap("nodejs", map[string]string{
+		SourceProperty: "  ",
+	})), "has no `source`")
+	assertRejected(t, "malformed envVars", admit(t, server, "CREATE", configMapKind, functionConfigMap("nodejs", map[string]string{
+		SourceProperty:  "module.exports = function(context, callback) {}",
+		EnvVarsProperty: "FOO=bar\nBAR",
+	})), "line 2")
+	assertRejected(t, "bad envVar name", admit(t, server, "CREATE", configMapKind, functionConfigMap("nodejs", map[string]string{
+		SourceProperty:  "module.exports = function(context, callback) {}",
+		EnvVarsProperty: "MY VAR=bar",
+	})), "line 1")
+	assertAllowed(t, "secret envVar", admit(t, server, "CREATE", configMapKind, functionConfigMap("nodejs", map[string]string{
+		SourceProperty:  "module.exports = function(context, callback) {}",
+		EnvVarsProperty: "FOO=bar\nAPI_KEY=secret:my-secret/api-key\nLEVEL=configmap:settings/level",
+	})))
+	assertRejected(t, "bad secret envVar", admit(t, server, "CREATE", configMapKind, functionConfigMap("nodejs", map[string]string{
+		SourceProperty:  "module.exports = function(context, callback) {}",
+		EnvVarsProperty: "FOO=bar\nAPI_KEY=secret:my-secret",
+	})), "line 2")
+}
+
+func TestAdmissionFlows(t *testing.T) {
+	server := newTestAdmissionServer()
+	defer server.Close()
+
+	assertAllowed(t, "valid flow", admit(t, server, "CREATE", configMapKind, flowConfigMap("timer", validFunktionYml)))
+	assertRejected(t, "unknown connector", admit(t, server, "CREATE", configMapKind, flowConfigMap("timr", validFunktionYml)), "unknown connector `timr`")
+	assertRejected(t, "unparseable funktion.yml", admit(t, server, "CREATE", configMapKind, flowConfigMap("timer", "flows: [")), "Failed to parse `funktion.yml`")
+	assertRejected(t, "unknown step kind", admit(t, server, "UPDATE", configMapKind, flowConfigMap("timer", `flows:
+- name: timer
+  steps:
+  - kind: endpiont
+    uri: timer://foo
+`)), "unknown kind `endpiont`")
+}
+
+func TestAdmissionCustomResources(t *testing.T) {
+	server := newTestAdmissionServer()
+	defer server.Close()
+
+	functionKind := GroupVersionKind{Group: spec.Group, Version: spec.Version, Kind: FunctionKind}
+	fn := &spec.Function{
+		ObjectMeta: v1.ObjectMeta{Name: "hello"},
+		Spec: spec.FunctionSpec{
+			Runtime: "nodejs",
+			Source:  "module.exports = function(context, callback) {}",
+		},
+	}
+	assertAllowed(t, "valid custom function", admit(t, server, "CREATE", functionKind, fn))
+	fn.Spec.Runtime = "python"
+	assertRejected(t, "custom function with unknown runtime", admit(t, server, "CREATE", functionKind, fn), "unknown runtime `python`")
+
+	flowKind := GroupVersionKind{Group: spec.Group, Version: spec.Version, Kind: FlowKind}
+	flow := &spec.Flow{
+		ObjectMeta: v1.ObjectMeta{Name: "timer-flow"},
+		Spec: spec.FlowSpec{
+			Connector: "timer",
+			Flows: []spec.FunktionFlow{
+				{
+					Steps: []spec.FunktionStep{
+						{Kind: spec.EndpointKind, URI: "timer://foo"},
+						{Kind: "log"},
+					},
+				},
+			},
+		},
+	}
+	assertRejected(t, "custom flow with unknown step kind", admit(t, server, "CREATE", flowKind, flow), "unknown kind `log`")
+}
+
+func TestAdmissionAllowsUnchangedUpdates(t *testing.T) {
+	server := newTestAdmissionServer()
+	defer server.Close()
+
+	// the runtime of the function has been deleted since it was created
+	old := functionConfigMap("nodjs", map[string]string{
+		SourceProperty: "module.exports = function(context, callback) {}",
+	})
+	cm := functionConfigMap("nodjs", map[string]string{
+		SourceProperty: "module.exports = function(context, callback) {}",
+	})
+	cm.Finalizers = []string{CleanupFinalizer}
+	cm.Annotations = map[string]string{StatusPhaseAnnotation: PhaseFailed}
+	assertAllowed(t, "status update", admitUpdate(t, server, "UPDATE", configMapKind, old, cm))
+
+	cm.Data[SourceProperty] = "module.exports = function(context, callback) { callback(200) }"
+	assertRejected(t, "source update", admitUpdate(t, server, "UPDATE", configMapKind, old, cm), "unknown runtime `nodjs`")
+
+	now := unversioned.Now()
+	cm.DeletionTimestamp = &now
+	cm.Finalizers = nil
+	assertAllowed(t, "finalizer release", admitUpdate(t, server, "UPDATE", configMapKind, old, cm))
+}
+
+func TestAdmissionIgnoresOtherResources(t *testing.T) {
+	server := newTestAdmissionServer()
+	defer server.Close()
+
+	other := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "default"},
+	}
+	assertAllowed(t, "unlabelled configmap", admit(t, server, "CREATE", configMapKind, other))
+	assertAllowed(t, "delete", admit(t, server, "DELETE", configMapKind, functionConfigMap("nodjs", nil)))
+}
+
+func TestAdmissionRejectsBadRequests(t *testing.T) {
+	server := newTestAdmissionServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d for a GET but got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d for a bad body but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
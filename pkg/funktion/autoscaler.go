@@ -0,0 +1,246 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	autoscaling "k8s.io/client-go/1.5/pkg/apis/autoscaling/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// MinReplicasProperty is the data key for the minimum number of replicas of an autoscaled Function
+	MinReplicasProperty = "minReplicas"
+	// MaxReplicasProperty is the data key for the maximum number of replicas of a Function.
+	// The Function is autoscaled when it is specified
+	MaxReplicasProperty = "maxReplicas"
+	// TargetCPUPercentageProperty is the data key for the average CPU utilization of the pods to scale at
+	TargetCPUPercentageProperty = "targetCPUPercentage"
+	// CustomMetricProperty is the data key for a `NAME=VALUE` custom metric and the average value per pod to scale at
+	CustomMetricProperty = "customMetric"
+
+	// AutoscalerKind is the kind of the HorizontalPodAutoscaler of a Function
+	AutoscalerKind = "HorizontalPodAutoscaler"
+
+	// customMetricsAnnotation is the annotation holding the custom metric targets of a HorizontalPodAutoscaler
+	customMetricsAnnotation = "alpha/target.custom-metrics.podautoscaler.kubernetes.io"
+)
+
+// autoscalingSettings holds the autoscaling properties of a Function
+type autoscalingSettings struct {
+	MinReplicas         int32
+	MaxReplicas         int32
+	TargetCPUPercentage int32
+	CustomMetricName    string
+	CustomMetricValue   string
+}
+
+// customMetricTargets is the value of the custom metrics annotation
+type customMetricTargets struct {
+	Items []customMetricTarget `json:"items"`
+}
+
+type customMetricTarget struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// autoscalingForFunction returns the autoscaling settings of the given Function or nil if it is not autoscaled
+func autoscalingForFunction(function *v1.ConfigMap) (*autoscalingSettings, error) {
+	data := function.Data
+	if len(data[MaxReplicasProperty]) == 0 {
+		for _, key := range []string{MinReplicasProperty, TargetCPUPercentageProperty, CustomMetricProperty} {
+			if len(data[key]) > 0 {
+				return nil, fmt.Errorf("Function %s has property `%s` without the property `%s`", function.Name, key, MaxReplicasProperty)
+			}
+		}
+		return nil, nil
+	}
+	settings := &autoscalingSettings{
+		MinReplicas: 1,
+	}
+	var err error
+	if settings.MaxReplicas, err = parseInt32Property(function, MaxReplicasProperty); err != nil {
+		return nil, err
+	}
+	if len(data[MinReplicasProperty]) > 0 {
+		if settings.MinReplicas, err = parseInt32Property(function, MinReplicasProperty); err != nil {
+			return nil, err
+		}
+	}
+	if settings.MinReplicas < 1 || settings.MaxReplicas < settings.MinReplicas {
+		return nil, fmt.Errorf("Function %s must have 1 <= `%s` <= `%s` but has %d and %d", function.Name, MinReplicasProperty, MaxReplicasProperty, settings.MinReplicas, settings.MaxReplicas)
+	}
+	if len(data[TargetCPUPercentageProperty]) > 0 {
+		if settings.TargetCPUPercentage, err = parseInt32Property(function, TargetCPUPercentageProperty); err != nil {
+			return nil, err
+		}
+		if settings.TargetCPUPercentage < 1 {
+			return nil, fmt.Errorf("Function %s must have a positive `%s`", function.Name, TargetCPUPercentageProperty)
+		}
+	}
+	if text := strings.TrimSpace(data[CustomMetricProperty]); len(text) > 0 {
+		pair := strings.SplitN(text, "=", 2)
+		if len(pair) != 2 || len(pair[0]) == 0 || len(pair[1]) == 0 {
+			return nil, fmt.Errorf("Function %s has invalid `%s`. Expecting `NAME=VALUE` but got: %s", function.Name, CustomMetricProperty, text)
+		}
+		settings.CustomMetricName = pair[0]
+		settings.CustomMetricValue = pair[1]
+	}
+	return settings, nil
+}
+
+func parseInt32Property(function *v1.ConfigMap, key string) (int32, error) {
+	value, err := strconv.ParseInt(strings.TrimSpace(function.Data[key]), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Function %s has invalid `%s`: %s", function.Name, key, err)
+	}
+	return int32(value), nil
+}
+
+// makeFunctionAutoscaler returns the HorizontalPodAutoscaler scaling the Deployment of the given Function
+func makeFunctionAutoscaler(function *v1.ConfigMap, settings *autoscalingSettings, deployment *v1beta1.Deployment) (*autoscaling.HorizontalPodAutoscaler, error) {
+	minReplicas := settings.MinReplicas
+	hpa := &autoscaling.HorizontalPodAutoscaler{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        function.Name,
+			Namespace:   function.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: autoscaling.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscaling.CrossVersionObjectReference{
+				APIVersion: "extensions/v1beta1",
+				Kind:       "Deployment",
+				Name:       deployment.Name,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: settings.MaxReplicas,
+		},
+	}
+	if settings.TargetCPUPercentage > 0 {
+		cpu := settings.TargetCPUPercentage
+		hpa.Spec.TargetCPUUtilizationPercentage = &cpu
+	}
+	if len(settings.CustomMetricName) > 0 {
+		data, err := json.Marshal(&customMetricTargets{
+			Items: []customMetricTarget{
+				{
+					Name:  settings.CustomMetricName,
+					Value: settings.CustomMetricValue,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		hpa.Annotations[customMetricsAnnotation] = string(data)
+	}
+	hpa.Labels[NameLabel] = function.Name
+	setManagedBy(&hpa.ObjectMeta, function)
+	setOwnerReference(&hpa.ObjectMeta, ownerReference(function))
+	return hpa, nil
+}
+
+// reconcileAutoscaler creates, updates or deletes the HorizontalPodAutoscaler of the given Function
+// depending on whether it has autoscaling settings
+func (c *Operator) reconcileAutoscaler(function *v1.ConfigMap, key string, deployment *v1beta1.Deployment) error {
+	settings, err := autoscalingForFunction(function)
+	if err != nil {
+		return err
+	}
+	hpaClient := c.kclient.Autoscaling().HorizontalPodAutoscalers(function.Namespace)
+	if settings == nil {
+		objs, err := managedBy(c.autoscalerInf, kindOf(function), key)
+		if err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			return nil
+		}
+		old := objs[0].(*autoscaling.HorizontalPodAutoscaler)
+		if !isOwnedBy(old.ObjectMeta, function) {
+			return nil
+		}
+		if err := hpaClient.Delete(old.Name, &api.DeleteOptions{}); err != nil {
+			c.recorder.Eventf(resourceReference(function), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete HorizontalPodAutoscaler %s: %s", old.Name, err)
+			return err
+		}
+		c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonDeleted, "Deleted HorizontalPodAutoscaler %s", old.Name)
+		return nil
+	}
+
+	old, name, err := c.findAutoscaler(function)
+	if err != nil {
+		return err
+	}
+	hpa, err := makeFunctionAutoscaler(function, settings, deployment)
+	if err != nil {
+		return fmt.Errorf("make autoscaler: %s", err)
+	}
+	hpa.Name = name
+	hash, err := specHash(hpa)
+	if err != nil {
+		return err
+	}
+	setSpecHash(&hpa.ObjectMeta, hash)
+	if old == nil {
+		if _, err := hpaClient.Create(hpa); err != nil {
+			return fmt.Errorf("create autoscaler: %s", err)
+		}
+		c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonCreated, "Created HorizontalPodAutoscaler %s", hpa.Name)
+		return nil
+	}
+	if hasSpecHash(old.ObjectMeta, hash) {
+		return nil
+	}
+	hpa.ResourceVersion = old.ResourceVersion
+	if _, err := hpaClient.Update(hpa); err != nil {
+		return err
+	}
+	c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonUpdated, "Updated HorizontalPodAutoscaler %s", hpa.Name)
+	return nil
+}
+
+// destroyAutoscaler removes the HorizontalPodAutoscaler of a deleted Function. Autoscalers owned by the
+// Function are left for the garbage collector unless they are still around after a timeout
+func (c *Operator) destroyAutoscaler(key string) error {
+	objs, err := managedBy(c.autoscalerInf, FunctionKind, key)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		c.orphans.forget(AutoscalerKind, key)
+		return nil
+	}
+	hpa := objs[0].(*autoscaling.HorizontalPodAutoscaler)
+	if c.awaitGarbageCollection(AutoscalerKind, key, FunctionKind, key, hpa.ObjectMeta) {
+		return nil
+	}
+	hpaClient := c.kclient.Autoscaling().HorizontalPodAutoscalers(hpa.Namespace)
+	if err := hpaClient.Delete(hpa.Name, &api.DeleteOptions{}); err != nil {
+		c.recorder.Eventf(keyReference(key), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete HorizontalPodAutoscaler %s: %s", hpa.Name, err)
+		return err
+	}
+	c.orphans.forget(AutoscalerKind, key)
+	c.recorder.Eventf(keyReference(key), v1.EventTypeNormal, ReasonDeleted, "Deleted HorizontalPodAutoscaler %s", hpa.Name)
+	return nil
+}
@@ -0,0 +1,93 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+func autoscaledFunction(data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "hello",
+			Namespace: "default",
+			UID:       "1234",
+		},
+		Data: data,
+	}
+}
+
+func TestAutoscalingNotConfigured(t *testing.T) {
+	settings, err := autoscalingForFunction(autoscaledFunction(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings != nil {
+		t.Errorf("expected no autoscaling settings but got %v", settings)
+	}
+}
+
+func TestAutoscalingInvalidSettings(t *testing.T) {
+	tests := []map[string]string{
+		{MinReplicasProperty: "2"},
+		{MaxReplicasProperty: "many"},
+		{MaxReplicasProperty: "2", MinReplicasProperty: "3"},
+		{MaxReplicasProperty: "2", MinReplicasProperty: "0"},
+		{MaxReplicasProperty: "2", TargetCPUPercentageProperty: "-10"},
+		{MaxReplicasProperty: "2", CustomMetricProperty: "qps"},
+	}
+	for _, data := range tests {
+		if _, err := autoscalingForFunction(autoscaledFunction(data)); err == nil {
+			t.Errorf("expected an error for %v", data)
+		}
+	}
+}
+
+func TestMakeFunctionAutoscaler(t *testing.T) {
+	function := autoscaledFunction(map[string]string{
+		MinReplicasProperty:         "2",
+		MaxReplicasProperty:         "10",
+		TargetCPUPercentageProperty: "80",
+		CustomMetricProperty:        "qps=20",
+	})
+	settings, err := autoscalingForFunction(function)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := &v1beta1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "hello"}}
+	hpa, err := makeFunctionAutoscaler(function, settings, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hpa.Spec.ScaleTargetRef.Kind != "Deployment" || hpa.Spec.ScaleTargetRef.Name != "hello" {
+		t.Errorf("expected the autoscaler to target Deployment hello but got %v", hpa.Spec.ScaleTargetRef)
+	}
+	if hpa.Spec.MinReplicas == nil || *hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 10 {
+		t.Errorf("expected 2 to 10 replicas but got %v to %d", hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas)
+	}
+	if hpa.Spec.TargetCPUUtilizationPercentage == nil || *hpa.Spec.TargetCPUUtilizationPercentage != 80 {
+		t.Errorf("expected a CPU target of 80 but got %v", hpa.Spec.TargetCPUUtilizationPercentage)
+	}
+	expected := `{"items":[{"name":"qps","value":"20"}]}`
+	if actual := hpa.Annotations[customMetricsAnnotation]; actual != expected {
+		t.Errorf("expected custom metrics annotation %s but got %s", expected, actual)
+	}
+	if !isOwnedBy(hpa.ObjectMeta, function) {
+		t.Errorf("expected the autoscaler to be owned by the function")
+	}
+}
@@ -15,40 +15,286 @@
 package funktion
 
 import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/funktionio/funktion/pkg/client"
+	"github.com/funktionio/funktion/pkg/k8sutil"
+
 	"k8s.io/client-go/1.5/kubernetes"
 	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/v1"
 	"k8s.io/client-go/1.5/pkg/labels"
 	"k8s.io/client-go/1.5/pkg/runtime"
+	utilruntime "k8s.io/client-go/1.5/pkg/util/runtime"
 	"k8s.io/client-go/1.5/pkg/watch"
 	"k8s.io/client-go/1.5/tools/cache"
 )
 
-// NewConfigMapListWatch returns a new ListWatch for ConfigMaps with the given listOptions
-func NewConfigMapListWatch(client *kubernetes.Clientset, listOpts api.ListOptions) *cache.ListWatch {
-	configMaps := client.ConfigMaps(api.NamespaceAll)
-
-	return &cache.ListWatch{
-		ListFunc: func(options api.ListOptions) (runtime.Object, error) {
-			return configMaps.List(listOpts)
+// NewResourceListWatch returns a new ListWatch for the ConfigMap form of the resources of the given kind
+// in the given namespaces or all namespaces if none are specified. The ConfigMaps matching listOpts are
+// combined with the custom resources of the kind unless tclient is nil.
+//
+// A resource being migrated between a ConfigMap and a custom resource exists in both forms for a while.
+// As both forms share the same key the deletion of one form is ignored while the other form still exists
+// so that the informer does not lose the resource and the operator keeps its Deployment and Service.
+// Which forms exist is tracked from the lists and watches of each form rather than asking the API server
+func NewResourceListWatch(kclient *kubernetes.Clientset, tclient *client.Client, kind string, listOpts api.ListOptions, namespaces []string) cache.ListerWatcher {
+	if tclient == nil {
+		return k8sutil.NewNamespacedListWatch(namespaces, listOpts,
+			func(ns string, options api.ListOptions) (runtime.Object, error) {
+				return kclient.ConfigMaps(ns).List(options)
+			},
+			func(ns string, options api.ListOptions) (watch.Interface, error) {
+				return kclient.ConfigMaps(ns).Watch(options)
+			},
+		)
+	}
+	configMaps := &resourceForm{}
+	customResources := &resourceForm{}
+	lws := k8sutil.NamespacedListWatches(namespaces, listOpts,
+		func(ns string, options api.ListOptions) (runtime.Object, error) {
+			list, err := kclient.ConfigMaps(ns).List(options)
+			if err == nil {
+				configMaps.listed(ns, list)
+			}
+			return list, err
+		},
+		func(ns string, options api.ListOptions) (watch.Interface, error) {
+			w, err := kclient.ConfigMaps(ns).Watch(options)
+			if err != nil {
+				return w, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				return in, configMaps.observe(in, customResources)
+			}), nil
 		},
-		WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
-			return configMaps.Watch(listOpts)
+	)
+	lws = append(lws, k8sutil.NamespacedListWatches(namespaces, api.ListOptions{},
+		func(ns string, options api.ListOptions) (runtime.Object, error) {
+			list, err := ListCustomResources(tclient, kind, ns, options)
+			if err == nil {
+				customResources.listed(ns, list)
+			}
+			return list, err
 		},
+		func(ns string, options api.ListOptions) (watch.Interface, error) {
+			w, err := watchCustomResources(tclient, kind, ns, options)
+			if err != nil {
+				return w, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				return in, customResources.observe(in, configMaps)
+			}), nil
+		},
+	)...)
+	return k8sutil.NewMultiListWatch(lws...)
+}
+
+// resourceForm tracks the keys of the resources of a kind which exist in one form, either as label based
+// ConfigMaps or as custom resources, as seen by the list and watch of that form
+type resourceForm struct {
+	lock sync.Mutex
+	keys map[string]bool
+}
+
+// listed replaces the keys in the given namespace, or all namespaces if empty, with the listed resources
+func (f *resourceForm) listed(ns string, list *v1.ConfigMapList) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.keys == nil {
+		f.keys = map[string]bool{}
+	}
+	for key := range f.keys {
+		if len(ns) == 0 || strings.HasPrefix(key, ns+"/") {
+			delete(f.keys, key)
+		}
+	}
+	for _, cm := range list.Items {
+		f.keys[referenceKey(cm.Namespace, cm.Name)] = true
+	}
+}
+
+// observe records the watch event of this form returning false if it is the deletion of a resource
+// which still exists in the other form and should therefore not reach the informer
+func (f *resourceForm) observe(in watch.Event, other *resourceForm) bool {
+	cm, ok := in.Object.(*v1.ConfigMap)
+	if !ok {
+		return true
+	}
+	key := referenceKey(cm.Namespace, cm.Name)
+	f.lock.Lock()
+	if f.keys == nil {
+		f.keys = map[string]bool{}
+	}
+	switch in.Type {
+	case watch.Added, watch.Modified:
+		f.keys[key] = true
+	case watch.Deleted:
+		delete(f.keys, key)
+	}
+	f.lock.Unlock()
+	return in.Type != watch.Deleted || !other.exists(key)
+}
+
+func (f *resourceForm) exists(key string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.keys[key]
+}
+
+// ListCustomResources lists the custom resources of the given kind in their ConfigMap form
+func ListCustomResources(tclient *client.Client, kind string, ns string, options api.ListOptions) (*v1.ConfigMapList, error) {
+	items := []interface{}{}
+	answer := &v1.ConfigMapList{}
+	switch kind {
+	case FunctionKind:
+		list, err := tclient.Functions(ns).List(options)
+		if err != nil {
+			return nil, err
+		}
+		answer.ResourceVersion = list.ResourceVersion
+		for _, item := range list.Items {
+			items = append(items, item)
+		}
+	case FlowKind:
+		list, err := tclient.Flows(ns).List(options)
+		if err != nil {
+			return nil, err
+		}
+		answer.ResourceVersion = list.ResourceVersion
+		for _, item := range list.Items {
+			items = append(items, item)
+		}
+	case RuntimeKind:
+		list, err := tclient.Runtimes(ns).List(options)
+		if err != nil {
+			return nil, err
+		}
+		answer.ResourceVersion = list.ResourceVersion
+		for _, item := range list.Items {
+			items = append(items, item)
+		}
+	case ConnectorKind:
+		list, err := tclient.Connectors(ns).List(options)
+		if err != nil {
+			return nil, err
+		}
+		answer.ResourceVersion = list.ResourceVersion
+		for _, item := range list.Items {
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown kind %s", kind)
+	}
+	for _, item := range items {
+		cm, err := ToConfigMap(item)
+		if err != nil {
+			return nil, err
+		}
+		answer.Items = append(answer.Items, *cm)
+	}
+	return answer, nil
+}
+
+// watchCustomResources watches the custom resources of the given kind converting them to their ConfigMap form
+func watchCustomResources(tclient *client.Client, kind string, ns string, options api.ListOptions) (watch.Interface, error) {
+	var w watch.Interface
+	var err error
+	switch kind {
+	case FunctionKind:
+		w, err = tclient.Functions(ns).Watch(options)
+	case FlowKind:
+		w, err = tclient.Flows(ns).Watch(options)
+	case RuntimeKind:
+		w, err = tclient.Runtimes(ns).Watch(options)
+	case ConnectorKind:
+		w, err = tclient.Connectors(ns).Watch(options)
+	default:
+		err = fmt.Errorf("Unknown kind %s", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		if in.Type == watch.Error {
+			return in, true
+		}
+		cm, err := ToConfigMap(in.Object)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("failed to convert %s: %v", kind, err))
+			return in, false
+		}
+		in.Object = cm
+		return in, true
+	}), nil
+}
+
+// customResourceExists returns true if there is a custom resource of the given kind, namespace and name
+func customResourceExists(tclient *client.Client, kind string, ns string, name string) bool {
+	var err error
+	switch kind {
+	case FunctionKind:
+		_, err = tclient.Functions(ns).Get(name)
+	case FlowKind:
+		_, err = tclient.Flows(ns).Get(name)
+	case RuntimeKind:
+		_, err = tclient.Runtimes(ns).Get(name)
+	case ConnectorKind:
+		_, err = tclient.Connectors(ns).Get(name)
+	default:
+		return false
 	}
+	return err == nil
 }
 
-// NewServiceListWatch creates a watch on services
-func NewServiceListWatch(client *kubernetes.Clientset) *cache.ListWatch {
-	listOpts := api.ListOptions{}
-	services := client.Services(api.NamespaceAll)
-	return &cache.ListWatch{
-		ListFunc: func(options api.ListOptions) (runtime.Object, error) {
-			return services.List(listOpts)
+// NewServiceListWatch creates a watch on the services matching the list options in the given namespaces or all namespaces if none are specified
+func NewServiceListWatch(client *kubernetes.Clientset, listOpts api.ListOptions, namespaces []string) cache.ListerWatcher {
+	return k8sutil.NewNamespacedListWatch(namespaces, listOpts,
+		func(ns string, options api.ListOptions) (runtime.Object, error) {
+			return client.Services(ns).List(options)
 		},
-		WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
-			return services.Watch(listOpts)
+		func(ns string, options api.ListOptions) (watch.Interface, error) {
+			return client.Services(ns).Watch(options)
 		},
-	}
+	)
+}
+
+// NewAutoscalerListWatch creates a watch on the HorizontalPodAutoscalers matching the list options in the given namespaces or all namespaces if none are specified
+func NewAutoscalerListWatch(client *kubernetes.Clientset, listOpts api.ListOptions, namespaces []string) cache.ListerWatcher {
+	return k8sutil.NewNamespacedListWatch(namespaces, listOpts,
+		func(ns string, options api.ListOptions) (runtime.Object, error) {
+			return client.Autoscaling().HorizontalPodAutoscalers(ns).List(options)
+		},
+		func(ns string, options api.ListOptions) (watch.Interface, error) {
+			return client.Autoscaling().HorizontalPodAutoscalers(ns).Watch(options)
+		},
+	)
+}
+
+// NewDeploymentListWatch creates a watch on the deployments matching the list options in the given namespaces or all namespaces if none are specified
+func NewDeploymentListWatch(client *kubernetes.Clientset, listOpts api.ListOptions, namespaces []string) cache.ListerWatcher {
+	return k8sutil.NewNamespacedListWatch(namespaces, listOpts,
+		func(ns string, options api.ListOptions) (runtime.Object, error) {
+			return client.Extensions().Deployments(ns).List(options)
+		},
+		func(ns string, options api.ListOptions) (watch.Interface, error) {
+			return client.Extensions().Deployments(ns).Watch(options)
+		},
+	)
+}
+
+// NewRevisionListWatch creates a watch on the snapshot ConfigMaps of Function revisions matching the list options in the given namespaces or all namespaces if none are specified
+func NewRevisionListWatch(client *kubernetes.Clientset, listOpts api.ListOptions, namespaces []string) cache.ListerWatcher {
+	return k8sutil.NewNamespacedListWatch(namespaces, listOpts,
+		func(ns string, options api.ListOptions) (runtime.Object, error) {
+			return client.ConfigMaps(ns).List(options)
+		},
+		func(ns string, options api.ListOptions) (watch.Interface, error) {
+			return client.ConfigMaps(ns).Watch(options)
+		},
+	)
 }
 
 // CreateFlowListOptions returns the default selector for Flow resources
@@ -82,3 +328,25 @@ func createKindListOptions(kind string) (*api.ListOptions, error) {
 	}
 	return &listOpts, nil
 }
+
+// CreateManagedListOptions returns the selector for the resources the operator creates for Functions and Flows
+func CreateManagedListOptions() (*api.ListOptions, error) {
+	selector, err := labels.Parse(ManagedByLabel + "=" + ManagedByOperator)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListOptions{
+		LabelSelector: selector,
+	}, nil
+}
+
+// CreateRevisionListOptions returns the selector for the snapshot ConfigMaps of Function revisions
+func CreateRevisionListOptions() (*api.ListOptions, error) {
+	selector, err := labels.Parse(fmt.Sprintf("%s,%s", NameLabel, RevisionLabel))
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListOptions{
+		LabelSelector: selector,
+	}, nil
+}
@@ -0,0 +1,48 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/watch"
+)
+
+func TestResourceFormIgnoresDeletionWhileOtherFormExists(t *testing.T) {
+	configMaps := &resourceForm{}
+	customResources := &resourceForm{}
+	function := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	configMaps.listed("", &v1.ConfigMapList{Items: []v1.ConfigMap{*function}})
+
+	// lets migrate the Function to a custom resource
+	if !customResources.observe(watch.Event{Type: watch.Added, Object: function}, configMaps) {
+		t.Errorf("expected the custom resource to be added")
+	}
+	if configMaps.observe(watch.Event{Type: watch.Deleted, Object: function}, customResources) {
+		t.Errorf("expected the deletion of the ConfigMap to be ignored while the custom resource exists")
+	}
+	if !customResources.observe(watch.Event{Type: watch.Deleted, Object: function}, configMaps) {
+		t.Errorf("expected the deletion of the last form to reach the informer")
+	}
+
+	// a relist forgets the resources which are gone
+	customResources.listed("default", &v1.ConfigMapList{Items: []v1.ConfigMap{*function}})
+	customResources.listed("default", &v1.ConfigMapList{})
+	configMaps.observe(watch.Event{Type: watch.Added, Object: function}, customResources)
+	if !configMaps.observe(watch.Event{Type: watch.Deleted, Object: function}, customResources) {
+		t.Errorf("expected the deletion to reach the informer once the custom resource was relisted without it")
+	}
+}
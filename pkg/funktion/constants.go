@@ -61,4 +61,9 @@ const (
 	SourceMountPathProperty = "sourceMountPath"
 
 	resyncPeriod = 30 * time.Second
+
+	// retryBaseDelay is the delay before retrying a resource which failed to reconcile
+	retryBaseDelay = 1 * time.Second
+	// retryMaxDelay is the maximum delay between retries of a resource which keeps failing
+	retryMaxDelay = 5 * time.Minute
 )
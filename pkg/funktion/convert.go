@@ -0,0 +1,485 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/funktionio/funktion/pkg/spec"
+	"github.com/ghodss/yaml"
+
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+// The operator and CLI work with the ConfigMap form of every funktion resource so that the
+// label based ConfigMaps and the custom resources can be used side by side. The ConfigMap form
+// of a custom resource has the apiVersion of the custom resources and is never stored itself
+
+var statusAnnotations = []string{
+	StatusPhaseAnnotation,
+	StatusMessageAnnotation,
+	StatusObservedVersionAnnotation,
+	StatusDeploymentAnnotation,
+	StatusServiceAnnotation,
+}
+
+// IsCustomResource returns true if the given ConfigMap is the ConfigMap form of a custom resource
+func IsCustomResource(cm *v1.ConfigMap) bool {
+	return cm.APIVersion == spec.APIVersion
+}
+
+// ToConfigMap returns the ConfigMap form of the given custom resource
+func ToConfigMap(obj interface{}) (*v1.ConfigMap, error) {
+	switch o := obj.(type) {
+	case *spec.Function:
+		return FunctionToConfigMap(o)
+	case *spec.Flow:
+		return FlowToConfigMap(o)
+	case *spec.Runtime:
+		return RuntimeToConfigMap(o)
+	case *spec.Connector:
+		return ConnectorToConfigMap(o)
+	default:
+		return nil, fmt.Errorf("Unknown custom resource type %T", obj)
+	}
+}
+
+// FromConfigMap returns the custom resource for the given ConfigMap form of a Function, Flow, Runtime or Connector
+func FromConfigMap(cm *v1.ConfigMap) (interface{}, error) {
+	switch kind := cm.Labels[KindLabel]; kind {
+	case FunctionKind:
+		return ConfigMapToFunction(cm)
+	case FlowKind:
+		return ConfigMapToFlow(cm)
+	case RuntimeKind:
+		return ConfigMapToRuntime(cm)
+	case ConnectorKind:
+		return ConfigMapToConnector(cm)
+	default:
+		return nil, fmt.Errorf("ConfigMap %s has unknown %s label `%s`", cm.Name, KindLabel, kind)
+	}
+}
+
+// FunctionToConfigMap returns the ConfigMap form of the given Function
+func FunctionToConfigMap(fn *spec.Function) (*v1.ConfigMap, error) {
+	cm := newConfigMapForm(FunctionKind, fn.ObjectMeta)
+	cm.Labels[RuntimeLabel] = fn.Spec.Runtime
+	setStatusAnnotations(cm.Annotations, statusFromSpec(fn.Status))
+	cm.Data[SourceProperty] = fn.Spec.Source
+	SetFunctionFiles(cm.Data, fn.Spec.Files)
+	if fn.Spec.Debug {
+		cm.Data[DebugProperty] = "true"
+	}
+	if len(fn.Spec.Env) > 0 {
+		cm.Data[EnvVarsProperty] = formatEnvVars(fn.Spec.Env)
+	}
+	if a := fn.Spec.Autoscaling; a != nil {
+		cm.Data[MaxReplicasProperty] = strconv.Itoa(int(a.MaxReplicas))
+		if a.MinReplicas > 0 {
+			cm.Data[MinReplicasProperty] = strconv.Itoa(int(a.MinReplicas))
+		}
+		if a.TargetCPUPercentage > 0 {
+			cm.Data[TargetCPUPercentageProperty] = strconv.Itoa(int(a.TargetCPUPercentage))
+		}
+		if a.CustomMetric != nil {
+			cm.Data[CustomMetricProperty] = a.CustomMetric.Name + "=" + a.CustomMetric.TargetValue
+		}
+	}
+	setOrRemoveData(cm.Data, IdleTimeoutProperty, fn.Spec.IdleTimeout)
+	if len(fn.Spec.Traffic) > 0 {
+		traffic := []TrafficTarget{}
+		for _, t := range fn.Spec.Traffic {
+			traffic = append(traffic, TrafficTarget{Revision: t.Revision, Percent: t.Percent})
+		}
+		cm.Data[TrafficProperty] = FormatTraffic(traffic)
+	}
+	if fn.Spec.Replicas != nil {
+		cm.Data[ReplicasProperty] = strconv.Itoa(int(*fn.Spec.Replicas))
+	}
+	if r := fn.Spec.Resources; r != nil {
+		setQuantityData(cm.Data, CPURequestProperty, r.Requests, v1.ResourceCPU)
+		setQuantityData(cm.Data, CPULimitProperty, r.Limits, v1.ResourceCPU)
+		setQuantityData(cm.Data, MemoryRequestProperty, r.Requests, v1.ResourceMemory)
+		setQuantityData(cm.Data, MemoryLimitProperty, r.Limits, v1.ResourceMemory)
+	}
+	if len(fn.Spec.NodeSelector) > 0 {
+		lines := []string{}
+		for k, v := range fn.Spec.NodeSelector {
+			lines = append(lines, k+"="+v)
+		}
+		sort.Strings(lines)
+		cm.Data[NodeSelectorProperty] = strings.Join(lines, "\n")
+	}
+	if len(fn.Spec.Tolerations) > 0 {
+		if err := setYamlData(cm.Data, TolerationsProperty, fn.Spec.Tolerations); err != nil {
+			return nil, err
+		}
+	}
+	if fn.Spec.Affinity != nil {
+		if err := setYamlData(cm.Data, AffinityProperty, fn.Spec.Affinity); err != nil {
+			return nil, err
+		}
+	}
+	if len(fn.Spec.ImagePullSecrets) > 0 {
+		names := []string{}
+		for _, secret := range fn.Spec.ImagePullSecrets {
+			names = append(names, secret.Name)
+		}
+		cm.Data[ImagePullSecretsProperty] = strings.Join(names, ",")
+	}
+	return cm, nil
+}
+
+// ConfigMapToFunction returns the Function for the given ConfigMap form
+func ConfigMapToFunction(cm *v1.ConfigMap) (*spec.Function, error) {
+	fn := &spec.Function{
+		ObjectMeta: customObjectMeta(cm),
+		Spec: spec.FunctionSpec{
+			Runtime:     cm.Labels[RuntimeLabel],
+			Source:      cm.Data[SourceProperty],
+			Debug:       strings.ToLower(cm.Data[DebugProperty]) == "true",
+			Env:         parseEnvVars(cm.Data[EnvVarsProperty]),
+			IdleTimeout: cm.Data[IdleTimeoutProperty],
+		},
+		Status: statusToSpec(GetStatus(cm)),
+	}
+	if len(fn.Spec.Env) == 0 {
+		fn.Spec.Env = nil
+	}
+	traffic, err := ParseTraffic(cm.Data[TrafficProperty])
+	if err != nil {
+		return nil, fmt.Errorf("Function %s: %s", cm.Name, err)
+	}
+	for _, t := range traffic {
+		fn.Spec.Traffic = append(fn.Spec.Traffic, spec.TrafficTarget{Revision: t.Revision, Percent: t.Percent})
+	}
+	settings, err := autoscalingForFunction(cm)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil {
+		fn.Spec.Autoscaling = &spec.AutoscalingSpec{
+			MinReplicas:         settings.MinReplicas,
+			MaxReplicas:         settings.MaxReplicas,
+			TargetCPUPercentage: settings.TargetCPUPercentage,
+		}
+		if len(settings.CustomMetricName) > 0 {
+			fn.Spec.Autoscaling.CustomMetric = &spec.CustomMetricSpec{
+				Name:        settings.CustomMetricName,
+				TargetValue: settings.CustomMetricValue,
+			}
+		}
+	}
+	fn.Spec.Replicas, err = replicasForFunction(cm)
+	if err != nil {
+		return nil, err
+	}
+	if len(cm.Data[FilesProperty]) > 0 {
+		fn.Spec.Files, err = GetFunctionFiles(cm.Data)
+		if err != nil {
+			return nil, fmt.Errorf("Function %s: %s", cm.Name, err)
+		}
+	}
+	overrides, err := podOverridesForFunction(cm.Name, cm.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides.Requests) > 0 || len(overrides.Limits) > 0 {
+		fn.Spec.Resources = &v1.ResourceRequirements{}
+		if len(overrides.Requests) > 0 {
+			fn.Spec.Resources.Requests = overrides.Requests
+		}
+		if len(overrides.Limits) > 0 {
+			fn.Spec.Resources.Limits = overrides.Limits
+		}
+	}
+	fn.Spec.NodeSelector = overrides.NodeSelector
+	fn.Spec.Tolerations = overrides.Tolerations
+	fn.Spec.Affinity = overrides.Affinity
+	fn.Spec.ImagePullSecrets = overrides.ImagePullSecrets
+	return fn, nil
+}
+
+// FlowToConfigMap returns the ConfigMap form of the given Flow
+func FlowToConfigMap(flow *spec.Flow) (*v1.ConfigMap, error) {
+	cm := newConfigMapForm(FlowKind, flow.ObjectMeta)
+	cm.Labels[ConnectorLabel] = flow.Spec.Connector
+	setStatusAnnotations(cm.Annotations, statusFromSpec(flow.Status))
+	if len(flow.Spec.Flows) > 0 {
+		data, err := yaml.Marshal(&spec.FunkionConfig{Flows: flow.Spec.Flows})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshal the flows of Flow %s: %v", flow.Name, err)
+		}
+		cm.Data[FunktionYmlProperty] = string(data)
+	}
+	setOrRemoveData(cm.Data, ApplicationPropertiesProperty, flow.Spec.ApplicationProperties)
+	setOrRemoveData(cm.Data, ApplicationYmlProperty, flow.Spec.ApplicationYml)
+	return cm, nil
+}
+
+// ConfigMapToFlow returns the Flow for the given ConfigMap form
+func ConfigMapToFlow(cm *v1.ConfigMap) (*spec.Flow, error) {
+	flow := &spec.Flow{
+		ObjectMeta: customObjectMeta(cm),
+		Spec: spec.FlowSpec{
+			Connector:             cm.Labels[ConnectorLabel],
+			ApplicationProperties: cm.Data[ApplicationPropertiesProperty],
+			ApplicationYml:        cm.Data[ApplicationYmlProperty],
+		},
+		Status: statusToSpec(GetStatus(cm)),
+	}
+	if text := cm.Data[FunktionYmlProperty]; len(text) > 0 {
+		config := spec.FunkionConfig{}
+		if err := yaml.Unmarshal([]byte(text), &config); err != nil {
+			return nil, fmt.Errorf("Failed to parse YAML from property `%s` on the Flow ConfigMap %s. Error: %s", FunktionYmlProperty, cm.Name, err)
+		}
+		flow.Spec.Flows = config.Flows
+	}
+	return flow, nil
+}
+
+// RuntimeToConfigMap returns the ConfigMap form of the given Runtime
+func RuntimeToConfigMap(rt *spec.Runtime) (*v1.ConfigMap, error) {
+	cm := newConfigMapForm(RuntimeKind, rt.ObjectMeta)
+	if err := setYamlData(cm.Data, DeploymentProperty, rt.Spec.Deployment); err != nil {
+		return nil, err
+	}
+	if rt.Spec.DebugDeployment != nil {
+		if err := setYamlData(cm.Data, DeploymentDebugProperty, rt.Spec.DebugDeployment); err != nil {
+			return nil, err
+		}
+	}
+	if rt.Spec.Service != nil {
+		if err := setYamlData(cm.Data, ServiceProperty, rt.Spec.Service); err != nil {
+			return nil, err
+		}
+	}
+	if rt.Spec.DebugPort > 0 {
+		cm.Data[DebugPortProperty] = strconv.Itoa(rt.Spec.DebugPort)
+	}
+	setOrRemoveData(cm.Data, FileExtensionsProperty, strings.Join(rt.Spec.FileExtensions, ","))
+	setOrRemoveData(cm.Data, SourceMountPathProperty, rt.Spec.SourceMountPath)
+	setOrRemoveData(cm.Data, IdleTimeoutProperty, rt.Spec.IdleTimeout)
+	setOrRemoveData(cm.Data, DependencyFilesProperty, strings.Join(rt.Spec.DependencyFiles, ","))
+	setOrRemoveData(cm.Data, DependencyInstallCommandProperty, rt.Spec.DependencyInstallCommand)
+	setOrRemoveData(cm.Data, DependencyInstallImageProperty, rt.Spec.DependencyInstallImage)
+	return cm, nil
+}
+
+// ConfigMapToRuntime returns the Runtime for the given ConfigMap form
+func ConfigMapToRuntime(cm *v1.ConfigMap) (*spec.Runtime, error) {
+	rt := &spec.Runtime{
+		ObjectMeta: customObjectMeta(cm),
+		Spec: spec.RuntimeSpec{
+			SourceMountPath:          cm.Data[SourceMountPathProperty],
+			IdleTimeout:              cm.Data[IdleTimeoutProperty],
+			DependencyInstallCommand: cm.Data[DependencyInstallCommandProperty],
+			DependencyInstallImage:   cm.Data[DependencyInstallImageProperty],
+		},
+	}
+	if text := cm.Data[DeploymentProperty]; len(text) > 0 {
+		rt.Spec.Deployment = &v1beta1.Deployment{}
+		if err := parseYamlData(cm, DeploymentProperty, rt.Spec.Deployment); err != nil {
+			return nil, err
+		}
+	}
+	if text := cm.Data[DeploymentDebugProperty]; len(text) > 0 {
+		rt.Spec.DebugDeployment = &v1beta1.Deployment{}
+		if err := parseYamlData(cm, DeploymentDebugProperty, rt.Spec.DebugDeployment); err != nil {
+			return nil, err
+		}
+	}
+	if text := cm.Data[ServiceProperty]; len(text) > 0 {
+		rt.Spec.Service = &v1.Service{}
+		if err := parseYamlData(cm, ServiceProperty, rt.Spec.Service); err != nil {
+			return nil, err
+		}
+	}
+	if text := cm.Data[DebugPortProperty]; len(text) > 0 {
+		port, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to convert property `%s` value `%s` on the Runtime ConfigMap %s to a number: %v", DebugPortProperty, text, cm.Name, err)
+		}
+		rt.Spec.DebugPort = port
+	}
+	if text := cm.Data[FileExtensionsProperty]; len(text) > 0 {
+		rt.Spec.FileExtensions = strings.Split(text, ",")
+	}
+	if text := cm.Data[DependencyFilesProperty]; len(text) > 0 {
+		rt.Spec.DependencyFiles = strings.Split(text, ",")
+	}
+	return rt, nil
+}
+
+// ConnectorToConfigMap returns the ConfigMap form of the given Connector
+func ConnectorToConfigMap(connector *spec.Connector) (*v1.ConfigMap, error) {
+	cm := newConfigMapForm(ConnectorKind, connector.ObjectMeta)
+	if err := setYamlData(cm.Data, DeploymentYmlProperty, connector.Spec.Deployment); err != nil {
+		return nil, err
+	}
+	if connector.Spec.Service != nil {
+		if err := setYamlData(cm.Data, ServiceYmlProperty, connector.Spec.Service); err != nil {
+			return nil, err
+		}
+	}
+	if connector.Spec.Schema != nil {
+		if err := setYamlData(cm.Data, SchemaYmlProperty, connector.Spec.Schema); err != nil {
+			return nil, err
+		}
+	}
+	setOrRemoveData(cm.Data, ApplicationPropertiesProperty, connector.Spec.ApplicationProperties)
+	return cm, nil
+}
+
+// ConfigMapToConnector returns the Connector for the given ConfigMap form
+func ConfigMapToConnector(cm *v1.ConfigMap) (*spec.Connector, error) {
+	connector := &spec.Connector{
+		ObjectMeta: customObjectMeta(cm),
+		Spec: spec.ConnectorSpec{
+			ApplicationProperties: cm.Data[ApplicationPropertiesProperty],
+		},
+	}
+	if text := cm.Data[DeploymentYmlProperty]; len(text) > 0 {
+		connector.Spec.Deployment = &v1beta1.Deployment{}
+		if err := parseYamlData(cm, DeploymentYmlProperty, connector.Spec.Deployment); err != nil {
+			return nil, err
+		}
+	}
+	if text := cm.Data[ServiceYmlProperty]; len(text) > 0 {
+		connector.Spec.Service = &v1.Service{}
+		if err := parseYamlData(cm, ServiceYmlProperty, connector.Spec.Service); err != nil {
+			return nil, err
+		}
+	}
+	if text := cm.Data[SchemaYmlProperty]; len(text) > 0 {
+		schema, err := LoadConnectorSchema([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load property `%s` on the Connector ConfigMap %s: %v", SchemaYmlProperty, cm.Name, err)
+		}
+		connector.Spec.Schema = schema
+	}
+	return connector, nil
+}
+
+// newConfigMapForm returns a ConfigMap with a copy of the metadata of the custom resource
+func newConfigMapForm(kind string, objectMeta v1.ObjectMeta) *v1.ConfigMap {
+	cm := &v1.ConfigMap{
+		ObjectMeta: objectMeta,
+		Data:       map[string]string{},
+	}
+	cm.Kind = kind
+	cm.APIVersion = spec.APIVersion
+	cm.Labels = copyStringMap(objectMeta.Labels)
+	cm.Annotations = copyStringMap(objectMeta.Annotations)
+	cm.Labels[KindLabel] = kind
+	return cm
+}
+
+// customObjectMeta returns a copy of the metadata of the ConfigMap form without the
+// kind label and status annotations which are held elsewhere on a custom resource
+func customObjectMeta(cm *v1.ConfigMap) v1.ObjectMeta {
+	objectMeta := cm.ObjectMeta
+	objectMeta.Labels = copyStringMap(cm.Labels)
+	objectMeta.Annotations = copyStringMap(cm.Annotations)
+	delete(objectMeta.Labels, KindLabel)
+	for _, key := range statusAnnotations {
+		delete(objectMeta.Annotations, key)
+	}
+	if !IsCustomResource(cm) {
+		// a ConfigMap being converted to a new custom resource
+		objectMeta.UID = ""
+		objectMeta.ResourceVersion = ""
+		objectMeta.SelfLink = ""
+		objectMeta.Generation = 0
+		objectMeta.CreationTimestamp = unversioned.Time{}
+	}
+	return objectMeta
+}
+
+func statusToSpec(status Status) spec.ResourceStatus {
+	return spec.ResourceStatus{
+		Phase:                   status.Phase,
+		Message:                 status.Message,
+		ObservedResourceVersion: status.ObservedResourceVersion,
+		Deployment:              status.Deployment,
+		Service:                 status.Service,
+	}
+}
+
+func statusFromSpec(status spec.ResourceStatus) Status {
+	return Status{
+		Phase:                   status.Phase,
+		Message:                 status.Message,
+		ObservedResourceVersion: status.ObservedResourceVersion,
+		Deployment:              status.Deployment,
+		Service:                 status.Service,
+	}
+}
+
+// formatEnvVars formats the environment variables as the NAME=VALUE lines parsed by parseEnvVars.
+// Only values from Secret and ConfigMap keys can be referenced so any other sources are ignored
+func formatEnvVars(envVars []v1.EnvVar) string {
+	lines := []string{}
+	for _, envVar := range envVars {
+		if line, ok := FormatEnvVar(envVar); ok {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// setQuantityData sets the data key to the quantity of the given resource if it is in the list
+func setQuantityData(data map[string]string, key string, list v1.ResourceList, name v1.ResourceName) {
+	if quantity, ok := list[name]; ok {
+		data[key] = quantity.String()
+	}
+}
+
+func setYamlData(data map[string]string, key string, obj interface{}) error {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal property `%s`: %v", key, err)
+	}
+	data[key] = string(b)
+	return nil
+}
+
+func parseYamlData(cm *v1.ConfigMap, key string, obj interface{}) error {
+	if err := yaml.Unmarshal([]byte(cm.Data[key]), obj); err != nil {
+		return fmt.Errorf("Failed to parse YAML from property `%s` on the %s ConfigMap %s. Error: %s", key, cm.Labels[KindLabel], cm.Name, err)
+	}
+	return nil
+}
+
+func setOrRemoveData(data map[string]string, key string, value string) {
+	if len(value) == 0 {
+		delete(data, key)
+	} else {
+		data[key] = value
+	}
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	answer := map[string]string{}
+	for k, v := range m {
+		answer[k] = v
+	}
+	return answer
+}
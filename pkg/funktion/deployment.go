@@ -16,6 +16,7 @@ package funktion
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/ghodss/yaml"
@@ -32,6 +33,9 @@ const (
 	// SchemaYmlProperty data key for the schema (JSON schema as YAML) file
 	SchemaYmlProperty = "schema.yml"
 
+	// ServiceYmlProperty data key for the optional service yaml file of connectors which accept inbound HTTP
+	ServiceYmlProperty = "service.yml"
+
 	// Flow
 
 	// FunktionYmlProperty the data key for the funktion yaml file
@@ -49,7 +53,8 @@ const (
 	SourceProperty = "source"
 	// DebugProperty is the data key for whether to enable debugging in a Function ConfigMap
 	DebugProperty = "debug"
-	// EnvVarsProperty represents a newline terminated list of NAME=VALUE expressions for environment variables
+	// EnvVarsProperty represents a newline terminated list of NAME=VALUE expressions for environment variables.
+	// The VALUE may reference the key of a Secret or ConfigMap using `secret:NAME/KEY` or `configmap:NAME/KEY`
 	EnvVarsProperty = "envVars"
 
 	// ExposeLabel is the label key to expose services
@@ -66,7 +71,8 @@ const (
 	// DebugPortProperty is the data key for a Runtime's debug port
 	DebugPortProperty = "debugPort"
 
-	// ConfigMapControllerAnnotation is the annotation for the configmapcontroller
+	// ConfigMapControllerAnnotation is the annotation for the configmapcontroller. The operator no longer
+	// sets it as it rolls the pods itself using the ConfigChecksumAnnotation
 	ConfigMapControllerAnnotation = "configmap.fabric8.io/update-on-change"
 
 	// Deployment
@@ -112,9 +118,7 @@ func makeFlowDeployment(flow *v1.ConfigMap, connector *v1.ConfigMap, old *v1beta
 			}
 		}
 	}
-	if len(deployment.Annotations[ConfigMapControllerAnnotation]) == 0 {
-		deployment.Annotations[ConfigMapControllerAnnotation] = flow.Name
-	}
+	setConfigChecksum(&deployment, configChecksum(flow.Data, []string{FunktionYmlProperty, ApplicationPropertiesProperty, ApplicationYmlProperty}))
 
 	volumeName := "config"
 	items := []v1.KeyToPath{}
@@ -163,12 +167,78 @@ func makeFlowDeployment(flow *v1.ConfigMap, connector *v1.ConfigMap, old *v1beta
 		deployment.Spec.Template.Spec.Containers[0].Name = "connector"
 	}
 	setDeploymentLabel(&deployment, NameLabel, name)
+	setManagedBy(&deployment.ObjectMeta, flow)
+	setOwnerReference(&deployment.ObjectMeta, ownerReference(flow))
 	return &deployment, nil
 }
 
-func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, old *v1beta1.Deployment) (*v1beta1.Deployment, error) {
+func makeFlowService(flow *v1.ConfigMap, connector *v1.ConfigMap, old *v1.Service, deployment *v1beta1.Deployment) (*v1.Service, error) {
+	yamlText := connector.Data[ServiceYmlProperty]
+	if len(yamlText) == 0 {
+		return nil, fmt.Errorf("No property `%s` on the Connector ConfigMap %s", ServiceYmlProperty, connector.Name)
+	}
+
+	svc := &v1.Service{}
+	err := yaml.Unmarshal([]byte(yamlText), &svc)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Service YAML from property `%s` on the Connector ConfigMap %s. Error: %s", ServiceYmlProperty, connector.Name, err)
+	}
+
+	svc.Name = flow.Name
+	if svc.Annotations == nil {
+		svc.Annotations = make(map[string]string)
+	}
+	if svc.Labels == nil {
+		svc.Labels = make(map[string]string)
+	}
+
+	svc.Spec.Selector = deployment.Spec.Selector.MatchLabels
+
+	// lets copy across any old missing dependencies
+	if old != nil {
+		if old.Annotations != nil {
+			for k, v := range old.Annotations {
+				if len(svc.Annotations[k]) == 0 {
+					svc.Annotations[k] = v
+				}
+			}
+		}
+	}
+	if flow.Labels != nil {
+		for k, v := range flow.Labels {
+			if len(svc.Labels[k]) == 0 {
+				svc.Labels[k] = v
+			}
+		}
+	}
+	if len(svc.Labels[ExposeLabel]) == 0 {
+		svc.Labels[ExposeLabel] = "true"
+	}
+	setManagedBy(&svc.ObjectMeta, flow)
+	setOwnerReference(&svc.ObjectMeta, ownerReference(flow))
+	return svc, nil
+}
+
+// runtimeSourcePath returns the path the Runtime projects the source of a Function to in its source volume
+// defaulting to sourcePath
+func runtimeSourcePath(podSpec *v1.PodSpec) string {
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == sourceVolume && volume.ConfigMap != nil {
+			for _, item := range volume.ConfigMap.Items {
+				if item.Key == SourceProperty && len(item.Path) > 0 {
+					return item.Path
+				}
+			}
+		}
+	}
+	return sourcePath
+}
+
+// makeFunctionDeployment returns the Deployment running the given revision of a Function
+func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, revision *functionRevision, old *v1beta1.Deployment) (*v1beta1.Deployment, error) {
+	data := revision.Data.Data
 	deployYaml := runtime.Data[DeploymentProperty]
-	debugFlag := function.Data[DebugProperty]
+	debugFlag := data[DebugProperty]
 	if strings.ToLower(debugFlag) == "true" {
 		deployYaml = runtime.Data[DeploymentDebugProperty]
 		if len(deployYaml) == 0 {
@@ -186,7 +256,7 @@ func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, old *
 	}
 
 	name := function.Name
-	deployment.Name = name
+	deployment.Name = RevisionName(name, revision.Number)
 	if deployment.Annotations == nil {
 		deployment.Annotations = make(map[string]string)
 	}
@@ -211,37 +281,66 @@ func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, old *
 			}
 		}
 	}
-	if len(deployment.Annotations[ConfigMapControllerAnnotation]) == 0 {
-		deployment.Annotations[ConfigMapControllerAnnotation] = function.Name
+	checksumKeys := []string{SourceProperty, EnvVarsProperty}
+	for key := range data {
+		if IsFileProperty(key) {
+			checksumKeys = append(checksumKeys, key)
+		}
+	}
+	setConfigChecksum(&deployment, configChecksum(data, checksumKeys))
+	replicas, err := replicasForFunction(function)
+	if err != nil {
+		return nil, err
+	}
+	if replicas != nil {
+		deployment.Spec.Replicas = replicas
+	}
+	if revision.Replicas != nil {
+		replicas := *revision.Replicas
+		deployment.Spec.Replicas = &replicas
+	}
+	// lets leave the replicas of an autoscaled Function to its HorizontalPodAutoscaler
+	if old != nil && revision.Replicas == nil && len(function.Data[MaxReplicasProperty]) > 0 {
+		deployment.Spec.Replicas = old.Spec.Replicas
+	}
+	// lets keep an idle Function scaled to zero until the activator wakes it up
+	if old != nil && old.Spec.Replicas != nil && *old.Spec.Replicas == 0 {
+		timeout, err := idleTimeoutForFunction(function, runtime)
+		if err != nil {
+			return nil, err
+		}
+		if timeout > 0 {
+			deployment.Spec.Replicas = old.Spec.Replicas
+		}
 	}
 
-	if len(function.Data[SourceProperty]) == 0 {
+	if len(data[SourceProperty]) == 0 {
 		return nil, fmt.Errorf("No property `%s` on the Function ConfigMap %s", SourceProperty, function.Name)
 	}
 
-	volumeName := "config"
-	items := []v1.KeyToPath{
-		v1.KeyToPath{
-			Key:  SourceProperty,
-			Path: "source.js",
-		},
+	files, err := ParseFunctionFiles(data[FilesProperty])
+	if err != nil {
+		return nil, fmt.Errorf("Function %s: %s", function.Name, err)
 	}
 
+	// lets project the source and files the same way whether or not the Runtime declares the source volume
 	foundVolume := false
 	podSpec := &deployment.Spec.Template.Spec
+	items := append([]v1.KeyToPath{{Key: SourceProperty, Path: runtimeSourcePath(podSpec)}}, fileItems(files)...)
 	for i, volume := range podSpec.Volumes {
-		if volume.Name == "source" && volume.ConfigMap != nil {
-			podSpec.Volumes[i].ConfigMap.Name = function.Name
+		if volume.Name == sourceVolume && volume.ConfigMap != nil {
+			podSpec.Volumes[i].ConfigMap.Name = revision.Data.Name
+			podSpec.Volumes[i].ConfigMap.Items = items
 			foundVolume = true
 		}
 	}
 	if !foundVolume {
 		podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
-			Name: volumeName,
+			Name: sourceVolume,
 			VolumeSource: v1.VolumeSource{
 				ConfigMap: &v1.ConfigMapVolumeSource{
 					LocalObjectReference: v1.LocalObjectReference{
-						Name: function.Name,
+						Name: revision.Data.Name,
 					},
 					Items: items,
 				},
@@ -249,7 +348,7 @@ func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, old *
 		})
 	}
 
-	envVars := parseEnvVars(function.Data[EnvVarsProperty])
+	envVars := parseEnvVars(data[EnvVarsProperty])
 
 	mountPath := runtime.Data[SourceMountPathProperty]
 	if len(mountPath) == 0 {
@@ -258,13 +357,13 @@ func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, old *
 	for i, container := range podSpec.Containers {
 		foundVolumeMount := false
 		for _, volumeMount := range container.VolumeMounts {
-			if volumeMount.Name == "source" {
+			if volumeMount.Name == sourceVolume {
 				foundVolumeMount = true
 			}
 		}
 		if !foundVolumeMount {
 			podSpec.Containers[i].VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
-				Name:      "source",
+				Name:      sourceVolume,
 				MountPath: mountPath,
 				ReadOnly:  true,
 			})
@@ -273,10 +372,23 @@ func makeFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, old *
 			applyEnvVars(&podSpec.Containers[i].Env, &envVars)
 		}
 	}
+	if err := applyDependencyInstall(&deployment, runtime, files); err != nil {
+		return nil, err
+	}
+	overrides, err := podOverridesForFunction(function.Name, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := overrides.apply(&deployment.Spec.Template); err != nil {
+		return nil, err
+	}
 	if len(deployment.Spec.Template.Spec.Containers[0].Name) == 0 {
 		deployment.Spec.Template.Spec.Containers[0].Name = "function"
 	}
 	setDeploymentLabel(&deployment, NameLabel, name)
+	setDeploymentLabel(&deployment, RevisionLabel, strconv.Itoa(revision.Number))
+	setManagedBy(&deployment.ObjectMeta, function)
+	setOwnerReference(&deployment.ObjectMeta, ownerReference(function))
 	return &deployment, nil
 }
 
@@ -286,15 +398,15 @@ func parseEnvVars(text string) []v1.EnvVar {
 		lines := strings.Split(text, "\n")
 		for _, line := range lines {
 			l := strings.TrimSpace(line)
-			pair := strings.SplitN(l, "=", 2)
-			if len(pair) != 2 {
-				fmt.Printf("Ignoring bad environment variable pair. Expecting `NAME=VALUE` but got: %s\n", l)
+			if len(l) == 0 {
 				continue
 			}
-			answer = append(answer, v1.EnvVar{
-				Name:  pair[0],
-				Value: pair[1],
-			})
+			envVar, err := ParseEnvVar(l)
+			if err != nil {
+				fmt.Printf("Ignoring bad environment variable. %s\n", err)
+				continue
+			}
+			answer = append(answer, envVar)
 		}
 		return answer
 	}
@@ -311,10 +423,10 @@ func applyEnvVars(envVar *[]v1.EnvVar, overrides *[]v1.EnvVar) {
 	}
 	for _, o := range *overrides {
 		found := false
-		for _, v := range *envVar {
+		for i, v := range *envVar {
 			if v.Name == o.Name {
-				v.Value = o.Value
-				v.ValueFrom = nil
+				(*envVar)[i].Value = o.Value
+				(*envVar)[i].ValueFrom = o.ValueFrom
 				found = true
 			}
 		}
@@ -358,7 +470,13 @@ func makeFunctionService(function *v1.ConfigMap, runtime *v1.ConfigMap, old *v1.
 		svc.Labels = make(map[string]string)
 	}
 
-	svc.Spec.Selector = deployment.Spec.Selector.MatchLabels
+	// lets select the pods of every revision so that requests are split between them
+	svc.Spec.Selector = map[string]string{}
+	for k, v := range deployment.Spec.Selector.MatchLabels {
+		if k != RevisionLabel {
+			svc.Spec.Selector[k] = v
+		}
+	}
 
 	// lets copy across any old missing dependencies
 	if old != nil {
@@ -379,6 +497,12 @@ func makeFunctionService(function *v1.ConfigMap, runtime *v1.ConfigMap, old *v1.
 	}
 	if len(svc.Labels[ExposeLabel]) == 0 {
 		svc.Labels[ExposeLabel] = "true"
+		// requests which bypass the activator would not count as activity of a Function scaled to zero when idle
+		if timeout, err := idleTimeoutForFunction(function, runtime); err == nil && timeout > 0 {
+			svc.Labels[ExposeLabel] = "false"
+		}
 	}
+	setManagedBy(&svc.ObjectMeta, function)
+	setOwnerReference(&svc.ObjectMeta, ownerReference(function))
 	return svc, nil
 }
@@ -0,0 +1,101 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const (
+	// SecretEnvVarPrefix is the prefix of an environment variable value which references a key of a Secret
+	// using the form `NAME=secret:SECRET/KEY`
+	SecretEnvVarPrefix = "secret:"
+	// ConfigMapEnvVarPrefix is the prefix of an environment variable value which references a key of a ConfigMap
+	// using the form `NAME=configmap:CONFIGMAP/KEY`
+	ConfigMapEnvVarPrefix = "configmap:"
+)
+
+var (
+	envVarNameRegexp        = regexp.MustCompile(`^[-._a-zA-Z][-._a-zA-Z0-9]*$`)
+	envVarResourceRegexp    = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	envVarResourceKeyRegexp = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// ParseEnvVar parses a line of the `envVars` property of a Function. The value is either a literal
+// or a reference to the key of a Secret or ConfigMap such as `API_KEY=secret:my-secret/api-key`
+// which is resolved by Kubernetes when the pod starts so the value is never stored on the Function
+func ParseEnvVar(line string) (v1.EnvVar, error) {
+	pair := strings.SplitN(line, "=", 2)
+	if len(pair) != 2 || !envVarNameRegexp.MatchString(pair[0]) {
+		return v1.EnvVar{}, fmt.Errorf("Expecting `NAME=VALUE` but got: %s", line)
+	}
+	name := pair[0]
+	value := pair[1]
+	var prefix string
+	switch {
+	case strings.HasPrefix(value, SecretEnvVarPrefix):
+		prefix = SecretEnvVarPrefix
+	case strings.HasPrefix(value, ConfigMapEnvVarPrefix):
+		prefix = ConfigMapEnvVarPrefix
+	default:
+		return v1.EnvVar{Name: name, Value: value}, nil
+	}
+	ref := strings.SplitN(strings.TrimPrefix(value, prefix), "/", 2)
+	if len(ref) != 2 || !envVarResourceRegexp.MatchString(ref[0]) || !envVarResourceKeyRegexp.MatchString(ref[1]) {
+		return v1.EnvVar{}, fmt.Errorf("Expecting `%s=%sNAME/KEY` but got: %s", name, prefix, line)
+	}
+	reference := v1.LocalObjectReference{Name: ref[0]}
+	source := &v1.EnvVarSource{}
+	if prefix == SecretEnvVarPrefix {
+		source.SecretKeyRef = &v1.SecretKeySelector{LocalObjectReference: reference, Key: ref[1]}
+	} else {
+		source.ConfigMapKeyRef = &v1.ConfigMapKeySelector{LocalObjectReference: reference, Key: ref[1]}
+	}
+	return v1.EnvVar{Name: name, ValueFrom: source}, nil
+}
+
+// FormatEnvVar formats the environment variable as a line of the `envVars` property of a Function.
+// False is returned if the value comes from a source other than a Secret or ConfigMap key
+func FormatEnvVar(envVar v1.EnvVar) (string, bool) {
+	source := envVar.ValueFrom
+	switch {
+	case source == nil:
+		return envVar.Name + "=" + envVar.Value, true
+	case source.SecretKeyRef != nil:
+		return envVar.Name + "=" + SecretEnvVarPrefix + source.SecretKeyRef.Name + "/" + source.SecretKeyRef.Key, true
+	case source.ConfigMapKeyRef != nil:
+		return envVar.Name + "=" + ConfigMapEnvVarPrefix + source.ConfigMapKeyRef.Name + "/" + source.ConfigMapKeyRef.Key, true
+	}
+	return "", false
+}
+
+// DescribeEnvVar returns a description of the environment variable suitable for display. The value of
+// a variable which comes from a Secret is never included, only the Secret and key it comes from
+func DescribeEnvVar(envVar v1.EnvVar) string {
+	source := envVar.ValueFrom
+	switch {
+	case source == nil:
+		return envVar.Name + "=" + envVar.Value
+	case source.SecretKeyRef != nil:
+		return fmt.Sprintf("%s from Secret %s key %s", envVar.Name, source.SecretKeyRef.Name, source.SecretKeyRef.Key)
+	case source.ConfigMapKeyRef != nil:
+		return fmt.Sprintf("%s from ConfigMap %s key %s", envVar.Name, source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key)
+	}
+	return envVar.Name
+}
@@ -0,0 +1,83 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+func TestParseEnvVar(t *testing.T) {
+	envVar, err := ParseEnvVar("GREETING=hello=world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envVar.Value != "hello=world" || envVar.ValueFrom != nil {
+		t.Errorf("expected a literal value but got %#v", envVar)
+	}
+
+	envVar, err = ParseEnvVar("API_KEY=secret:my-secret/api-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := envVar.ValueFrom.SecretKeyRef; ref == nil || ref.Name != "my-secret" || ref.Key != "api-key" || len(envVar.Value) > 0 {
+		t.Errorf("expected a secret key reference but got %#v", envVar)
+	}
+
+	envVar, err = ParseEnvVar("LEVEL=configmap:settings/log.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := envVar.ValueFrom.ConfigMapKeyRef; ref == nil || ref.Name != "settings" || ref.Key != "log.level" {
+		t.Errorf("expected a config map key reference but got %#v", envVar)
+	}
+
+	for _, line := range []string{"FOO", "MY VAR=bar", "API_KEY=secret:my-secret", "API_KEY=secret:My_Secret/key", "LEVEL=configmap:settings/"} {
+		if _, err := ParseEnvVar(line); err == nil {
+			t.Errorf("expected an error parsing %s", line)
+		}
+	}
+}
+
+func TestFormatEnvVars(t *testing.T) {
+	text := "GREETING=hello\nAPI_KEY=secret:my-secret/api-key\nLEVEL=configmap:settings/level"
+	if actual := formatEnvVars(parseEnvVars(text)); actual != text {
+		t.Errorf("expected the environment variables to round trip as %s but got %s", text, actual)
+	}
+	fieldRef := v1.EnvVar{Name: "POD", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}}
+	if actual := formatEnvVars([]v1.EnvVar{fieldRef}); len(actual) > 0 {
+		t.Errorf("expected an unsupported value source to be ignored but got %s", actual)
+	}
+}
+
+func TestApplyEnvVarsOverridesValueFrom(t *testing.T) {
+	env := []v1.EnvVar{{Name: "API_KEY", Value: "changeme"}}
+	overrides := parseEnvVars("API_KEY=secret:my-secret/api-key")
+	applyEnvVars(&env, &overrides)
+	if len(env) != 1 || len(env[0].Value) > 0 || env[0].ValueFrom == nil || env[0].ValueFrom.SecretKeyRef == nil {
+		t.Errorf("expected the runtime value to be replaced by the secret reference but got %#v", env)
+	}
+}
+
+func TestDescribeEnvVarHidesSecrets(t *testing.T) {
+	envVar, err := ParseEnvVar("API_KEY=secret:my-secret/api-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := DescribeEnvVar(envVar); actual != "API_KEY from Secret my-secret key api-key" {
+		t.Errorf("unexpected description %s", actual)
+	}
+}
@@ -0,0 +1,183 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const (
+	// EventComponent is the source component of the Events posted by the operator
+	EventComponent = "funktion-operator"
+
+	// ReasonCreated is the Event reason when a Deployment or Service has been created
+	ReasonCreated = "Created"
+	// ReasonUpdated is the Event reason when a Deployment or Service has been updated
+	ReasonUpdated = "Updated"
+	// ReasonDeleted is the Event reason when a Deployment or Service has been deleted
+	ReasonDeleted = "Deleted"
+	// ReasonFailedSync is the Event reason when a Function or Flow could not be reconciled
+	ReasonFailedSync = "FailedSync"
+	// ReasonFailedDelete is the Event reason when a Deployment or Service could not be deleted
+	ReasonFailedDelete = "FailedDelete"
+
+	// maxCachedEvents limits how many Events we remember for aggregating repeated Events
+	maxCachedEvents = 4096
+
+	// maxQueuedEvents limits how many Events wait to be posted before new ones are dropped
+	maxQueuedEvents = 1000
+)
+
+// eventRecorder posts Events against the ConfigMaps of funktion resources.
+// Events are posted in the background so that reconciling never waits on the API server.
+// Repeated Events with the same reason and message are aggregated by incrementing the count
+// on the previously posted Event. Repeated Warnings are aggregated by reason alone so that
+// a resource which keeps failing with slightly different messages doesn't flood the Events
+type eventRecorder struct {
+	kclient kubernetes.Interface
+	logger  log.Logger
+	source  v1.EventSource
+
+	queue chan *v1.Event
+	// cache is only used by the goroutine posting the Events
+	cache map[string]*v1.Event
+}
+
+func newEventRecorder(kclient kubernetes.Interface, logger log.Logger) *eventRecorder {
+	host, _ := os.Hostname()
+	return &eventRecorder{
+		kclient: kclient,
+		logger:  logger,
+		source: v1.EventSource{
+			Component: EventComponent,
+			Host:      host,
+		},
+		queue: make(chan *v1.Event, maxQueuedEvents),
+		cache: map[string]*v1.Event{},
+	}
+}
+
+// run posts the queued Events until stopc is closed
+func (r *eventRecorder) run(stopc <-chan struct{}) {
+	for {
+		select {
+		case <-stopc:
+			return
+		case event := <-r.queue:
+			r.post(event)
+		}
+	}
+}
+
+// resourceReference returns the reference to use for Events on the given Function or Flow
+func resourceReference(cm *v1.ConfigMap) v1.ObjectReference {
+	ref := v1.ObjectReference{
+		Kind:            "ConfigMap",
+		APIVersion:      "v1",
+		Namespace:       cm.Namespace,
+		Name:            cm.Name,
+		UID:             cm.UID,
+		ResourceVersion: cm.ResourceVersion,
+	}
+	if IsCustomResource(cm) {
+		ref.Kind = cm.Kind
+		ref.APIVersion = cm.APIVersion
+	}
+	return ref
+}
+
+// keyReference returns the reference to use for Events on a ConfigMap which may no longer exist
+func keyReference(key string) v1.ObjectReference {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		name = key
+	}
+	return v1.ObjectReference{
+		Kind:       "ConfigMap",
+		APIVersion: "v1",
+		Namespace:  ns,
+		Name:       name,
+	}
+}
+
+// Eventf queues an Event of the given type against the referenced object
+func (r *eventRecorder) Eventf(ref v1.ObjectReference, eventType, reason, messageFmt string, args ...interface{}) {
+	now := unversioned.Now()
+	event := &v1.Event{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", ref.Name, now.UnixNano()),
+			Namespace: ref.Namespace,
+		},
+		InvolvedObject: ref,
+		Reason:         reason,
+		Message:        fmt.Sprintf(messageFmt, args...),
+		Source:         r.source,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+	select {
+	case r.queue <- event:
+	default:
+		r.logger.Log("msg", "dropping event as too many are waiting to be posted", "reason", reason, "name", ref.Name, "namespace", ref.Namespace)
+	}
+}
+
+// eventCacheKey returns the key of the Events which are aggregated with the given Event
+func eventCacheKey(event *v1.Event) string {
+	ref := event.InvolvedObject
+	key := fmt.Sprintf("%s/%s/%s/%s/%s", ref.Namespace, ref.Name, ref.UID, event.Type, event.Reason)
+	if event.Type == v1.EventTypeWarning {
+		return key
+	}
+	return key + "/" + event.Message
+}
+
+// post creates the Event or increments the count of the previously posted Event it aggregates with
+func (r *eventRecorder) post(event *v1.Event) {
+	cacheKey := eventCacheKey(event)
+	events := r.kclient.Core().Events(event.Namespace)
+	if old := r.cache[cacheKey]; old != nil {
+		aggregated := *old
+		aggregated.Count++
+		aggregated.Message = event.Message
+		aggregated.LastTimestamp = event.LastTimestamp
+		updated, err := events.Update(&aggregated)
+		if err == nil {
+			r.cache[cacheKey] = updated
+			return
+		}
+		// the old Event may have expired so lets post a new one
+		delete(r.cache, cacheKey)
+	}
+
+	created, err := events.Create(event)
+	if err != nil {
+		r.logger.Log("msg", "failed to post event", "reason", event.Reason, "name", event.InvolvedObject.Name, "namespace", event.Namespace, "err", err)
+		return
+	}
+	if len(r.cache) >= maxCachedEvents {
+		r.cache = map[string]*v1.Event{}
+	}
+	r.cache[cacheKey] = created
+}
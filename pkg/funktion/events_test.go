@@ -0,0 +1,64 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// postQueued posts the Events queued on the recorder
+func postQueued(r *eventRecorder) {
+	for {
+		select {
+		case event := <-r.queue:
+			r.post(event)
+		default:
+			return
+		}
+	}
+}
+
+func TestEventRecorderAggregatesWarningsByReason(t *testing.T) {
+	kclient := fake.NewSimpleClientset()
+	r := newEventRecorder(kclient, log.NewNopLogger())
+	ref := resourceReference(functionConfigMap("nodejs", nil))
+
+	r.Eventf(ref, v1.EventTypeWarning, ReasonFailedSync, "attempt %d failed", 1)
+	r.Eventf(ref, v1.EventTypeWarning, ReasonFailedSync, "attempt %d failed", 2)
+	r.Eventf(ref, v1.EventTypeNormal, ReasonCreated, "Created Deployment %s", "hello-v1")
+	r.Eventf(ref, v1.EventTypeNormal, ReasonCreated, "Created Deployment %s", "hello-v2")
+	postQueued(r)
+
+	list, err := kclient.Core().Events("default").List(api.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 3 {
+		t.Fatalf("expected 3 events but got %d", len(list.Items))
+	}
+	for _, event := range list.Items {
+		if event.Type != v1.EventTypeWarning {
+			continue
+		}
+		if event.Count != 2 || event.Message != "attempt 2 failed" {
+			t.Errorf("expected the warning to be counted twice with the latest message but got %d: %s", event.Count, event.Message)
+		}
+	}
+}
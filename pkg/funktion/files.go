@@ -0,0 +1,230 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// FilesProperty is the data key for the newline separated `KEY=PATH` lines of a Function which map the
+	// data keys holding the other files of the Function to their path relative to the source mount path
+	FilesProperty = "files"
+
+	// DependencyFilesProperty is the data key for the comma separated paths of the files of a Function
+	// such as `package.json` which declare dependencies to be installed before the Function starts
+	DependencyFilesProperty = "dependencyFiles"
+	// DependencyInstallCommandProperty is the data key for the shell command of a Runtime which installs
+	// the dependencies of a Function such as `npm install --production`
+	DependencyInstallCommandProperty = "dependencyInstallCommand"
+	// DependencyInstallImageProperty is the data key for the image used to install the dependencies of a
+	// Function. Defaults to the image of the Runtime
+	DependencyInstallImageProperty = "dependencyInstallImage"
+
+	// fileKeyPrefix is the prefix of the data keys holding the files of a Function
+	fileKeyPrefix = "file."
+
+	// initContainersAnnotation is the annotation the kubelet reads the init containers of a pod from
+	initContainersAnnotation = "pod.beta.kubernetes.io/init-containers"
+
+	// sourceVolume is the name of the volume containing the source of a Function
+	sourceVolume = "source"
+	// sourcePath is the path of the source of a Function in the source volume
+	sourcePath = "source.js"
+
+	dependencyInstallContainer = "install-dependencies"
+	dependencyWorkspaceVolume  = "workspace"
+	dependencySourcePath       = "/funktion-source"
+)
+
+var (
+	configMapKeyRegexp   = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	invalidFileKeyRegexp = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+)
+
+// FunctionFile is one of the other files of a Function along with the data key which holds it
+type FunctionFile struct {
+	Key  string
+	Path string
+}
+
+// ParseFunctionFiles parses the `files` property of a Function
+func ParseFunctionFiles(text string) ([]FunctionFile, error) {
+	answer := []FunctionFile{}
+	paths := map[string]bool{}
+	for i, line := range strings.Split(text, "\n") {
+		l := strings.TrimSpace(line)
+		if len(l) == 0 {
+			continue
+		}
+		pair := strings.SplitN(l, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], fileKeyPrefix) || !configMapKeyRegexp.MatchString(pair[0]) {
+			return nil, fmt.Errorf("Invalid `%s` line %d. Expecting `%sNAME=PATH` but got: %s", FilesProperty, i+1, fileKeyPrefix, l)
+		}
+		p := pair[1]
+		if len(p) == 0 || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("Invalid `%s` line %d. The path `%s` must be a clean relative path inside the source folder", FilesProperty, i+1, p)
+		}
+		if paths[p] {
+			return nil, fmt.Errorf("Invalid `%s` line %d. The path `%s` is used more than once", FilesProperty, i+1, p)
+		}
+		paths[p] = true
+		answer = append(answer, FunctionFile{Key: pair[0], Path: p})
+	}
+	return answer, nil
+}
+
+// SetFunctionFiles replaces the files on the data of a Function with the given files keyed by their path
+// along with the `files` property which maps the generated data keys back to the paths
+func SetFunctionFiles(data map[string]string, files map[string]string) {
+	for key := range data {
+		if IsFileProperty(key) {
+			delete(data, key)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	paths := []string{}
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	lines := []string{}
+	for _, p := range paths {
+		key := fileKeyPrefix + invalidFileKeyRegexp.ReplaceAllString(p, "_")
+		for i := 2; hasKey(data, key); i++ {
+			key = fileKeyPrefix + invalidFileKeyRegexp.ReplaceAllString(p, "_") + "-" + strconv.Itoa(i)
+		}
+		data[key] = files[p]
+		lines = append(lines, key+"="+p)
+	}
+	data[FilesProperty] = strings.Join(lines, "\n")
+}
+
+func hasKey(data map[string]string, key string) bool {
+	_, ok := data[key]
+	return ok
+}
+
+// GetFunctionFiles returns the other files of a Function keyed by their path
+func GetFunctionFiles(data map[string]string) (map[string]string, error) {
+	files, err := ParseFunctionFiles(data[FilesProperty])
+	if err != nil {
+		return nil, err
+	}
+	answer := map[string]string{}
+	for _, f := range files {
+		content, ok := data[f.Key]
+		if !ok {
+			return nil, fmt.Errorf("The file `%s` has no data key `%s`", f.Path, f.Key)
+		}
+		answer[f.Path] = content
+	}
+	return answer, nil
+}
+
+// IsFileProperty returns true if the data key holds the files of a Function or their mapping
+func IsFileProperty(key string) bool {
+	return key == FilesProperty || strings.HasPrefix(key, fileKeyPrefix)
+}
+
+// fileItems returns the items projecting the files of a Function into its source volume
+func fileItems(files []FunctionFile) []v1.KeyToPath {
+	items := []v1.KeyToPath{}
+	for _, f := range files {
+		items = append(items, v1.KeyToPath{Key: f.Key, Path: f.Path})
+	}
+	return items
+}
+
+// hasDependencyFiles returns true if the Function has one of the dependency files of the Runtime
+func hasDependencyFiles(runtime *v1.ConfigMap, files []FunctionFile) bool {
+	for _, name := range strings.Split(runtime.Data[DependencyFilesProperty], ",") {
+		name = strings.TrimSpace(name)
+		for _, f := range files {
+			if len(name) > 0 && f.Path == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyDependencyInstall adds an init container to the Deployment of a Function which copies the source
+// of the Function into a workspace and runs the dependency install command of the Runtime there. The
+// containers then mount the workspace rather than the source so that they see the installed dependencies
+func applyDependencyInstall(deployment *v1beta1.Deployment, runtime *v1.ConfigMap, files []FunctionFile) error {
+	command := strings.TrimSpace(runtime.Data[DependencyInstallCommandProperty])
+	if len(command) == 0 || !hasDependencyFiles(runtime, files) {
+		return nil
+	}
+	template := &deployment.Spec.Template
+	podSpec := &template.Spec
+	mountPath := ""
+	for i, container := range podSpec.Containers {
+		for j, volumeMount := range container.VolumeMounts {
+			if volumeMount.Name == sourceVolume {
+				if len(mountPath) == 0 {
+					mountPath = volumeMount.MountPath
+				}
+				podSpec.Containers[i].VolumeMounts[j].Name = dependencyWorkspaceVolume
+			}
+		}
+	}
+	if len(mountPath) == 0 {
+		return fmt.Errorf("Runtime %s does not mount the `%s` volume", runtime.Name, sourceVolume)
+	}
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+		Name: dependencyWorkspaceVolume,
+		VolumeSource: v1.VolumeSource{
+			EmptyDir: &v1.EmptyDirVolumeSource{},
+		},
+	})
+
+	image := runtime.Data[DependencyInstallImageProperty]
+	if len(image) == 0 {
+		image = podSpec.Containers[0].Image
+	}
+	// the files of a ConfigMap volume are symlinks into hidden `..` folders which we don't want to copy
+	script := fmt.Sprintf("cp -rL %s/. %s && rm -rf %s/..?* && cd %s && %s", dependencySourcePath, mountPath, mountPath, mountPath, command)
+	install := v1.Container{
+		Name:    dependencyInstallContainer,
+		Image:   image,
+		Command: []string{"sh", "-c", script},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: sourceVolume, MountPath: dependencySourcePath, ReadOnly: true},
+			{Name: dependencyWorkspaceVolume, MountPath: mountPath},
+		},
+	}
+
+	initContainers := []v1.Container{}
+	if text := template.Annotations[initContainersAnnotation]; len(text) > 0 {
+		if err := json.Unmarshal([]byte(text), &initContainers); err != nil {
+			return fmt.Errorf("Failed to parse annotation `%s` of the Runtime %s: %v", initContainersAnnotation, runtime.Name, err)
+		}
+	}
+	initContainers = append(initContainers, install)
+	return setJSONAnnotation(&template.ObjectMeta, initContainersAnnotation, initContainers)
+}
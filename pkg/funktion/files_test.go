@@ -0,0 +1,158 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const filesTestDeployment = `
+spec:
+  template:
+    spec:
+      containers:
+      - image: funktion/nodejs
+        volumeMounts:
+        - name: source
+          mountPath: /funktion
+          readOnly: true
+      volumes:
+      - name: source
+        configMap:
+          name: placeholder
+          items:
+          - key: source
+            path: index.js
+`
+
+func TestFunctionFilesRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"lib/greet.js": "module.exports = 'hello'",
+		"lib_greet.js": "module.exports = 'clash'",
+		"package.json": "{}",
+	}
+	data := map[string]string{SourceProperty: "require('./lib/greet')"}
+	SetFunctionFiles(data, files)
+	for key := range data {
+		if !configMapKeyRegexp.MatchString(key) {
+			t.Errorf("generated an invalid data key %s", key)
+		}
+	}
+	actual, err := GetFunctionFiles(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, files) {
+		t.Errorf("expected files %v but got %v", files, actual)
+	}
+}
+
+func TestParseFunctionFilesRejectsUnsafePaths(t *testing.T) {
+	for _, text := range []string{
+		"file.a=/etc/passwd",
+		"file.a=../secret.js",
+		"file.a=lib/../../secret.js",
+		"file.a=lib//greet.js",
+		"source=lib/greet.js",
+		"file.a=a.js\nfile.b=a.js",
+	} {
+		if _, err := ParseFunctionFiles(text); err == nil {
+			t.Errorf("expected an error parsing %s", text)
+		}
+	}
+}
+
+func TestMakeFunctionDeploymentFiles(t *testing.T) {
+	runtime := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "nodejs"},
+		Data: map[string]string{
+			DeploymentProperty:               filesTestDeployment,
+			DependencyFilesProperty:          "package.json",
+			DependencyInstallCommandProperty: "npm install --production",
+		},
+	}
+	data := map[string]string{SourceProperty: "require('./lib/greet')"}
+	SetFunctionFiles(data, map[string]string{"lib/greet.js": "module.exports = 'hello'"})
+	function := functionConfigMap("nodejs", data)
+
+	revision := &functionRevision{Number: 1, Data: makeRevision(function, 1, "")}
+	d, err := makeFunctionDeployment(function, runtime, revision, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec := d.Spec.Template.Spec
+	paths := []string{}
+	for _, item := range podSpec.Volumes[0].ConfigMap.Items {
+		paths = append(paths, item.Path)
+	}
+	if expected := []string{"index.js", "lib/greet.js"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected the source volume to project %v but got %v", expected, paths)
+	}
+	if _, ok := d.Spec.Template.Annotations[initContainersAnnotation]; ok {
+		t.Errorf("expected no dependency install without a dependency file")
+	}
+
+	// lets project the source the same way into the volume we add when the Runtime does not declare one
+	runtime.Data[DeploymentProperty] = `
+spec:
+  template:
+    spec:
+      containers:
+      - image: funktion/nodejs
+`
+	d, err = makeFunctionDeployment(function, runtime, revision, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec = d.Spec.Template.Spec
+	if actual := podSpec.Volumes[0].Name; actual != sourceVolume {
+		t.Errorf("expected the %s volume but got %s", sourceVolume, actual)
+	}
+	if actual := podSpec.Containers[0].VolumeMounts[0].Name; actual != sourceVolume {
+		t.Errorf("expected the function to mount the %s volume but mounted %s", sourceVolume, actual)
+	}
+	if actual := podSpec.Volumes[0].ConfigMap.Items[0]; actual.Key != SourceProperty || actual.Path != sourcePath {
+		t.Errorf("expected the source to be projected to %s but got %v", sourcePath, actual)
+	}
+	runtime.Data[DeploymentProperty] = filesTestDeployment
+
+	// lets add a package.json so that the dependencies get installed
+	SetFunctionFiles(function.Data, map[string]string{"lib/greet.js": "module.exports = 'hello'", "package.json": "{}"})
+	revision = &functionRevision{Number: 2, Data: makeRevision(function, 2, "")}
+	d, err = makeFunctionDeployment(function, runtime, revision, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec = d.Spec.Template.Spec
+	if actual := podSpec.Containers[0].VolumeMounts[0].Name; actual != dependencyWorkspaceVolume {
+		t.Errorf("expected the function to mount the workspace but mounted %s", actual)
+	}
+	initContainers := []v1.Container{}
+	if err := json.Unmarshal([]byte(d.Spec.Template.Annotations[initContainersAnnotation]), &initContainers); err != nil {
+		t.Fatal(err)
+	}
+	if len(initContainers) != 1 {
+		t.Fatalf("expected 1 init container but got %d", len(initContainers))
+	}
+	install := initContainers[0]
+	if install.Image != "funktion/nodejs" || !strings.HasSuffix(install.Command[2], "cd /funktion && npm install --production") {
+		t.Errorf("unexpected install container %#v", install)
+	}
+}
@@ -0,0 +1,276 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const (
+	// garbageCollectionTimeout is how long we wait for the garbage collector to remove the
+	// resources owned by a deleted Function or Flow before we delete them ourselves
+	garbageCollectionTimeout = 1 * time.Minute
+
+	// teardownTimeout is how long we wait for a Deployment to scale down before deleting it
+	teardownTimeout = 2 * time.Minute
+
+	// teardownPollInterval is how often we check on a Deployment which is scaling down before it is deleted
+	teardownPollInterval = 5 * time.Second
+
+	// teardownKind is the kind the Deployments which are scaling down are tracked under
+	teardownKind = "Teardown"
+
+	// CleanupFinalizer is the finalizer the operator adds to the label based ConfigMaps of Functions and Flows
+	// so that their deletion only completes once the operator has removed their Deployments and Services
+	CleanupFinalizer = "funktion.fabric8.io/cleanup"
+)
+
+// errTeardownPending is returned when a Deployment is still scaling down so that its owner is synced again
+// after teardownPollInterval rather than blocking the worker until the pods are gone
+var errTeardownPending = fmt.Errorf("Deployment is still scaling down")
+
+// ownerReference returns the reference to the Function or Flow owning a generated resource
+// so that the garbage collector removes the resource when the Function or Flow is deleted
+func ownerReference(cm *v1.ConfigMap) v1.OwnerReference {
+	controller := true
+	ref := v1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Name:       cm.Name,
+		UID:        cm.UID,
+		Controller: &controller,
+	}
+	if IsCustomResource(cm) {
+		ref.APIVersion = cm.APIVersion
+		ref.Kind = cm.Kind
+	}
+	return ref
+}
+
+// isFunktionOwner returns true if the reference is to a label based ConfigMap or a custom resource
+func isFunktionOwner(ref v1.OwnerReference) bool {
+	return ref.Kind == "ConfigMap" || ref.APIVersion == spec.APIVersion
+}
+
+// setOwnerReference replaces any Function or Flow owner of the given resource with the given owner
+// so that resources are adopted when a ConfigMap is migrated to a custom resource
+func setOwnerReference(objectMeta *v1.ObjectMeta, owner v1.OwnerReference) {
+	refs := []v1.OwnerReference{}
+	for _, ref := range objectMeta.OwnerReferences {
+		if !isFunktionOwner(ref) {
+			refs = append(refs, ref)
+		}
+	}
+	objectMeta.OwnerReferences = append(refs, owner)
+}
+
+// hasFunktionOwner returns true if the resource is owned by a Function or Flow
+func hasFunktionOwner(objectMeta v1.ObjectMeta) bool {
+	for _, ref := range objectMeta.OwnerReferences {
+		if isFunktionOwner(ref) && len(ref.UID) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnedBy returns true if the resource is owned by the given Function or Flow
+func isOwnedBy(objectMeta v1.ObjectMeta, cm *v1.ConfigMap) bool {
+	for _, ref := range objectMeta.OwnerReferences {
+		if isFunktionOwner(ref) && ref.UID == cm.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFinalizer returns true if the resource has the given finalizer
+func hasFinalizer(objectMeta v1.ObjectMeta, finalizer string) bool {
+	for _, f := range objectMeta.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// addFinalizer adds the finalizer to the resource returning false if it was already there
+func addFinalizer(objectMeta *v1.ObjectMeta, finalizer string) bool {
+	if hasFinalizer(*objectMeta, finalizer) {
+		return false
+	}
+	objectMeta.Finalizers = append(objectMeta.Finalizers, finalizer)
+	return true
+}
+
+// removeFinalizer removes the finalizer from the resource returning false if it was not there
+func removeFinalizer(objectMeta *v1.ObjectMeta, finalizer string) bool {
+	finalizers := []string{}
+	for _, f := range objectMeta.Finalizers {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(objectMeta.Finalizers) {
+		return false
+	}
+	objectMeta.Finalizers = finalizers
+	return true
+}
+
+// orphanTracker remembers when we first noticed a resource whose owner has been deleted
+type orphanTracker struct {
+	lock  sync.Mutex
+	since map[string]time.Time
+}
+
+// observe returns how long the resource has been known to be orphaned and whether this is the first time
+func (t *orphanTracker) observe(kind string, key string) (time.Duration, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.since == nil {
+		t.since = map[string]time.Time{}
+	}
+	k := kind + "/" + key
+	since, ok := t.since[k]
+	if !ok {
+		t.since[k] = time.Now()
+		return 0, true
+	}
+	return time.Since(since), false
+}
+
+func (t *orphanTracker) forget(kind string, key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.since, kind+"/"+key)
+}
+
+// awaitGarbageCollection returns true if the resource is owned by a Function or Flow and the garbage
+// collector should still be given time to remove it. The owner is re-enqueued after the timeout
+// so that we fall back to deleting the resource ourselves on clusters without garbage collection
+func (c *Operator) awaitGarbageCollection(kind string, key string, ownerKind string, ownerKey string, objectMeta v1.ObjectMeta) bool {
+	// the garbage collector leaves the resources of an owner which is waiting on our finalizer alone
+	if !hasFunktionOwner(objectMeta) || c.ownerExists(ownerKind, ownerKey) {
+		return false
+	}
+	d, first := c.orphans.observe(kind, key)
+	if first {
+		c.queue.AddAfter(ResourceKey{Kind: ownerKind, Key: ownerKey}, garbageCollectionTimeout)
+		return true
+	}
+	if d < garbageCollectionTimeout {
+		return true
+	}
+	c.logger.Log("msg", "garbage collector did not remove resource in time, deleting it", "kind", kind, "key", key)
+	return false
+}
+
+// ownerExists returns true if the Function or Flow with the given key is still in the cache
+func (c *Operator) ownerExists(ownerKind string, ownerKey string) bool {
+	var store cache.Store
+	switch ownerKind {
+	case FunctionKind:
+		store = c.functionInf.GetStore()
+	case FlowKind:
+		store = c.flowInf.GetStore()
+	default:
+		return false
+	}
+	_, exists, err := store.GetByKey(ownerKey)
+	return err == nil && exists
+}
+
+// ensureFinalizer adds the cleanup finalizer to the label based ConfigMap of a Function or Flow. Custom
+// resources do not support finalizers so their resources are left to the garbage collector and the startup sweep
+func (c *Operator) ensureFinalizer(cm *v1.ConfigMap) error {
+	if IsCustomResource(cm) || hasFinalizer(cm.ObjectMeta, CleanupFinalizer) {
+		return nil
+	}
+	cms := c.kclient.Core().ConfigMaps(cm.Namespace)
+	latest, err := cms.Get(cm.Name)
+	if err != nil {
+		return err
+	}
+	if latest.DeletionTimestamp != nil || !addFinalizer(&latest.ObjectMeta, CleanupFinalizer) {
+		return nil
+	}
+	if _, err = cms.Update(latest); err != nil {
+		return fmt.Errorf("add finalizer: %s", err)
+	}
+	return nil
+}
+
+// releaseFinalizer removes the cleanup finalizer from a deleted Function or Flow once its resources
+// have been removed so that the deletion of the ConfigMap can complete
+func (c *Operator) releaseFinalizer(cm *v1.ConfigMap) error {
+	if !hasFinalizer(cm.ObjectMeta, CleanupFinalizer) {
+		return nil
+	}
+	cms := c.kclient.Core().ConfigMaps(cm.Namespace)
+	latest, err := cms.Get(cm.Name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !removeFinalizer(&latest.ObjectMeta, CleanupFinalizer) {
+		return nil
+	}
+	if _, err = cms.Update(latest); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("remove finalizer: %s", err)
+	}
+	return nil
+}
+
+// sweepOrphans enqueues the Functions and Flows which no longer exist but still have managed Deployments,
+// Services, HorizontalPodAutoscalers or revisions so that resources whose owner was deleted while no operator
+// was running get cleaned up too
+func (c *Operator) sweepOrphans() {
+	owners := map[ResourceKey]bool{}
+	for _, inf := range []cache.SharedIndexInformer{c.deploymentInf, c.serviceInf, c.autoscalerInf, c.revisionInf} {
+		for _, obj := range inf.GetStore().List() {
+			objectMeta, ok := managedObjectMeta(obj)
+			if !ok {
+				continue
+			}
+			if owner, ok := c.orphanedOwner(objectMeta); ok {
+				owners[owner] = true
+			}
+		}
+	}
+	for owner := range owners {
+		c.logger.Log("msg", "found resources of a deleted owner", "kind", owner.Kind, "key", owner.Key)
+		c.queue.Add(owner)
+	}
+}
+
+// orphanedOwner returns the kind and key of the Function or Flow owning the resource if it no longer exists
+func (c *Operator) orphanedOwner(objectMeta v1.ObjectMeta) (ResourceKey, bool) {
+	owner, ok := managedOwner(objectMeta)
+	if !ok || c.ownerExists(owner.Kind, owner.Key) {
+		return ResourceKey{}, false
+	}
+	return owner, true
+}
@@ -0,0 +1,180 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/funktionio/funktion/pkg/queue"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	autoscaling "k8s.io/client-go/1.5/pkg/apis/autoscaling/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/types"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+func TestFinalizers(t *testing.T) {
+	meta := v1.ObjectMeta{Finalizers: []string{"orphan"}}
+	if hasFinalizer(meta, CleanupFinalizer) {
+		t.Errorf("expected no cleanup finalizer")
+	}
+	if !addFinalizer(&meta, CleanupFinalizer) {
+		t.Errorf("expected the cleanup finalizer to be added")
+	}
+	if addFinalizer(&meta, CleanupFinalizer) {
+		t.Errorf("expected the cleanup finalizer to be added only once")
+	}
+	if !hasFinalizer(meta, CleanupFinalizer) || len(meta.Finalizers) != 2 {
+		t.Errorf("expected finalizers [orphan %s] but got %v", CleanupFinalizer, meta.Finalizers)
+	}
+	if !removeFinalizer(&meta, CleanupFinalizer) {
+		t.Errorf("expected the cleanup finalizer to be removed")
+	}
+	if removeFinalizer(&meta, CleanupFinalizer) {
+		t.Errorf("expected nothing to remove")
+	}
+	if len(meta.Finalizers) != 1 || meta.Finalizers[0] != "orphan" {
+		t.Errorf("expected the other finalizers to be kept but got %v", meta.Finalizers)
+	}
+}
+
+// newGCTestOperator returns an Operator whose informer caches and fake client hold the given resources
+func newGCTestOperator(objects ...runtime.Object) (*Operator, *fake.Clientset) {
+	kclient := fake.NewSimpleClientset(objects...)
+	newInformer := func(indexers cache.Indexers) *testInformer {
+		return &testInformer{synced: true, indexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)}
+	}
+	c := &Operator{
+		kclient:       kclient,
+		logger:        log.NewNopLogger(),
+		recorder:      newEventRecorder(kclient, log.NewNopLogger()),
+		functionInf:   newInformer(cache.Indexers{}),
+		flowInf:       newInformer(cache.Indexers{}),
+		deploymentInf: newInformer(cache.Indexers{ownerIndex: ownerIndexFunc, revisionIndex: revisionIndexFunc}),
+		serviceInf:    newInformer(cache.Indexers{ownerIndex: ownerIndexFunc}),
+		autoscalerInf: newInformer(cache.Indexers{ownerIndex: ownerIndexFunc}),
+		revisionInf:   newInformer(cache.Indexers{revisionIndex: revisionIndexFunc}),
+		queue:         queue.New(),
+	}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *v1beta1.Deployment:
+			c.deploymentInf.GetIndexer().Add(o)
+		case *v1.Service:
+			c.serviceInf.GetIndexer().Add(o)
+		case *autoscaling.HorizontalPodAutoscaler:
+			c.autoscalerInf.GetIndexer().Add(o)
+		case *v1.ConfigMap:
+			if RevisionNumber(o.ObjectMeta) > 0 {
+				c.revisionInf.GetIndexer().Add(o)
+			} else {
+				c.functionInf.GetIndexer().Add(o)
+			}
+		}
+	}
+	return c, kclient
+}
+
+// namedFunction returns a Function with the given name and uid
+func namedFunction(name string, uid string) *v1.ConfigMap {
+	function := functionConfigMap("nodejs", map[string]string{SourceProperty: "module.exports = {}"})
+	function.Name = name
+	function.UID = types.UID(uid)
+	return function
+}
+
+// functionAutoscaler returns the HorizontalPodAutoscaler of the given Function with an owner reference if owned is true
+func functionAutoscaler(function *v1.ConfigMap, owned bool) *autoscaling.HorizontalPodAutoscaler {
+	hpa := &autoscaling.HorizontalPodAutoscaler{
+		ObjectMeta: v1.ObjectMeta{Name: function.Name, Namespace: function.Namespace},
+	}
+	setManagedBy(&hpa.ObjectMeta, function)
+	if owned {
+		setOwnerReference(&hpa.ObjectMeta, ownerReference(function))
+	}
+	return hpa
+}
+
+func TestSweepOrphans(t *testing.T) {
+	hello := namedFunction("hello", "1")
+	c, _ := newGCTestOperator(
+		hello,
+		functionDeployment(1, idleTestNow),
+		functionAutoscaler(hello, true),
+		functionAutoscaler(namedFunction("gone", "2"), true),
+		makeRevision(hello, 1, ""),
+		makeRevision(namedFunction("lost", "3"), 1, ""),
+	)
+	c.sweepOrphans()
+
+	swept := map[ResourceKey]bool{}
+	for c.queue.Len() > 0 {
+		item, _ := c.queue.Get()
+		swept[item.(ResourceKey)] = true
+		c.queue.Done(item)
+	}
+	expected := map[ResourceKey]bool{
+		{Kind: FunctionKind, Key: "default/gone"}: true,
+		{Kind: FunctionKind, Key: "default/lost"}: true,
+	}
+	if !reflect.DeepEqual(swept, expected) {
+		t.Errorf("expected the owners %v to be swept but got %v", expected, swept)
+	}
+}
+
+func TestAwaitGarbageCollection(t *testing.T) {
+	gone := namedFunction("gone", "2")
+	owned := functionAutoscaler(gone, true)
+	c, kclient := newGCTestOperator(owned)
+	hpas := kclient.Autoscaling().HorizontalPodAutoscalers("default")
+
+	if !c.awaitGarbageCollection(AutoscalerKind, "default/gone", FunctionKind, "default/gone", owned.ObjectMeta) {
+		t.Errorf("expected to wait for the garbage collector to remove an owned resource")
+	}
+	if err := c.destroyAutoscaler("default/gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hpas.Get("gone"); err != nil {
+		t.Errorf("expected the owned HorizontalPodAutoscaler to be left for the garbage collector but got %v", err)
+	}
+
+	// lets pretend the garbage collector is not running
+	c.orphans.since[AutoscalerKind+"/default/gone"] = time.Now().Add(-garbageCollectionTimeout)
+	if c.awaitGarbageCollection(AutoscalerKind, "default/gone", FunctionKind, "default/gone", owned.ObjectMeta) {
+		t.Errorf("expected to stop waiting for the garbage collector after the timeout")
+	}
+	if err := c.destroyAutoscaler("default/gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hpas.Get("gone"); err == nil {
+		t.Errorf("expected the HorizontalPodAutoscaler to be deleted after the timeout")
+	}
+
+	unowned := functionAutoscaler(namedFunction("legacy", ""), false)
+	if c.awaitGarbageCollection(AutoscalerKind, "default/legacy", FunctionKind, "default/legacy", unowned.ObjectMeta) {
+		t.Errorf("expected not to wait for the garbage collector to remove a resource without an owner reference")
+	}
+	hello := namedFunction("hello", "1")
+	c.functionInf.GetIndexer().Add(hello)
+	if c.awaitGarbageCollection(AutoscalerKind, "default/hello", FunctionKind, "default/hello", functionAutoscaler(hello, true).ObjectMeta) {
+		t.Errorf("expected not to wait for the garbage collector while the owner exists")
+	}
+}
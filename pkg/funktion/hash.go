@@ -0,0 +1,85 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// SpecHashAnnotation is the annotation on a generated Deployment or Service holding the hash of
+	// the desired state it was last updated from. The operator only updates the resource when the hash changes
+	SpecHashAnnotation = "funktion.fabric8.io/spec-hash"
+
+	// ConfigChecksumAnnotation is the annotation on the pod template of a generated Deployment holding a checksum
+	// of the configuration mounted into its pods. Kubernetes rolls the pods whenever the configuration changes
+	ConfigChecksumAnnotation = "funktion.fabric8.io/config-checksum"
+)
+
+// specHash returns a hash of the given desired resource. The resource should be rendered without
+// copying anything from the existing resource so that the hash only changes with the desired state
+func specHash(obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("Failed to hash resource: %s", err)
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
+
+// hasSpecHash returns true if the given resource was last updated from the desired state with the given hash
+func hasSpecHash(meta v1.ObjectMeta, hash string) bool {
+	return meta.Annotations != nil && meta.Annotations[SpecHashAnnotation] == hash
+}
+
+func setSpecHash(meta *v1.ObjectMeta, hash string) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[SpecHashAnnotation] = hash
+}
+
+// configChecksum returns a checksum of the values of the given data keys
+func configChecksum(data map[string]string, keys []string) string {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	for _, key := range sorted {
+		if value, ok := data[key]; ok {
+			fmt.Fprintf(h, "%s\x00%d\x00%s", key, len(value), value)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// setConfigChecksum stamps the checksum of the configuration onto the pod template of the Deployment.
+// The pods are rolled by the Deployment so the annotation of the configmapcontroller is removed to avoid
+// rolling them twice
+func setConfigChecksum(deployment *v1beta1.Deployment, checksum string) {
+	delete(deployment.Annotations, ConfigMapControllerAnnotation)
+	template := &deployment.Spec.Template
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[ConfigChecksumAnnotation] = checksum
+}
@@ -0,0 +1,144 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+func hashTestDeployment(image string) *v1beta1.Deployment {
+	return &v1beta1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   "hello",
+			Labels: map[string]string{"app": "hello"},
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "hello", Image: image}},
+				},
+			},
+		},
+	}
+}
+
+func TestSpecHash(t *testing.T) {
+	hash, err := specHash(hashTestDeployment("funktion/nodejs:1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := specHash(hashTestDeployment("funktion/nodejs:1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != hash {
+		t.Errorf("expected the same desired state to have the same hash but got %s and %s", hash, again)
+	}
+	changed, err := specHash(hashTestDeployment("funktion/nodejs:1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == hash {
+		t.Errorf("expected the hash to change with the image")
+	}
+}
+
+func TestSetSpecHash(t *testing.T) {
+	d := hashTestDeployment("funktion/nodejs:1.0")
+	if hasSpecHash(d.ObjectMeta, "") {
+		t.Errorf("expected a resource without annotations not to have a hash")
+	}
+	setSpecHash(&d.ObjectMeta, "abc")
+	if !hasSpecHash(d.ObjectMeta, "abc") {
+		t.Errorf("expected the resource to have the hash abc but got annotations %v", d.Annotations)
+	}
+	if hasSpecHash(d.ObjectMeta, "def") {
+		t.Errorf("expected the resource not to have the hash def")
+	}
+	setSpecHash(&d.ObjectMeta, "def")
+	if !hasSpecHash(d.ObjectMeta, "def") {
+		t.Errorf("expected the hash to be replaced but got annotations %v", d.Annotations)
+	}
+}
+
+func TestConfigChecksum(t *testing.T) {
+	keys := []string{SourceProperty, EnvVarsProperty}
+	data := map[string]string{SourceProperty: "v1", EnvVarsProperty: "FOO=bar", DebugProperty: "true"}
+	checksum := configChecksum(data, keys)
+	if actual := configChecksum(data, []string{EnvVarsProperty, SourceProperty}); actual != checksum {
+		t.Errorf("expected the checksum not to depend on the order of the keys")
+	}
+	data[DebugProperty] = "false"
+	if actual := configChecksum(data, keys); actual != checksum {
+		t.Errorf("expected the checksum to ignore other keys")
+	}
+	data[SourceProperty] = "v2"
+	if actual := configChecksum(data, keys); actual == checksum {
+		t.Errorf("expected the checksum to change with the source")
+	}
+	// lets make sure values can't be shifted between keys without changing the checksum
+	a := configChecksum(map[string]string{SourceProperty: "ab", EnvVarsProperty: ""}, keys)
+	b := configChecksum(map[string]string{SourceProperty: "a", EnvVarsProperty: "b"}, keys)
+	if a == b {
+		t.Errorf("expected different checksums when values move between keys")
+	}
+}
+
+func TestSetConfigChecksum(t *testing.T) {
+	d := hashTestDeployment("funktion/nodejs:1.0")
+	d.Annotations = map[string]string{ConfigMapControllerAnnotation: "hello"}
+	setConfigChecksum(d, "abc")
+	if actual := d.Spec.Template.Annotations[ConfigChecksumAnnotation]; actual != "abc" {
+		t.Errorf("expected the pod template checksum abc but got %s", actual)
+	}
+	if _, ok := d.Annotations[ConfigMapControllerAnnotation]; ok {
+		t.Errorf("expected the configmapcontroller annotation to be removed")
+	}
+}
+
+func TestFlowDeploymentChecksum(t *testing.T) {
+	connector := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "timer"},
+		Data: map[string]string{
+			DeploymentYmlProperty: "spec:\n  template:\n    spec:\n      containers:\n      - image: funktion/timer\n",
+		},
+	}
+	flow := flowConfigMap("timer", validFunktionYml)
+	old := &v1beta1.Deployment{ObjectMeta: v1.ObjectMeta{
+		Annotations: map[string]string{ConfigMapControllerAnnotation: flow.Name},
+	}}
+	d, err := makeFlowDeployment(flow, connector, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := d.Spec.Template.Annotations[ConfigChecksumAnnotation]
+	if len(checksum) == 0 {
+		t.Fatalf("expected the pod template to have a checksum")
+	}
+	if _, ok := d.Annotations[ConfigMapControllerAnnotation]; ok {
+		t.Errorf("expected the configmapcontroller annotation to be removed")
+	}
+	flow.Data[ApplicationPropertiesProperty] = "camel.springboot.name=timer"
+	d, err = makeFlowDeployment(flow, connector, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spec.Template.Annotations[ConfigChecksumAnnotation] == checksum {
+		t.Errorf("expected the checksum to change with the application.properties")
+	}
+}
@@ -0,0 +1,157 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const (
+	// maxSyncDuration is how long a single reconcile may take before the worker is considered stuck
+	maxSyncDuration = 5 * time.Minute
+
+	cacheSyncPollPeriod = 100 * time.Millisecond
+)
+
+// healthState tracks the workers so that we can report whether they are alive
+type healthState struct {
+	lock      sync.Mutex
+	started   int
+	running   int
+	nextID    int
+	busySince map[int]time.Time
+}
+
+// workerStarted registers a new worker returning its id
+func (h *healthState) workerStarted() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.started++
+	h.running++
+	h.nextID++
+	return h.nextID
+}
+
+func (h *healthState) workerStopped(id int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.running--
+	delete(h.busySince, id)
+}
+
+func (h *healthState) syncStarted(id int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.busySince == nil {
+		h.busySince = map[int]time.Time{}
+	}
+	h.busySince[id] = time.Now()
+}
+
+func (h *healthState) syncFinished(id int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.busySince, id)
+}
+
+// check returns an error if a worker has exited or has been stuck reconciling a single resource for too long
+func (h *healthState) check(stopping bool) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.running < h.started && !stopping {
+		return fmt.Errorf("%d of %d workers have exited", h.started-h.running, h.started)
+	}
+	for id, since := range h.busySince {
+		if d := time.Since(since); d > maxSyncDuration {
+			return fmt.Errorf("worker %d has been reconciling the same resource for %v", id, d)
+		}
+	}
+	return nil
+}
+
+// Healthy returns an error if the workers are no longer processing the queue.
+// Followers that are not running any workers are considered healthy
+func (c *Operator) Healthy() error {
+	return c.health.check(c.queue.ShuttingDown())
+}
+
+// Ready returns an error unless all the informer caches have synced
+func (c *Operator) Ready() error {
+	informers := []struct {
+		name string
+		inf  cache.SharedIndexInformer
+	}{
+		{"connector", c.connectorInf},
+		{"flow", c.flowInf},
+		{"runtime", c.runtimeInf},
+		{"function", c.functionInf},
+		{"deployment", c.deploymentInf},
+		{"service", c.serviceInf},
+		{"autoscaler", c.autoscalerInf},
+		{"revision", c.revisionInf},
+	}
+	notSynced := []string{}
+	for _, i := range informers {
+		if !i.inf.HasSynced() {
+			notSynced = append(notSynced, i.name)
+		}
+	}
+	if len(notSynced) > 0 {
+		return fmt.Errorf("informer caches not synced: %s", strings.Join(notSynced, ", "))
+	}
+	return nil
+}
+
+// waitForCacheSync blocks until all the informer caches have synced returning false if stopc is closed first
+func (c *Operator) waitForCacheSync(stopc <-chan struct{}) bool {
+	ticker := time.NewTicker(cacheSyncPollPeriod)
+	defer ticker.Stop()
+	for {
+		if c.Ready() == nil {
+			return true
+		}
+		select {
+		case <-stopc:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthzHandler returns the HTTP handler for the liveness probe
+func (c *Operator) HealthzHandler() http.Handler {
+	return checkHandler(c.Healthy)
+}
+
+// ReadyzHandler returns the HTTP handler for the readiness probe
+func (c *Operator) ReadyzHandler() http.Handler {
+	return checkHandler(c.Ready)
+}
+
+func checkHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+}
@@ -0,0 +1,100 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/funktionio/funktion/pkg/queue"
+
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+// testInformer is an informer which only reports whether it has synced and serves its indexer
+type testInformer struct {
+	cache.SharedIndexInformer
+	synced  bool
+	indexer cache.Indexer
+}
+
+func (i *testInformer) HasSynced() bool {
+	return i.synced
+}
+
+func (i *testInformer) GetIndexer() cache.Indexer {
+	return i.indexer
+}
+
+func (i *testInformer) GetStore() cache.Store {
+	return i.indexer
+}
+
+// newHealthTestOperator returns an Operator whose informers have synced apart from the revision informer if synced is false
+func newHealthTestOperator(synced bool) *Operator {
+	informer := &testInformer{synced: true}
+	return &Operator{
+		connectorInf:  informer,
+		flowInf:       informer,
+		runtimeInf:    informer,
+		functionInf:   informer,
+		deploymentInf: informer,
+		serviceInf:    informer,
+		autoscalerInf: informer,
+		revisionInf:   &testInformer{synced: synced},
+		queue:         queue.New(),
+	}
+}
+
+func TestReadyzHandler(t *testing.T) {
+	expectCheck(t, newHealthTestOperator(false).ReadyzHandler(), http.StatusServiceUnavailable, "revision")
+	expectCheck(t, newHealthTestOperator(true).ReadyzHandler(), http.StatusOK, "ok")
+}
+
+func TestHealthzHandler(t *testing.T) {
+	c := newHealthTestOperator(true)
+	id := c.health.workerStarted()
+	c.health.syncStarted(id)
+	expectCheck(t, c.HealthzHandler(), http.StatusOK, "ok")
+
+	c.health.busySince[id] = time.Now().Add(-maxSyncDuration - time.Minute)
+	expectCheck(t, c.HealthzHandler(), http.StatusServiceUnavailable, "has been reconciling the same resource")
+
+	c.health.syncFinished(id)
+	c.health.workerStopped(id)
+	expectCheck(t, c.HealthzHandler(), http.StatusServiceUnavailable, "1 of 1 workers have exited")
+
+	// followers and stopping operators shut down the queue so their workers exit without being unhealthy
+	c.queue.ShutDown()
+	expectCheck(t, c.HealthzHandler(), http.StatusOK, "ok")
+}
+
+func expectCheck(t *testing.T, handler http.Handler, expectedCode int, expectedBody string) {
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != expectedCode {
+		t.Errorf("expected status %d but got %d: %s", expectedCode, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), expectedBody) {
+		t.Errorf("expected the body to contain %q but got %q", expectedBody, w.Body.String())
+	}
+}
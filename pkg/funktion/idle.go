@@ -0,0 +1,140 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// IdleTimeoutProperty is the data key for how long a Function may receive no requests before it is scaled to zero.
+	// A Runtime may specify the default for its Functions. Functions are never scaled to zero if it is not specified.
+	// Only the requests proxied by the activator count so the Service of such a Function is not exposed and it is
+	// only scaled to zero when the operator runs the activator
+	IdleTimeoutProperty = "idleTimeout"
+
+	// LastActivityAnnotation is the annotation on the Deployment of a Function holding the time the
+	// activator last proxied a request to it
+	LastActivityAnnotation = "funktion.fabric8.io/last-activity"
+
+	// ReasonScaledToZero is the Event reason when an idle Function has been scaled to zero
+	ReasonScaledToZero = "ScaledToZero"
+
+	// ReasonIdleTimeoutIgnored is the Event reason when a Function has an idle timeout but the activator is not running
+	ReasonIdleTimeoutIgnored = "IdleTimeoutIgnored"
+)
+
+// idleTimeoutForFunction returns the idle timeout of the given Function falling back to the default
+// of its Runtime. Zero is returned if the Function should never be scaled to zero
+func idleTimeoutForFunction(function *v1.ConfigMap, runtime *v1.ConfigMap) (time.Duration, error) {
+	text := strings.TrimSpace(function.Data[IdleTimeoutProperty])
+	kind, owner := FunctionKind, function
+	if len(text) == 0 && runtime != nil {
+		text = strings.TrimSpace(runtime.Data[IdleTimeoutProperty])
+		kind, owner = RuntimeKind, runtime
+	}
+	if len(text) == 0 {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s has invalid `%s`: %s", kind, owner.Name, IdleTimeoutProperty, err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("%s %s has a negative `%s`", kind, owner.Name, IdleTimeoutProperty)
+	}
+	return timeout, nil
+}
+
+// lastActivity returns when the Deployment last received a request through the activator
+// or when it was created if it has not received any
+func lastActivity(deployment *v1beta1.Deployment) time.Time {
+	if deployment.Annotations != nil {
+		if t, err := time.Parse(time.RFC3339, deployment.Annotations[LastActivityAnnotation]); err == nil {
+			return t
+		}
+	}
+	return deployment.CreationTimestamp.Time
+}
+
+func deploymentReplicas(deployment *v1beta1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// idleScaler scales the Deployments of Functions which have been idle for longer than their timeout to zero
+type idleScaler struct {
+	kclient kubernetes.Interface
+	now     func() time.Time
+}
+
+// isIdle returns true if the Deployment is running and has not received a request within the timeout
+func (s *idleScaler) isIdle(deployment *v1beta1.Deployment, timeout time.Duration) bool {
+	if timeout <= 0 || deploymentReplicas(deployment) == 0 {
+		return false
+	}
+	return s.now().Sub(lastActivity(deployment)) >= timeout
+}
+
+// scaleDownIfIdle scales the Deployment to zero if it is idle returning true if it did so
+func (s *idleScaler) scaleDownIfIdle(deployment *v1beta1.Deployment, timeout time.Duration) (bool, error) {
+	if !s.isIdle(deployment, timeout) {
+		return false, nil
+	}
+	deployments := s.kclient.Extensions().Deployments(deployment.Namespace)
+	latest, err := deployments.Get(deployment.Name)
+	if err != nil {
+		return false, err
+	}
+	// the activator may have received a request since the cache was updated
+	if !s.isIdle(latest, timeout) {
+		return false, nil
+	}
+	replicas := int32(0)
+	latest.Spec.Replicas = &replicas
+	if _, err := deployments.Update(latest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reconcileIdle scales the Deployment of the given Function to zero once it has been idle for its timeout
+func (c *Operator) reconcileIdle(function *v1.ConfigMap, runtime *v1.ConfigMap, deployment *v1beta1.Deployment) error {
+	timeout, err := idleTimeoutForFunction(function, runtime)
+	if err != nil || timeout == 0 || deployment == nil {
+		return err
+	}
+	if c.idle == nil {
+		// without the activator nothing records the requests of the Function or scales it up again
+		c.recorder.Eventf(resourceReference(function), v1.EventTypeWarning, ReasonIdleTimeoutIgnored, "Not scaling Deployment %s to zero when idle as the activator is not running", deployment.Name)
+		return nil
+	}
+	scaled, err := c.idle.scaleDownIfIdle(deployment, timeout)
+	if err != nil {
+		return fmt.Errorf("scale down idle deployment: %s", err)
+	}
+	if scaled {
+		c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonScaledToZero, "Scaled Deployment %s to zero after being idle for %v", deployment.Name, timeout)
+	}
+	return nil
+}
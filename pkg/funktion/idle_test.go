@@ -0,0 +1,145 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+var idleTestNow = time.Date(2016, time.December, 1, 12, 0, 0, 0, time.UTC)
+
+func functionDeployment(replicas int32, lastActivity time.Time) *v1beta1.Deployment {
+	return &v1beta1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              RevisionName("hello", 1),
+			Namespace:         "default",
+			CreationTimestamp: unversioned.NewTime(idleTestNow.Add(-24 * time.Hour)),
+			Labels: map[string]string{
+				ManagedByLabel: ManagedByOperator,
+				OwnerKindLabel: FunctionKind,
+				NameLabel:      "hello",
+				RevisionLabel:  "1",
+			},
+			Annotations: map[string]string{
+				OwnerAnnotation:        "hello",
+				LastActivityAnnotation: lastActivity.Format(time.RFC3339),
+			},
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+	}
+}
+
+func TestIdleTimeoutForFunction(t *testing.T) {
+	runtime := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "nodejs"},
+		Data:       map[string]string{IdleTimeoutProperty: "10m"},
+	}
+	tests := []struct {
+		function map[string]string
+		runtime  *v1.ConfigMap
+		expected time.Duration
+	}{
+		{map[string]string{}, nil, 0},
+		{map[string]string{}, runtime, 10 * time.Minute},
+		{map[string]string{IdleTimeoutProperty: "30s"}, runtime, 30 * time.Second},
+		{map[string]string{IdleTimeoutProperty: "0"}, runtime, 0},
+	}
+	for _, test := range tests {
+		actual, err := idleTimeoutForFunction(autoscaledFunction(test.function), test.runtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != test.expected {
+			t.Errorf("expected idle timeout %v for %v but got %v", test.expected, test.function, actual)
+		}
+	}
+	for _, text := range []string{"soon", "-5m"} {
+		if _, err := idleTimeoutForFunction(autoscaledFunction(map[string]string{IdleTimeoutProperty: text}), nil); err == nil {
+			t.Errorf("expected an error for idle timeout %s", text)
+		}
+	}
+	runtime.Data[IdleTimeoutProperty] = "soon"
+	if _, err := idleTimeoutForFunction(autoscaledFunction(map[string]string{}), runtime); err == nil || !strings.HasPrefix(err.Error(), "Runtime nodejs ") {
+		t.Errorf("expected an error naming the Runtime but got %v", err)
+	}
+}
+
+func TestScaleDownIfIdle(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		activity time.Time
+		scaled   bool
+	}{
+		{"idle", 2, idleTestNow.Add(-10 * time.Minute), true},
+		{"recently active", 1, idleTestNow.Add(-time.Minute), false},
+		{"already scaled to zero", 0, idleTestNow.Add(-time.Hour), false},
+	}
+	for _, test := range tests {
+		kclient := fake.NewSimpleClientset(functionDeployment(test.replicas, test.activity))
+		s := &idleScaler{
+			kclient: kclient,
+			now:     func() time.Time { return idleTestNow },
+		}
+		deployment, err := kclient.Extensions().Deployments("default").Get("hello-v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		scaled, err := s.scaleDownIfIdle(deployment, 5*time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if scaled != test.scaled {
+			t.Errorf("%s: expected scaled to be %v but was %v", test.name, test.scaled, scaled)
+		}
+		deployment, err = kclient.Extensions().Deployments("default").Get("hello-v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := test.replicas
+		if test.scaled {
+			expected = 0
+		}
+		if actual := deploymentReplicas(deployment); actual != expected {
+			t.Errorf("%s: expected %d replicas but got %d", test.name, expected, actual)
+		}
+	}
+}
+
+func TestScaleDownUsesLatestActivity(t *testing.T) {
+	// the cached Deployment is stale as the activator has since recorded a request
+	stale := functionDeployment(1, idleTestNow.Add(-time.Hour))
+	kclient := fake.NewSimpleClientset(functionDeployment(1, idleTestNow.Add(-time.Second)))
+	s := &idleScaler{
+		kclient: kclient,
+		now:     func() time.Time { return idleTestNow },
+	}
+	scaled, err := s.scaleDownIfIdle(stale, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scaled {
+		t.Errorf("expected a recently active function not to be scaled down")
+	}
+}
@@ -0,0 +1,58 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const (
+	// runtimeIndex is the name of the index of Functions by the key of their Runtime
+	runtimeIndex = "runtime"
+	// connectorIndex is the name of the index of Flows by the key of their Connector
+	connectorIndex = "connector"
+)
+
+// referenceKey returns the informer key of a resource referenced by name from a resource in the given namespace
+func referenceKey(ns string, name string) string {
+	if len(ns) > 0 && !strings.Contains(name, "/") {
+		return ns + "/" + name
+	}
+	return name
+}
+
+// runtimeIndexFunc indexes Functions by the key of the Runtime in their runtime label
+func runtimeIndexFunc(obj interface{}) ([]string, error) {
+	return labelReferenceIndex(obj, RuntimeLabel)
+}
+
+// connectorIndexFunc indexes Flows by the key of the Connector in their connector label
+func connectorIndexFunc(obj interface{}) ([]string, error) {
+	return labelReferenceIndex(obj, ConnectorLabel)
+}
+
+func labelReferenceIndex(obj interface{}, label string) ([]string, error) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok || cm.Labels == nil {
+		return []string{}, nil
+	}
+	name := cm.Labels[label]
+	if len(name) == 0 {
+		return []string{}, nil
+	}
+	return []string{referenceKey(cm.Namespace, name)}, nil
+}
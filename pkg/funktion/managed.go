@@ -0,0 +1,285 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	autoscaling "k8s.io/client-go/1.5/pkg/apis/autoscaling/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const (
+	// ManagedByLabel is the label on the Deployments, Services and other resources the operator creates for
+	// Functions and Flows. The operator only watches the resources which have this label
+	ManagedByLabel = "funktion.fabric8.io/managed-by"
+	// ManagedByOperator is the value of the ManagedByLabel
+	ManagedByOperator = "funktion-operator"
+	// OwnerKindLabel is the label holding the kind of the Function or Flow a managed resource belongs to
+	OwnerKindLabel = "funktion.fabric8.io/owner-kind"
+	// OwnerAnnotation is the annotation holding the name of the Function or Flow a managed resource belongs to.
+	// The name of the resource differs from the name of its owner if the name was already taken
+	OwnerAnnotation = "funktion.fabric8.io/owner"
+
+	// ownerIndex is the name of the index of managed resources by the kind and key of their owner
+	ownerIndex = "owner"
+)
+
+// kindOf returns the kind of the given label based ConfigMap or custom resource
+func kindOf(cm *v1.ConfigMap) string {
+	if IsCustomResource(cm) {
+		return cm.Kind
+	}
+	return cm.Labels[KindLabel]
+}
+
+// isLegacyResource returns true if the resource was created for the owner by an operator which predates the
+// managed-by label. Such resources are named after their owner and labelled with its kind by copying its labels
+// but only have an owner reference when created by an operator which already set them
+func isLegacyResource(objectMeta v1.ObjectMeta, owner *v1.ConfigMap) bool {
+	if len(objectMeta.Labels[ManagedByLabel]) > 0 || objectMeta.Name != owner.Name {
+		return false
+	}
+	if len(objectMeta.OwnerReferences) > 0 {
+		return isOwnedBy(objectMeta, owner)
+	}
+	return len(objectMeta.Labels[KindLabel]) > 0 && objectMeta.Labels[KindLabel] == kindOf(owner)
+}
+
+// setManagedBy labels the given resource as managed by the operator for the given Function or Flow
+func setManagedBy(objectMeta *v1.ObjectMeta, owner *v1.ConfigMap) {
+	if objectMeta.Labels == nil {
+		objectMeta.Labels = map[string]string{}
+	}
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+	objectMeta.Labels[ManagedByLabel] = ManagedByOperator
+	objectMeta.Labels[OwnerKindLabel] = kindOf(owner)
+	objectMeta.Annotations[OwnerAnnotation] = owner.Name
+}
+
+// managedOwner returns the kind and key of the Function or Flow the given managed resource belongs to
+func managedOwner(objectMeta v1.ObjectMeta) (ResourceKey, bool) {
+	kind := objectMeta.Labels[OwnerKindLabel]
+	name := objectMeta.Annotations[OwnerAnnotation]
+	if objectMeta.Labels[ManagedByLabel] != ManagedByOperator || len(kind) == 0 || len(name) == 0 {
+		return ResourceKey{}, false
+	}
+	return ResourceKey{Kind: kind, Key: referenceKey(objectMeta.Namespace, name)}, true
+}
+
+// OwnerName returns the name of the Function or Flow of the given kind which the Deployment, Service or
+// HorizontalPodAutoscaler belongs to or an empty string if it belongs to something else. Resources created
+// before the operator labelled its resources are assumed to be named after their owner
+func OwnerName(objectMeta v1.ObjectMeta, kind string) string {
+	if objectMeta.Labels[ManagedByLabel] != ManagedByOperator {
+		if RevisionNumber(objectMeta) > 0 {
+			return objectMeta.Labels[NameLabel]
+		}
+		return objectMeta.Name
+	}
+	if owner, ok := managedOwner(objectMeta); ok && owner.Kind == kind {
+		return objectMeta.Annotations[OwnerAnnotation]
+	}
+	return ""
+}
+
+// managedObjectMeta returns the metadata of a Deployment, Service, HorizontalPodAutoscaler or revision from an informer
+func managedObjectMeta(obj interface{}) (v1.ObjectMeta, bool) {
+	switch o := obj.(type) {
+	case *v1beta1.Deployment:
+		return o.ObjectMeta, true
+	case *v1.Service:
+		return o.ObjectMeta, true
+	case *autoscaling.HorizontalPodAutoscaler:
+		return o.ObjectMeta, true
+	case *v1.ConfigMap:
+		// only the revisions of a Function are managed ConfigMaps
+		if RevisionNumber(o.ObjectMeta) == 0 {
+			return v1.ObjectMeta{}, false
+		}
+		return o.ObjectMeta, true
+	case cache.DeletedFinalStateUnknown:
+		return managedObjectMeta(o.Obj)
+	}
+	return v1.ObjectMeta{}, false
+}
+
+func ownerIndexKey(kind string, key string) string {
+	return kind + "/" + key
+}
+
+// ownerIndexFunc indexes managed resources by the kind and key of the Function or Flow they belong to
+func ownerIndexFunc(obj interface{}) ([]string, error) {
+	objectMeta, ok := managedObjectMeta(obj)
+	if !ok {
+		return []string{}, nil
+	}
+	owner, ok := managedOwner(objectMeta)
+	if !ok {
+		return []string{}, nil
+	}
+	return []string{ownerIndexKey(owner.Kind, owner.Key)}, nil
+}
+
+// clashName returns the name used for a resource of a Function or Flow when its own name is already taken
+func clashName(name string, kind string) string {
+	return name + "-" + strings.ToLower(kind)
+}
+
+// resolveName returns the name to create a resource of the owner with when it is not in the cache. A resource
+// of that name which is owned by the owner, or which was created before the operator set owner references and
+// labels and carries the kind label of the owner, is adopted by returning true. If the name belongs to something
+// else then the name suffixed with the kind of the owner is used instead
+func resolveName(owner *v1.ConfigMap, resourceKind string, name string, get func(name string) (*v1.ObjectMeta, error)) (string, bool, error) {
+	alternative := clashName(name, kindOf(owner))
+	for _, candidate := range []string{name, alternative} {
+		objectMeta, err := get(candidate)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return candidate, false, nil
+			}
+			return "", false, err
+		}
+		if isOwnedBy(*objectMeta, owner) || isLegacyResource(*objectMeta, owner) {
+			return candidate, true, nil
+		}
+	}
+	return "", false, fmt.Errorf("Cannot create the %s of %s %s as there are other %ss called %s and %s", resourceKind, kindOf(owner), owner.Name, resourceKind, name, alternative)
+}
+
+// managedBy returns the resources of the given informer which belong to the Function or Flow
+func managedBy(inf cache.SharedIndexInformer, ownerKind string, ownerKey string) ([]interface{}, error) {
+	return inf.GetIndexer().ByIndex(ownerIndex, ownerIndexKey(ownerKind, ownerKey))
+}
+
+// findDeployment returns the Deployment running the given revision of the Function or Flow if it exists
+// along with its name. Otherwise the name to create the Deployment with is returned
+func (c *Operator) findDeployment(owner *v1.ConfigMap, name string, revision int) (*v1beta1.Deployment, string, error) {
+	objs, err := managedBy(c.deploymentInf, kindOf(owner), referenceKey(owner.Namespace, owner.Name))
+	if err != nil {
+		return nil, "", err
+	}
+	for _, obj := range objs {
+		d := obj.(*v1beta1.Deployment)
+		if RevisionNumber(d.ObjectMeta) == revision {
+			return d, d.Name, nil
+		}
+	}
+	deployments := c.kclient.Extensions().Deployments(owner.Namespace)
+	var found *v1beta1.Deployment
+	name, adopt, err := resolveName(owner, DeploymentKind, name, func(n string) (*v1.ObjectMeta, error) {
+		d, err := deployments.Get(n)
+		if err != nil {
+			return nil, err
+		}
+		found = d
+		return &d.ObjectMeta, nil
+	})
+	if err != nil || !adopt {
+		return nil, name, err
+	}
+	return found, name, nil
+}
+
+// findService returns the Service of the Function or Flow if it exists along with its name.
+// Otherwise the name to create the Service with is returned
+func (c *Operator) findService(owner *v1.ConfigMap) (*v1.Service, string, error) {
+	objs, err := managedBy(c.serviceInf, kindOf(owner), referenceKey(owner.Namespace, owner.Name))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objs) > 0 {
+		s := objs[0].(*v1.Service)
+		return s, s.Name, nil
+	}
+	services := c.kclient.Core().Services(owner.Namespace)
+	var found *v1.Service
+	name, adopt, err := resolveName(owner, ServiceKind, owner.Name, func(n string) (*v1.ObjectMeta, error) {
+		s, err := services.Get(n)
+		if err != nil {
+			return nil, err
+		}
+		found = s
+		return &s.ObjectMeta, nil
+	})
+	if err != nil || !adopt {
+		return nil, name, err
+	}
+	return found, name, nil
+}
+
+// findAutoscaler returns the HorizontalPodAutoscaler of the Function if it exists along with its name.
+// Otherwise the name to create the HorizontalPodAutoscaler with is returned
+func (c *Operator) findAutoscaler(function *v1.ConfigMap) (*autoscaling.HorizontalPodAutoscaler, string, error) {
+	objs, err := managedBy(c.autoscalerInf, kindOf(function), referenceKey(function.Namespace, function.Name))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objs) > 0 {
+		hpa := objs[0].(*autoscaling.HorizontalPodAutoscaler)
+		return hpa, hpa.Name, nil
+	}
+	hpas := c.kclient.Autoscaling().HorizontalPodAutoscalers(function.Namespace)
+	var found *autoscaling.HorizontalPodAutoscaler
+	name, adopt, err := resolveName(function, AutoscalerKind, function.Name, func(n string) (*v1.ObjectMeta, error) {
+		hpa, err := hpas.Get(n)
+		if err != nil {
+			return nil, err
+		}
+		found = hpa
+		return &hpa.ObjectMeta, nil
+	})
+	if err != nil || !adopt {
+		return nil, name, err
+	}
+	return found, name, nil
+}
+
+// ownerOf returns the Function or Flow which the managed resource belongs to along with its kind. The owner
+// is resolved from the same labels and annotations the owner index uses
+func (c *Operator) ownerOf(obj interface{}) (*v1.ConfigMap, string) {
+	objectMeta, ok := managedObjectMeta(obj)
+	if !ok {
+		return nil, ""
+	}
+	owner, ok := managedOwner(objectMeta)
+	if !ok {
+		return nil, ""
+	}
+	var inf cache.SharedIndexInformer
+	switch owner.Kind {
+	case FunctionKind:
+		inf = c.functionInf
+	case FlowKind:
+		inf = c.flowInf
+	default:
+		return nil, ""
+	}
+	o, exists, err := inf.GetStore().GetByKey(owner.Key)
+	if err != nil {
+		c.logger.Log("msg", owner.Kind+" lookup failed", "err", err)
+		return nil, ""
+	}
+	if !exists {
+		return nil, ""
+	}
+	return o.(*v1.ConfigMap), owner.Kind
+}
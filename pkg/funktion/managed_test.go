@@ -0,0 +1,131 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+func managedFlow(name string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       "1234",
+			Labels:    map[string]string{KindLabel: FlowKind},
+		},
+	}
+}
+
+func TestOwnerIndex(t *testing.T) {
+	flow := managedFlow("timer")
+	d := &v1beta1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "timer-flow", Namespace: "default"}}
+	if keys, _ := ownerIndexFunc(d); len(keys) != 0 {
+		t.Errorf("expected an unmanaged Deployment not to be indexed but got %v", keys)
+	}
+	setManagedBy(&d.ObjectMeta, flow)
+	keys, err := ownerIndexFunc(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := ownerIndexKey(FlowKind, "default/timer"); len(keys) != 1 || keys[0] != expected {
+		t.Errorf("expected index keys [%s] but got %v", expected, keys)
+	}
+	if name := OwnerName(d.ObjectMeta, FlowKind); name != "timer" {
+		t.Errorf("expected the owner timer but got %s", name)
+	}
+	if name := OwnerName(d.ObjectMeta, FunctionKind); len(name) > 0 {
+		t.Errorf("expected no Function to own the Deployment of a Flow but got %s", name)
+	}
+}
+
+func TestIsLegacyResource(t *testing.T) {
+	flow := managedFlow("timer")
+	other := managedFlow("other")
+	other.UID = "5678"
+	owned := v1.ObjectMeta{Name: "timer"}
+	setOwnerReference(&owned, ownerReference(flow))
+	managed := v1.ObjectMeta{Name: "timer"}
+	setManagedBy(&managed, flow)
+	ownedByOther := v1.ObjectMeta{Name: "timer", Labels: map[string]string{KindLabel: FlowKind}}
+	setOwnerReference(&ownedByOther, ownerReference(other))
+
+	tests := []struct {
+		objectMeta v1.ObjectMeta
+		legacy     bool
+	}{
+		{v1.ObjectMeta{Name: "timer", Labels: map[string]string{KindLabel: FlowKind}}, true},
+		{owned, true},
+		{v1.ObjectMeta{Name: "timer"}, false},
+		{v1.ObjectMeta{Name: "timer", Labels: map[string]string{KindLabel: FunctionKind}}, false},
+		{v1.ObjectMeta{Name: "timer-flow", Labels: map[string]string{KindLabel: FlowKind}}, false},
+		{managed, false},
+		{ownedByOther, false},
+	}
+	for i, test := range tests {
+		if actual := isLegacyResource(test.objectMeta, flow); actual != test.legacy {
+			t.Errorf("expected %v for resource %d but got %v", test.legacy, i, actual)
+		}
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	flow := managedFlow("timer")
+	other := v1.ObjectMeta{Name: "timer"}
+	owned := v1.ObjectMeta{Name: "timer-flow"}
+	setOwnerReference(&owned, ownerReference(flow))
+	// created by an operator which predates owner references and the managed-by label
+	legacy := v1.ObjectMeta{Name: "timer", Labels: map[string]string{KindLabel: FlowKind}}
+	otherKind := v1.ObjectMeta{Name: "timer", Labels: map[string]string{KindLabel: FunctionKind}}
+	notFound := errors.NewNotFound(unversioned.GroupResource{Resource: "deployments"}, "")
+
+	tests := []struct {
+		existing map[string]v1.ObjectMeta
+		name     string
+		adopt    bool
+	}{
+		{map[string]v1.ObjectMeta{}, "timer", false},
+		{map[string]v1.ObjectMeta{"timer": other}, "timer-flow", false},
+		{map[string]v1.ObjectMeta{"timer": other, "timer-flow": owned}, "timer-flow", true},
+		{map[string]v1.ObjectMeta{"timer": legacy}, "timer", true},
+		{map[string]v1.ObjectMeta{"timer": otherKind}, "timer-flow", false},
+	}
+	for _, test := range tests {
+		name, adopt, err := resolveName(flow, DeploymentKind, "timer", func(n string) (*v1.ObjectMeta, error) {
+			if objectMeta, ok := test.existing[n]; ok {
+				return &objectMeta, nil
+			}
+			return nil, notFound
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != test.name || adopt != test.adopt {
+			t.Errorf("expected %s adopt %v but got %s adopt %v", test.name, test.adopt, name, adopt)
+		}
+	}
+
+	_, _, err := resolveName(flow, DeploymentKind, "timer", func(n string) (*v1.ObjectMeta, error) {
+		return &other, nil
+	})
+	if err == nil {
+		t.Errorf("expected an error when both names are taken")
+	}
+}
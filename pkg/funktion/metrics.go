@@ -0,0 +1,134 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const metricsNamespace = "funktion_operator"
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_total",
+		Help:      "Number of reconciles by resource kind",
+	}, []string{"kind"})
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of failed reconciles by resource kind",
+	}, []string{"kind"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Time taken to reconcile a resource by resource kind",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"kind"})
+
+	queueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "queue_depth"),
+		"Number of resources waiting to be reconciled",
+		nil, nil,
+	)
+	informerCacheSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "informer_cache_size"),
+		"Number of resources in each informer cache",
+		[]string{"informer"}, nil,
+	)
+	managedDeploymentsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "managed_deployments"),
+		"Number of Deployments managed for Functions and Flows",
+		nil, nil,
+	)
+	managedServicesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "managed_services"),
+		"Number of Services managed for Functions and Flows",
+		nil, nil,
+	)
+)
+
+// observeReconcile records the outcome of reconciling a resource of the given kind
+func observeReconcile(kind string, start time.Time, err error) {
+	reconcileTotal.WithLabelValues(kind).Inc()
+	reconcileDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(kind).Inc()
+	}
+}
+
+// RegisterMetrics registers the reconcile metrics and the gauges describing the queue and informer caches of the Operator
+func (c *Operator) RegisterMetrics(r prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{reconcileTotal, reconcileErrors, reconcileDuration, &operatorCollector{c: c}} {
+		if err := r.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// operatorCollector computes the gauges from the current state of the Operator on each scrape
+type operatorCollector struct {
+	c *Operator
+}
+
+func (oc *operatorCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- informerCacheSizeDesc
+	ch <- managedDeploymentsDesc
+	ch <- managedServicesDesc
+}
+
+func (oc *operatorCollector) Collect(ch chan<- prometheus.Metric) {
+	c := oc.c
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(c.queue.Len()))
+
+	informers := map[string]cache.SharedIndexInformer{
+		"connector":  c.connectorInf,
+		"flow":       c.flowInf,
+		"runtime":    c.runtimeInf,
+		"function":   c.functionInf,
+		"deployment": c.deploymentInf,
+		"service":    c.serviceInf,
+		"autoscaler": c.autoscalerInf,
+		"revision":   c.revisionInf,
+	}
+	for name, inf := range informers {
+		ch <- prometheus.MustNewConstMetric(informerCacheSizeDesc, prometheus.GaugeValue, float64(len(inf.GetStore().ListKeys())), name)
+	}
+
+	ch <- prometheus.MustNewConstMetric(managedDeploymentsDesc, prometheus.GaugeValue, float64(c.countManaged(c.deploymentInf)))
+	ch <- prometheus.MustNewConstMetric(managedServicesDesc, prometheus.GaugeValue, float64(c.countManaged(c.serviceInf)))
+}
+
+// countManaged counts the resources in the given informer whose Function or Flow still exists
+func (c *Operator) countManaged(inf cache.SharedIndexInformer) int {
+	count := 0
+	for _, obj := range inf.GetStore().List() {
+		objectMeta, ok := managedObjectMeta(obj)
+		if !ok {
+			continue
+		}
+		if owner, ok := managedOwner(objectMeta); ok && c.ownerExists(owner.Kind, owner.Key) {
+			count++
+		}
+	}
+	return count
+}
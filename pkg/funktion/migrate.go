@@ -0,0 +1,135 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+
+	"github.com/funktionio/funktion/pkg/client"
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// MigrateConfigMap converts the given label based ConfigMap into a custom resource of the same name.
+//
+// The ConfigMap of a Function or Flow is kept without its kind label as the data ConfigMap of the custom
+// resource so that the pods of the existing Deployment keep their volume. The operator then adopts the
+// Deployment and Service without recreating them. The ConfigMap of a Runtime or Connector is removed.
+// Migrating a ConfigMap which has already been migrated completes any step which did not finish
+func MigrateConfigMap(kclient kubernetes.Interface, tclient *client.Client, cm *v1.ConfigMap) error {
+	kind := cm.Labels[KindLabel]
+	if !customResourceExists(tclient, kind, cm.Namespace, cm.Name) {
+		if _, err := NewResourceClient(kclient, tclient, cm.Namespace, kind).Create(cm); err != nil {
+			return fmt.Errorf("Failed to create %s %s/%s: %v", kind, cm.Namespace, cm.Name, err)
+		}
+	}
+
+	cms := kclient.Core().ConfigMaps(cm.Namespace)
+	if kind == RuntimeKind || kind == ConnectorKind {
+		if err := cms.Delete(cm.Name, nil); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Failed to delete ConfigMap %s/%s: %v", cm.Namespace, cm.Name, err)
+		}
+		return nil
+	}
+
+	latest, err := cms.Get(cm.Name)
+	if err != nil {
+		return err
+	}
+	delete(latest.Labels, KindLabel)
+	for _, key := range statusAnnotations {
+		delete(latest.Annotations, key)
+	}
+	// the data ConfigMap is removed along with the custom resource so it must not wait on the operator
+	removeFinalizer(&latest.ObjectMeta, CleanupFinalizer)
+	if _, err = cms.Update(latest); err != nil {
+		return fmt.Errorf("Failed to remove label %s from ConfigMap %s/%s: %v", KindLabel, cm.Namespace, cm.Name, err)
+	}
+	return nil
+}
+
+// RollbackCustomResource converts the ConfigMap form of a custom resource back into a label based ConfigMap
+// and deletes the custom resource. The Deployment and Service are orphaned rather than deleted so that
+// the operator adopts them again from the ConfigMap
+func RollbackCustomResource(kclient kubernetes.Interface, tclient *client.Client, form *v1.ConfigMap) error {
+	kind := form.Kind
+	cms := kclient.Core().ConfigMaps(form.Namespace)
+	old, err := cms.Get(form.Name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		cm := &v1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        form.Name,
+				Namespace:   form.Namespace,
+				Labels:      copyStringMap(form.Labels),
+				Annotations: copyStringMap(form.Annotations),
+			},
+			Data: copyStringMap(form.Data),
+		}
+		if _, err = cms.Create(cm); err != nil {
+			return fmt.Errorf("Failed to create ConfigMap %s/%s: %v", form.Namespace, form.Name, err)
+		}
+	} else if old.Labels[KindLabel] != kind {
+		// lets turn the data ConfigMap back into the label based ConfigMap
+		if old.Labels == nil {
+			old.Labels = map[string]string{}
+		}
+		for k, v := range form.Labels {
+			old.Labels[k] = v
+		}
+		if old.Annotations == nil {
+			old.Annotations = map[string]string{}
+		}
+		for k, v := range form.Annotations {
+			old.Annotations[k] = v
+		}
+		old.Data = copyStringMap(form.Data)
+		refs := []v1.OwnerReference{}
+		for _, ref := range old.OwnerReferences {
+			if ref.APIVersion != spec.APIVersion {
+				refs = append(refs, ref)
+			}
+		}
+		old.OwnerReferences = refs
+		if _, err = cms.Update(old); err != nil {
+			return fmt.Errorf("Failed to restore ConfigMap %s/%s: %v", form.Namespace, form.Name, err)
+		}
+	}
+
+	orphan := true
+	opts := &api.DeleteOptions{OrphanDependents: &orphan}
+	switch kind {
+	case FunctionKind:
+		err = tclient.Functions(form.Namespace).Delete(form.Name, opts)
+	case FlowKind:
+		err = tclient.Flows(form.Namespace).Delete(form.Name, opts)
+	case RuntimeKind:
+		err = tclient.Runtimes(form.Namespace).Delete(form.Name, opts)
+	case ConnectorKind:
+		err = tclient.Connectors(form.Namespace).Delete(form.Name, opts)
+	default:
+		return fmt.Errorf("Unknown kind %s", kind)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete %s %s/%s: %v", kind, form.Namespace, form.Name, err)
+	}
+	return nil
+}
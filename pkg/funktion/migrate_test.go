@@ -0,0 +1,152 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"reflect"
+	"testing"
+
+	clientfake "github.com/funktionio/funktion/pkg/client/fake"
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+func TestMigrateAndRollbackFunction(t *testing.T) {
+	data := map[string]string{
+		SourceProperty:  "module.exports = function(context, callback) { callback(200, 'Hello'); };",
+		EnvVarsProperty: "GREETING=hello",
+	}
+	cm := functionConfigMap("nodejs", copyStringMap(data))
+	cm.Labels["team"] = "payments"
+	cm.Finalizers = []string{CleanupFinalizer}
+	kclient := fake.NewSimpleClientset(cm)
+	server := clientfake.NewServer()
+	defer server.Close()
+	tclient := server.Client()
+
+	if err := MigrateConfigMap(kclient, tclient, cm); err != nil {
+		t.Fatal(err)
+	}
+	fn, err := tclient.Functions("default").Get("hello")
+	if err != nil {
+		t.Fatalf("expected the Function to be created but got %v", err)
+	}
+	if fn.Labels["team"] != "payments" || fn.Spec.Runtime != "nodejs" || fn.Spec.Source != data[SourceProperty] {
+		t.Errorf("expected the Function to keep the labels and source but got %+v", fn)
+	}
+	dataConfigMap, err := kclient.Core().ConfigMaps("default").Get("hello")
+	if err != nil {
+		t.Fatalf("expected the ConfigMap to be kept as the data ConfigMap but got %v", err)
+	}
+	if _, ok := dataConfigMap.Labels[KindLabel]; ok {
+		t.Errorf("expected the %s label to be removed from the data ConfigMap", KindLabel)
+	}
+	if hasFinalizer(dataConfigMap.ObjectMeta, CleanupFinalizer) {
+		t.Errorf("expected the cleanup finalizer to be removed from the data ConfigMap")
+	}
+
+	// migrating again completes the migration without creating the Function twice
+	if err := MigrateConfigMap(kclient, tclient, cm); err != nil {
+		t.Fatalf("expected migrating again to succeed but got %v", err)
+	}
+
+	form, err := NewResourceClient(kclient, tclient, "default", FunctionKind).Get("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsCustomResource(form) {
+		t.Fatalf("expected the custom resource to hide the data ConfigMap")
+	}
+	if err := RollbackCustomResource(kclient, tclient, form); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tclient.Functions("default").Get("hello"); !errors.IsNotFound(err) {
+		t.Errorf("expected the Function to be deleted but got %v", err)
+	}
+	restored, err := kclient.Core().ConfigMaps("default").Get("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLabels := map[string]string{
+		KindLabel:    FunctionKind,
+		RuntimeLabel: "nodejs",
+		"team":       "payments",
+	}
+	if !reflect.DeepEqual(restored.Labels, expectedLabels) {
+		t.Errorf("expected the labels %v but got %v", expectedLabels, restored.Labels)
+	}
+	if !reflect.DeepEqual(restored.Data, data) {
+		t.Errorf("expected the data %v but got %v", data, restored.Data)
+	}
+	for _, ref := range restored.OwnerReferences {
+		if ref.APIVersion == spec.APIVersion {
+			t.Errorf("expected the owner reference to the Function to be removed but got %v", ref)
+		}
+	}
+}
+
+func TestMigrateAndRollbackRuntime(t *testing.T) {
+	cm := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "nodejs",
+			Namespace: "default",
+			Labels: map[string]string{
+				KindLabel: RuntimeKind,
+				"team":    "platform",
+			},
+		},
+		Data: map[string]string{
+			SourceMountPathProperty: "/funktion/source.js",
+			IdleTimeoutProperty:     "10m",
+		},
+	}
+	kclient := fake.NewSimpleClientset(cm)
+	server := clientfake.NewServer()
+	defer server.Close()
+	tclient := server.Client()
+
+	if err := MigrateConfigMap(kclient, tclient, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kclient.Core().ConfigMaps("default").Get("nodejs"); !errors.IsNotFound(err) {
+		t.Errorf("expected the Runtime ConfigMap to be deleted but got %v", err)
+	}
+	form, err := NewResourceClient(kclient, tclient, "default", RuntimeKind).Get("nodejs")
+	if err != nil {
+		t.Fatalf("expected the Runtime to be created but got %v", err)
+	}
+
+	if err := RollbackCustomResource(kclient, tclient, form); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tclient.Runtimes("default").Get("nodejs"); !errors.IsNotFound(err) {
+		t.Errorf("expected the Runtime to be deleted but got %v", err)
+	}
+	restored, err := kclient.Core().ConfigMaps("default").Get("nodejs")
+	if err != nil {
+		t.Fatalf("expected the Runtime ConfigMap to be recreated but got %v", err)
+	}
+	if restored.Labels[KindLabel] != RuntimeKind || restored.Labels["team"] != "platform" {
+		t.Errorf("expected the labels to be restored but got %v", restored.Labels)
+	}
+	for key, value := range cm.Data {
+		if restored.Data[key] != value {
+			t.Errorf("expected %s to be %s but got %s", key, value, restored.Data[key])
+		}
+	}
+}
@@ -16,29 +16,37 @@ package funktion
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/funktionio/funktion/pkg/analytics"
+	"github.com/funktionio/funktion/pkg/client"
+	"github.com/funktionio/funktion/pkg/k8sutil"
 	"github.com/funktionio/funktion/pkg/queue"
+	"github.com/funktionio/funktion/pkg/spec"
 
 	"strings"
 
 	"github.com/go-kit/kit/log"
 	"k8s.io/client-go/1.5/kubernetes"
 	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
 	"k8s.io/client-go/1.5/pkg/api/v1"
+	autoscaling "k8s.io/client-go/1.5/pkg/apis/autoscaling/v1"
 	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
 	utilruntime "k8s.io/client-go/1.5/pkg/util/runtime"
-	"k8s.io/client-go/1.5/pkg/util/wait"
 	"k8s.io/client-go/1.5/rest"
 	"k8s.io/client-go/1.5/tools/cache"
 )
 
 // Operator manages Funktion Deployments
 type Operator struct {
-	kclient *kubernetes.Clientset
-	//funktionClient *rest.RESTClient
-	logger log.Logger
+	kclient kubernetes.Interface
+	// tclient is the client for the custom resources or nil if only ConfigMaps are used
+	tclient  *client.Client
+	logger   log.Logger
+	recorder *eventRecorder
 
 	connectorInf  cache.SharedIndexInformer
 	flowInf       cache.SharedIndexInformer
@@ -46,8 +54,40 @@ type Operator struct {
 	functionInf   cache.SharedIndexInformer
 	deploymentInf cache.SharedIndexInformer
 	serviceInf    cache.SharedIndexInformer
+	autoscalerInf cache.SharedIndexInformer
+	revisionInf   cache.SharedIndexInformer
 
 	queue *queue.Queue
+
+	elector *k8sutil.LeaderElector
+	workers int
+	health  healthState
+	orphans orphanTracker
+	// statuses holds the resourceVersions produced by writing the status of Functions and Flows
+	statuses statusVersions
+	// idle scales idle Functions to zero or is nil if the activator is not running
+	idle *idleScaler
+}
+
+// Options configures how the Operator runs
+type Options struct {
+	// LeaderElection enables leader election using a lease on a ConfigMap when not nil
+	// so that only one replica of the operator reconciles resources at any time
+	LeaderElection *k8sutil.LeaderElectionConfig
+
+	// Namespaces restricts the operator to the given namespaces. All namespaces are used if empty
+	Namespaces []string
+
+	// Workers is the number of resources reconciled concurrently. Defaults to 1
+	Workers int
+
+	// CustomResources registers the Function, Flow, Runtime and Connector custom resources
+	// and watches them alongside the label based ConfigMaps
+	CustomResources bool
+
+	// Activator is true when the activator proxies requests to Functions. Functions with an idle
+	// timeout are only scaled to zero when it is as only the requests it proxies count as activity
+	Activator bool
 }
 
 // ResourceKey represents a kind and a key
@@ -57,19 +97,44 @@ type ResourceKey struct {
 }
 
 // New creates a new controller.
-func New(cfg *rest.Config, logger log.Logger) (*Operator, error) {
+func New(cfg *rest.Config, opts Options, logger log.Logger) (*Operator, error) {
 	logger.Log("msg", "starting up!")
-	client, err := kubernetes.NewForConfig(cfg)
+	kclient, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	c := &Operator{
-		kclient: client,
-		logger:  logger,
-		queue:   queue.New(),
+		kclient:  kclient,
+		logger:   logger,
+		recorder: newEventRecorder(kclient, logger),
+		queue:    queue.NewWithBackoff(retryBaseDelay, retryMaxDelay),
+		workers:  opts.Workers,
+	}
+	if opts.Activator {
+		c.idle = &idleScaler{
+			kclient: kclient,
+			now:     time.Now,
+		}
+	}
+	if c.workers < 1 {
+		c.workers = 1
+	}
+	if opts.CustomResources {
+		c.tclient = client.NewForClientset(kclient)
 	}
 
+	if opts.LeaderElection != nil {
+		elector, err := k8sutil.NewLeaderElector(kclient, *opts.LeaderElection, logger)
+		if err != nil {
+			return nil, err
+		}
+		c.elector = elector
+	}
+
+	if len(opts.Namespaces) > 0 {
+		logger.Log("msg", "watching namespaces", "namespaces", strings.Join(opts.Namespaces, ","))
+	}
 	logger.Log("msg", "creating ListOptions")
 	flowListOpts, err := CreateFlowListOptions()
 	if err != nil {
@@ -87,42 +152,75 @@ func New(cfg *rest.Config, logger log.Logger) (*Operator, error) {
 	if err != nil {
 		return nil, err
 	}
+	managedListOpts, err := CreateManagedListOptions()
+	if err != nil {
+		return nil, err
+	}
+	revisionListOpts, err := CreateRevisionListOptions()
+	if err != nil {
+		return nil, err
+	}
 
 	c.connectorInf = cache.NewSharedIndexInformer(
-		NewConfigMapListWatch(c.kclient, *connectorListOpts),
+		NewResourceListWatch(kclient, c.tclient, ConnectorKind, *connectorListOpts, opts.Namespaces),
 		&v1.ConfigMap{},
 		resyncPeriod,
 		cache.Indexers{},
 	)
 	c.flowInf = cache.NewSharedIndexInformer(
-		NewConfigMapListWatch(c.kclient, *flowListOpts),
+		NewResourceListWatch(kclient, c.tclient, FlowKind, *flowListOpts, opts.Namespaces),
 		&v1.ConfigMap{},
 		resyncPeriod,
-		cache.Indexers{},
+		cache.Indexers{
+			connectorIndex: connectorIndexFunc,
+		},
 	)
 	c.runtimeInf = cache.NewSharedIndexInformer(
-		NewConfigMapListWatch(c.kclient, *runtimeListOpts),
+		NewResourceListWatch(kclient, c.tclient, RuntimeKind, *runtimeListOpts, opts.Namespaces),
 		&v1.ConfigMap{},
 		resyncPeriod,
 		cache.Indexers{},
 	)
 	c.functionInf = cache.NewSharedIndexInformer(
-		NewConfigMapListWatch(c.kclient, *functionListOpts),
+		NewResourceListWatch(kclient, c.tclient, FunctionKind, *functionListOpts, opts.Namespaces),
 		&v1.ConfigMap{},
 		resyncPeriod,
-		cache.Indexers{},
+		cache.Indexers{
+			runtimeIndex: runtimeIndexFunc,
+		},
 	)
 	c.deploymentInf = cache.NewSharedIndexInformer(
-		cache.NewListWatchFromClient(c.kclient.Extensions().GetRESTClient(), "deployments", api.NamespaceAll, nil),
+		NewDeploymentListWatch(kclient, *managedListOpts, opts.Namespaces),
 		&v1beta1.Deployment{},
 		resyncPeriod,
-		cache.Indexers{},
+		cache.Indexers{
+			revisionIndex: revisionIndexFunc,
+			ownerIndex:    ownerIndexFunc,
+		},
 	)
 	c.serviceInf = cache.NewSharedIndexInformer(
-		NewServiceListWatch(c.kclient),
+		NewServiceListWatch(kclient, *managedListOpts, opts.Namespaces),
 		&v1.Service{},
 		resyncPeriod,
-		cache.Indexers{},
+		cache.Indexers{
+			ownerIndex: ownerIndexFunc,
+		},
+	)
+	c.autoscalerInf = cache.NewSharedIndexInformer(
+		NewAutoscalerListWatch(kclient, *managedListOpts, opts.Namespaces),
+		&autoscaling.HorizontalPodAutoscaler{},
+		resyncPeriod,
+		cache.Indexers{
+			ownerIndex: ownerIndexFunc,
+		},
+	)
+	c.revisionInf = cache.NewSharedIndexInformer(
+		NewRevisionListWatch(kclient, *revisionListOpts, opts.Namespaces),
+		&v1.ConfigMap{},
+		resyncPeriod,
+		cache.Indexers{
+			revisionIndex: revisionIndexFunc,
+		},
 	)
 
 	c.connectorInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -146,7 +244,6 @@ func New(cfg *rest.Config, logger log.Logger) (*Operator, error) {
 		UpdateFunc: c.handleUpdateFunction,
 	})
 	c.deploymentInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		// TODO only look at Funktion related Deployments?
 		AddFunc: func(d interface{}) {
 			c.handleAddDeployment(d)
 		},
@@ -158,7 +255,6 @@ func New(cfg *rest.Config, logger log.Logger) (*Operator, error) {
 		},
 	})
 	c.serviceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		// TODO only look at Funktion related Services?
 		AddFunc: func(d interface{}) {
 			c.handleAddService(d)
 		},
@@ -169,6 +265,12 @@ func New(cfg *rest.Config, logger log.Logger) (*Operator, error) {
 			c.handleUpdateService(old, cur)
 		},
 	})
+	c.autoscalerInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		// the autoscaler updates its status frequently so lets only recreate it when deleted
+		DeleteFunc: func(d interface{}) {
+			c.handleDeleteAutoscaler(d)
+		},
+	})
 
 	logger.Log("msg", "started up!")
 
@@ -179,17 +281,57 @@ func New(cfg *rest.Config, logger log.Logger) (*Operator, error) {
 func (c *Operator) Run(stopc <-chan struct{}) error {
 	defer c.queue.ShutDown()
 
-	go c.worker()
+	if c.tclient != nil {
+		c.logger.Log("msg", "registering custom resources")
+		if err := client.CreateThirdPartyResources(c.kclient); err != nil {
+			return fmt.Errorf("failed to register custom resources: %s", err)
+		}
+	}
 
+	go c.recorder.run(stopc)
+
+	// the informers always run so that followers have warm caches when they take over
 	go c.connectorInf.Run(stopc)
 	go c.flowInf.Run(stopc)
 	go c.runtimeInf.Run(stopc)
 	go c.functionInf.Run(stopc)
 	go c.deploymentInf.Run(stopc)
 	go c.serviceInf.Run(stopc)
+	go c.autoscalerInf.Run(stopc)
+	go c.revisionInf.Run(stopc)
 
-	<-stopc
-	return nil
+	// lets not reconcile against empty caches or we would create duplicate resources
+	c.logger.Log("msg", "waiting for informer caches to sync")
+	if !c.waitForCacheSync(stopc) {
+		return nil
+	}
+	c.logger.Log("msg", "informer caches synced")
+
+	if c.elector == nil {
+		c.runWorkers(stopc)
+		return nil
+	}
+
+	workersStopped := make(chan struct{})
+	c.elector.Run(stopc, k8sutil.LeaderCallbacks{
+		OnStartedLeading: func(stop <-chan struct{}) {
+			c.logger.Log("msg", "elected as leader, starting workers")
+			c.runWorkers(stop)
+			close(workersStopped)
+		},
+		OnStoppedLeading: func() {
+			c.logger.Log("msg", "no longer the leader, stopping workers")
+			// lets not give up the lease while a worker may still be reconciling
+			<-workersStopped
+		},
+	})
+	select {
+	case <-stopc:
+		return nil
+	default:
+		// the worker cannot safely continue so lets exit and let another replica take over
+		return fmt.Errorf("lost the leader election lease")
+	}
 }
 
 func (c *Operator) keyFunc(obj interface{}) (string, bool) {
@@ -228,6 +370,10 @@ func (c *Operator) handleUpdateRuntime(old, cur interface{}) {
 	if !ok {
 		return
 	}
+	// Periodic resync resends the runtime without changes; the dependent functions resync on their own.
+	if old.(*v1.ConfigMap).ResourceVersion == cur.(*v1.ConfigMap).ResourceVersion {
+		return
+	}
 
 	c.logger.Log("msg", "Runtime updated", "key", key)
 	c.enqueue(key, RuntimeKind)
@@ -260,6 +406,9 @@ func (c *Operator) handleUpdateFunction(old, cur interface{}) {
 	if !ok {
 		return
 	}
+	if isStatusUpdate(old.(*v1.ConfigMap), cur.(*v1.ConfigMap)) {
+		return
+	}
 
 	c.logger.Log("msg", "Function updated", "key", key)
 	c.enqueue(key, FunctionKind)
@@ -292,6 +441,10 @@ func (c *Operator) handleUpdateConnector(old, cur interface{}) {
 	if !ok {
 		return
 	}
+	// Periodic resync resends the connector without changes; the dependent flows resync on their own.
+	if old.(*v1.ConfigMap).ResourceVersion == cur.(*v1.ConfigMap).ResourceVersion {
+		return
+	}
 
 	c.logger.Log("msg", "Connector updated", "key", key)
 	c.enqueue(key, ConnectorKind)
@@ -324,67 +477,65 @@ func (c *Operator) handleUpdateFlow(old, cur interface{}) {
 	if !ok {
 		return
 	}
+	if isStatusUpdate(old.(*v1.ConfigMap), cur.(*v1.ConfigMap)) {
+		return
+	}
 
 	c.logger.Log("msg", "Flow updated", "key", key)
 	c.enqueue(key, FlowKind)
 }
 
 func (c *Operator) handleDeleteDeployment(obj interface{}) {
-	if d := c.flowForDeployment(obj); d != nil {
-		c.enqueue(d, DeploymentKind)
-	}
+	c.enqueueOwner(obj)
 }
 
 func (c *Operator) handleAddDeployment(obj interface{}) {
-	if d := c.flowForDeployment(obj); d != nil {
-		c.enqueue(d, DeploymentKind)
-	}
+	c.enqueueOwner(obj)
 }
 
 func (c *Operator) handleUpdateDeployment(oldo, curo interface{}) {
 	old := oldo.(*v1beta1.Deployment)
 	cur := curo.(*v1beta1.Deployment)
 
-	//c.logger.Log("msg", "update handler", "old", old.ResourceVersion, "cur", cur.ResourceVersion, "kind", "Service"))
-
 	// Periodic resync may resend the deployment without changes in-between.
-	// Also breaks loops created by updating the resource ourselves.
 	if old.ResourceVersion == cur.ResourceVersion {
 		return
 	}
 
-	// Wake up Flow resource the deployment belongs to.
-	if k := c.flowForDeployment(cur); k != nil {
-		c.enqueue(k, DeploymentKind)
-	}
+	// Wake up the Function or Flow the deployment belongs to so that its status follows the rollout.
+	c.enqueueOwner(cur)
 }
+
 func (c *Operator) handleDeleteService(obj interface{}) {
-	if d := c.functionForService(obj); d != nil {
-		c.enqueue(d, ServiceKind)
-	}
+	c.enqueueOwner(obj)
+}
+
+func (c *Operator) handleDeleteAutoscaler(obj interface{}) {
+	c.enqueueOwner(obj)
 }
 
 func (c *Operator) handleAddService(obj interface{}) {
-	if d := c.functionForService(obj); d != nil {
-		c.enqueue(d, ServiceKind)
-	}
+	c.enqueueOwner(obj)
 }
 
 func (c *Operator) handleUpdateService(oldo, curo interface{}) {
 	old := oldo.(*v1.Service)
 	cur := curo.(*v1.Service)
 
-	//c.logger.Log("msg", "update handler", "old", old.ResourceVersion, "cur", cur.ResourceVersion, "kind", "Service")
-
 	// Periodic resync may resend the service without changes in-between.
-	// Also breaks loops created by updating the resource ourselves.
 	if old.ResourceVersion == cur.ResourceVersion {
 		return
 	}
 
-	// Wake up Funktion resource the service belongs to.
-	if k := c.functionForService(cur); k != nil {
-		c.enqueue(k, ServiceKind)
+	// Wake up the Function or Flow the service belongs to.
+	c.enqueueOwner(cur)
+}
+
+// enqueueOwner enqueues the Function or Flow which the given Deployment, Service or HorizontalPodAutoscaler
+// belongs to under its own kind
+func (c *Operator) enqueueOwner(obj interface{}) {
+	if owner, kind := c.ownerOf(obj); owner != nil {
+		c.enqueue(owner, kind)
 	}
 }
 
@@ -403,71 +554,78 @@ func (c *Operator) enqueue(obj interface{}, kind string) {
 		}
 	}
 
-	c.queue.Add(&ResourceKey{
+	c.queue.Add(ResourceKey{
 		Key:  key,
 		Kind: kind,
 	})
 }
 
+// runWorkers runs the configured number of workers after sweeping for resources whose owner was deleted while
+// no operator was running and adopting the Deployments of Functions which predate revisions. The queue ensures
+// that the same ResourceKey is never processed by more than one worker at a time. It blocks until stopc is
+// closed and all the workers have stopped
+func (c *Operator) runWorkers(stopc <-chan struct{}) {
+	c.sweepOrphans()
+	c.adoptLegacyDeployments()
+
+	var wg sync.WaitGroup
+	c.logger.Log("msg", "starting workers", "count", c.workers)
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			c.worker()
+			wg.Done()
+		}()
+	}
+	<-stopc
+	c.queue.ShutDown()
+	wg.Wait()
+	c.logger.Log("msg", "workers stopped")
+}
+
 // worker runs a worker thread that just dequeues items, processes them, and marks them done.
 // It enforces that the syncHandler is never invoked concurrently with the same key.
 func (c *Operator) worker() {
+	id := c.health.workerStarted()
+	defer c.health.workerStopped(id)
 	for {
 		key, quit := c.queue.Get()
 		if quit {
 			return
 		}
-		if err := c.sync(key.(*ResourceKey)); err != nil {
+		if c.queue.ShuttingDown() {
+			// rather than draining the queue lets stop straight away as we may no longer hold the leader lease
+			c.queue.Done(key)
+			return
+		}
+		resourceKey := key.(ResourceKey)
+		start := time.Now()
+		c.health.syncStarted(id)
+		err := c.sync(&resourceKey)
+		c.health.syncFinished(id)
+		if err == errTeardownPending {
+			// the Deployments of a deleted Function or Flow are still scaling down so lets check on them later
+			observeReconcile(resourceKey.Kind, start, nil)
+			c.queue.Forget(key)
+			c.queue.Done(key)
+			c.queue.AddAfter(key, teardownPollInterval)
+			continue
+		}
+		observeReconcile(resourceKey.Kind, start, err)
+		if err != nil {
 			utilruntime.HandleError(fmt.Errorf("reconciliation failed, re-enqueueing: %s", err))
-			// We only mark the item as done after waiting. In the meantime
-			// other items can be processed but the same item won't be processed again.
-			// This is a trivial form of rate-limiting that is sufficient for our throughput
-			// and latency expectations.
-			go func() {
-				time.Sleep(3 * time.Second)
-				c.queue.Done(key)
-			}()
+			// Retry with an exponential backoff so that permanently broken resources
+			// don't keep the worker busy and healthy resources are not starved.
+			c.queue.Done(key)
+			c.queue.AddRateLimited(key)
 			continue
 		}
 
+		c.queue.Forget(key)
 		c.queue.Done(key)
 	}
 }
 
-func (c *Operator) flowForDeployment(obj interface{}) *v1.ConfigMap {
-	key, ok := c.keyFunc(obj)
-	if !ok {
-		return nil
-	}
-	// Namespace/Name are one-to-one so the key will find the respective Funktion resource.
-	k, exists, err := c.flowInf.GetStore().GetByKey(key)
-	if err != nil {
-		c.logger.Log("msg", "Flow lookup failed", "err", err)
-		return nil
-	}
-	if !exists {
-		return nil
-	}
-	return k.(*v1.ConfigMap)
-}
-
-func (c *Operator) functionForService(obj interface{}) *v1.ConfigMap {
-	key, ok := c.keyFunc(obj)
-	if !ok {
-		return nil
-	}
-	// Namespace/Name are one-to-one so the key will find the respective Funktion resource.
-	k, exists, err := c.functionInf.GetStore().GetByKey(key)
-	if err != nil {
-		c.logger.Log("msg", "Function lookup failed", "err", err)
-		return nil
-	}
-	if !exists {
-		return nil
-	}
-	return k.(*v1.ConfigMap)
-}
-
 func (c *Operator) sync(resourceKey *ResourceKey) error {
 	kind := resourceKey.Kind
 	key := resourceKey.Key
@@ -477,134 +635,446 @@ func (c *Operator) sync(resourceKey *ResourceKey) error {
 	case FlowKind:
 		return c.syncFlow(key)
 	case ConnectorKind:
-		return nil
+		return c.syncConnector(key)
 	case RuntimeKind:
-		return nil
+		return c.syncRuntime(key)
 	case FunctionKind:
 		return c.syncFunction(key)
-	case DeploymentKind:
-		return nil
-	case ServiceKind:
-		return nil
 	default:
 		c.logger.Log("msg", "Unknown kind funktion", "key", key, "kind", kind)
 		return fmt.Errorf("Unknown kind %s for key %s", kind, key)
 	}
 }
 
+// syncConnector re-enqueues all the Flows using the Connector so that they pick up its changes.
+// If the Connector has been deleted the Flows are marked as failed when they are reconciled
+func (c *Operator) syncConnector(key string) error {
+	flows, err := c.flowInf.GetIndexer().ByIndex(connectorIndex, key)
+	if err != nil {
+		return err
+	}
+	for _, flow := range flows {
+		c.enqueue(flow, FlowKind)
+	}
+	return nil
+}
+
+// syncRuntime re-enqueues all the Functions using the Runtime so that they pick up its changes
+func (c *Operator) syncRuntime(key string) error {
+	functions, err := c.functionInf.GetIndexer().ByIndex(runtimeIndex, key)
+	if err != nil {
+		return err
+	}
+	for _, function := range functions {
+		c.enqueue(function, FunctionKind)
+	}
+	return nil
+}
+
 func (c *Operator) syncFlow(key string) error {
 	obj, exists, err := c.flowInf.GetIndexer().GetByKey(key)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return c.destroyDeployment(key)
+		c.statuses.forget(FlowKind + "/" + key)
+		return c.destroyFlow(key)
 	}
 	flow := obj.(*v1.ConfigMap)
+	if flow.DeletionTimestamp != nil {
+		if err := c.destroyFlow(key); err != nil {
+			return err
+		}
+		return c.releaseFinalizer(flow)
+	}
+	if err := c.ensureFinalizer(flow); err != nil {
+		return err
+	}
 
+	deployment, service, err := c.reconcileFlow(flow)
+	return c.updateStatus(flow, deployment, service, err)
+}
+
+// destroyFlow removes the resources created for a deleted Flow
+func (c *Operator) destroyFlow(key string) error {
+	if err := c.destroyDeployment(key, FlowKind); err != nil {
+		return err
+	}
+	if err := c.destroyService(key, FlowKind); err != nil {
+		return err
+	}
+	return c.destroyDataConfigMap(key)
+}
+
+// reconcileFlow creates or updates the Deployment for the given Flow along with its Service
+// if the Connector has a service template
+func (c *Operator) reconcileFlow(flow *v1.ConfigMap) (*v1beta1.Deployment, *v1.Service, error) {
+	key, ok := c.keyFunc(flow)
+	if !ok {
+		return nil, nil, fmt.Errorf("Could not create key for Flow %s/%s", flow.Namespace, flow.Name)
+	}
+	if err := c.reconcileDataConfigMap(flow); err != nil {
+		return nil, nil, err
+	}
 	connectorName := flow.Labels[ConnectorLabel]
 	if len(connectorName) == 0 {
-		return fmt.Errorf("Flow %s/%s does not have label %s", flow.Namespace, flow.Name, ConnectorLabel)
-	}
-	ns := flow.Namespace
-	connectorKey := connectorName
-	if len(ns) > 0 && !strings.Contains(connectorName, "/") {
-		connectorKey = ns + "/" + connectorKey
+		return nil, nil, fmt.Errorf("Flow %s/%s does not have label %s", flow.Namespace, flow.Name, ConnectorLabel)
 	}
-	obj, exists, err = c.connectorInf.GetIndexer().GetByKey(connectorKey)
+	connectorKey := referenceKey(flow.Namespace, connectorName)
+	obj, exists, err := c.connectorInf.GetIndexer().GetByKey(connectorKey)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if !exists {
-		return fmt.Errorf("Connector %s does not exist for Flow %s/%s current connector keys are %v", connectorKey, flow.Namespace, flow.Name, c.connectorInf.GetIndexer().ListKeys())
+		return nil, nil, fmt.Errorf("Connector %s does not exist for Flow %s/%s current connector keys are %v", connectorKey, flow.Namespace, flow.Name, c.connectorInf.GetIndexer().ListKeys())
 	}
 	connector := obj.(*v1.ConfigMap)
 	if connector == nil {
-		return fmt.Errorf("Connector %s does not exist for Flow %s/%s", connectorKey, flow.Namespace, flow.Name)
+		return nil, nil, fmt.Errorf("Connector %s does not exist for Flow %s/%s", connectorKey, flow.Namespace, flow.Name)
 	}
 
-	deploymentClient := c.kclient.Extensions().Deployments(flow.Namespace)
-	obj, exists, err = c.deploymentInf.GetIndexer().GetByKey(key)
+	d2, err := c.reconcileFlowDeployment(flow, connector, key)
 	if err != nil {
-		return err
+		return d2, nil, err
 	}
 
-	if !exists {
+	if len(connector.Data[ServiceYmlProperty]) == 0 {
+		// the Connector does not accept inbound HTTP so lets remove any Service we created previously
+		return d2, nil, c.removeFlowService(flow, key)
+	}
+	s2, err := c.reconcileService(flow, key, func(old *v1.Service) (*v1.Service, error) {
+		return makeFlowService(flow, connector, old, d2)
+	})
+	return d2, s2, err
+}
+
+// reconcileFlowDeployment creates or updates the Deployment for the given Flow
+func (c *Operator) reconcileFlowDeployment(flow *v1.ConfigMap, connector *v1.ConfigMap, key string) (*v1beta1.Deployment, error) {
+	old, name, err := c.findDeployment(flow, flow.Name, 0)
+	if err != nil {
+		return nil, err
+	}
+	deploymentClient := c.kclient.Extensions().Deployments(flow.Namespace)
+
+	if old == nil {
 		d, err := makeFlowDeployment(flow, connector, nil)
 		if err != nil {
-			return fmt.Errorf("make deployment: %s", err)
+			return nil, fmt.Errorf("make deployment: %s", err)
 		}
-		if _, err := deploymentClient.Create(d); err != nil {
-			return fmt.Errorf("create deployment: %s", err)
+		d.Name = name
+		hash, err := specHash(d)
+		if err != nil {
+			return nil, err
 		}
+		setSpecHash(&d.ObjectMeta, hash)
+		d2, err := deploymentClient.Create(d)
+		if err != nil {
+			return nil, fmt.Errorf("create deployment: %s", err)
+		}
+		c.recorder.Eventf(resourceReference(flow), v1.EventTypeNormal, ReasonCreated, "Created Deployment %s", d2.Name)
+		return d2, nil
+	}
+
+	// lets only update the Deployment if the desired state has changed
+	desired, err := makeFlowDeployment(flow, connector, nil)
+	if err != nil {
+		return old, fmt.Errorf("update deployment: %s", err)
+	}
+	desired.Name = name
+	hash, err := specHash(desired)
+	if err != nil {
+		return old, err
+	}
+	if hasSpecHash(old.ObjectMeta, hash) {
+		return old, nil
+	}
+	d, err := makeFlowDeployment(flow, connector, old)
+	if err != nil {
+		return old, fmt.Errorf("update deployment: %s", err)
+	}
+	d.Name = name
+	setSpecHash(&d.ObjectMeta, hash)
+	d2, err := deploymentClient.Update(d)
+	if err != nil {
+		return old, err
+	}
+	c.recorder.Eventf(resourceReference(flow), v1.EventTypeNormal, ReasonUpdated, "Updated Deployment %s", d2.Name)
+	return d2, nil
+}
+
+// updateStatus writes the outcome of a reconcile onto the status annotations of the given
+// Function or Flow ConfigMap. The reconcile error is returned so that the resource is retried
+func (c *Operator) updateStatus(cm *v1.ConfigMap, deployment *v1beta1.Deployment, service *v1.Service, reconcileErr error) error {
+	status := Status{
+		ObservedResourceVersion: cm.ResourceVersion,
+	}
+	if deployment != nil {
+		status.Deployment = deployment.Name
+	}
+	if service != nil {
+		status.Service = service.Name
+	}
+	if reconcileErr != nil {
+		status.Phase = PhaseFailed
+		status.Message = reconcileErr.Error()
+		c.recorder.Eventf(resourceReference(cm), v1.EventTypeWarning, ReasonFailedSync, "%s", reconcileErr)
+	} else {
+		status.Phase = deploymentPhase(deployment)
+	}
+
+	// lets avoid updating the ConfigMap if nothing has changed. If the resource has not changed since
+	// we last wrote its status then the observed resourceVersion is the one before that write
+	current := GetStatus(cm)
+	statusKey := kindOf(cm) + "/" + referenceKey(cm.Namespace, cm.Name)
+	if c.statuses.wrote(statusKey, cm.ResourceVersion) {
+		current.ObservedResourceVersion = status.ObservedResourceVersion
+	}
+	if current == status {
+		return reconcileErr
+	}
+
+	var resourceVersion string
+	var err error
+	if IsCustomResource(cm) {
+		resourceVersion, err = c.updateCustomResourceStatus(cm, status)
+	} else {
+		resourceVersion, err = c.updateConfigMapStatus(cm, status)
+	}
+	if err == nil {
+		c.statuses.set(statusKey, resourceVersion)
+	} else {
+		c.logger.Log("msg", "failed to update status", "name", cm.Name, "namespace", cm.Namespace, "err", err)
+		if reconcileErr == nil {
+			return err
+		}
+	}
+	return reconcileErr
+}
+
+// updateConfigMapStatus writes the status onto the annotations of the latest version of a label based ConfigMap
+// returning the resourceVersion after the update
+func (c *Operator) updateConfigMapStatus(cm *v1.ConfigMap, status Status) (string, error) {
+	cms := c.kclient.Core().ConfigMaps(cm.Namespace)
+	latest, err := cms.Get(cm.Name)
+	if err != nil {
+		return "", err
+	}
+	if !setStatus(latest, status) {
+		return latest.ResourceVersion, nil
+	}
+	updated, err := cms.Update(latest)
+	if err != nil {
+		return "", err
+	}
+	return updated.ResourceVersion, nil
+}
+
+// updateCustomResourceStatus writes the status onto the latest version of a Function or Flow custom resource
+// returning the resourceVersion after the update
+func (c *Operator) updateCustomResourceStatus(cm *v1.ConfigMap, status Status) (string, error) {
+	switch cm.Kind {
+	case FunctionKind:
+		functions := c.tclient.Functions(cm.Namespace)
+		latest, err := functions.Get(cm.Name)
+		if err != nil {
+			return "", err
+		}
+		latest.Status = statusToSpec(status)
+		updated, err := functions.Update(latest)
+		if err != nil {
+			return "", err
+		}
+		return updated.ResourceVersion, nil
+	case FlowKind:
+		flows := c.tclient.Flows(cm.Namespace)
+		latest, err := flows.Get(cm.Name)
+		if err != nil {
+			return "", err
+		}
+		latest.Status = statusToSpec(status)
+		updated, err := flows.Update(latest)
+		if err != nil {
+			return "", err
+		}
+		return updated.ResourceVersion, nil
+	default:
+		return "", fmt.Errorf("Custom resource %s/%s of kind %s has no status", cm.Namespace, cm.Name, cm.Kind)
+	}
+}
+
+// reconcileDataConfigMap creates or updates the ConfigMap holding the data of a Function or Flow custom
+// resource so that the pods can mount the source or flow configuration as they do for label based ConfigMaps
+func (c *Operator) reconcileDataConfigMap(cm *v1.ConfigMap) error {
+	if !IsCustomResource(cm) {
 		return nil
 	}
-	d, err := makeFlowDeployment(flow, connector, obj.(*v1beta1.Deployment))
+	cms := c.kclient.Core().ConfigMaps(cm.Namespace)
+	old, err := cms.Get(cm.Name)
 	if err != nil {
-		return fmt.Errorf("update deployment: %s", err)
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		data := &v1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+			Data: copyStringMap(cm.Data),
+		}
+		setOwnerReference(&data.ObjectMeta, ownerReference(cm))
+		if _, err = cms.Create(data); err != nil {
+			return fmt.Errorf("create data configmap: %s", err)
+		}
+		return nil
 	}
-	if _, err := deploymentClient.Update(d); err != nil {
-		return err
+	if len(old.Labels[KindLabel]) > 0 {
+		return fmt.Errorf("ConfigMap %s/%s still has the label %s. Please run `funktion migrate` to convert it to a %s", cm.Namespace, cm.Name, KindLabel, cm.Kind)
+	}
+	if isOwnedBy(old.ObjectMeta, cm) && reflect.DeepEqual(old.Data, cm.Data) {
+		return nil
+	}
+	old.Data = copyStringMap(cm.Data)
+	setOwnerReference(&old.ObjectMeta, ownerReference(cm))
+	if _, err = cms.Update(old); err != nil {
+		return fmt.Errorf("update data configmap: %s", err)
 	}
 	return nil
 }
 
-func (c *Operator) destroyDeployment(key string) error {
-	obj, exists, err := c.deploymentInf.GetStore().GetByKey(key)
+// destroyDataConfigMap removes the data ConfigMap of a deleted Function or Flow custom resource
+// if the garbage collector has not already done so
+func (c *Operator) destroyDataConfigMap(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		return err
 	}
-	if !exists {
+	cms := c.kclient.Core().ConfigMaps(namespace)
+	cm, err := cms.Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	owned := false
+	for _, ref := range cm.OwnerReferences {
+		if ref.APIVersion == spec.APIVersion {
+			owned = true
+		}
+	}
+	if !owned || len(cm.Labels[KindLabel]) > 0 {
 		return nil
 	}
-	deployment := obj.(*v1beta1.Deployment)
+	if err := cms.Delete(name, nil); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
 
-	// Only necessary until GC is properly implemented in both Kubernetes and OpenShift.
-	scaleClient := c.kclient.Extensions().Scales(deployment.Namespace)
-	if _, err := scaleClient.Update("deployment", &v1beta1.Scale{
-		ObjectMeta: v1.ObjectMeta{
-			Namespace: deployment.Namespace,
-			Name:      deployment.Name,
-		},
-		Spec: v1beta1.ScaleSpec{
-			Replicas: 0,
-		},
-	}); err != nil {
+// destroyDeployment removes the Deployment of a deleted Function or Flow. Deployments owned by the
+// ConfigMap are left for the garbage collector unless they are still around after a timeout.
+// The Deployments of the revisions of a Function are removed by destroyRevisionDeployments
+func (c *Operator) destroyDeployment(key string, ownerKind string) error {
+	objs, err := managedBy(c.deploymentInf, ownerKind, key)
+	if err != nil {
 		return err
 	}
+	found := false
+	pending := false
+	for _, obj := range objs {
+		deployment := obj.(*v1beta1.Deployment)
+		if RevisionNumber(deployment.ObjectMeta) > 0 {
+			continue
+		}
+		found = true
+		if c.awaitGarbageCollection(DeploymentKind, key, ownerKind, key, deployment.ObjectMeta) {
+			continue
+		}
+		if err := c.deleteDeployment(deployment); err == errTeardownPending {
+			pending = true
+			continue
+		} else if err != nil {
+			c.recorder.Eventf(keyReference(key), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete Deployment %s: %s", deployment.Name, err)
+			return err
+		}
+		c.orphans.forget(DeploymentKind, key)
+		c.recorder.Eventf(keyReference(key), v1.EventTypeNormal, ReasonDeleted, "Deleted Deployment %s", deployment.Name)
+	}
+	if !found {
+		c.orphans.forget(DeploymentKind, key)
+	}
+	if pending {
+		return errTeardownPending
+	}
+	return nil
+}
 
-	deploymentClient := c.kclient.Extensions().Deployments(deployment.Namespace)
-	currentGeneration := deployment.Generation
-	if err := wait.PollInfinite(1*time.Second, func() (bool, error) {
-		updatedDeployment, err := deploymentClient.Get(deployment.Name)
-		if err != nil {
-			return false, err
+// deleteDeployment scales down the Deployment before deleting it so that its pods are not
+// left behind on clusters without garbage collection. Rather than waiting for the pods to go
+// it returns errTeardownPending until the Deployment has scaled down or teardownTimeout passed
+func (c *Operator) deleteDeployment(deployment *v1beta1.Deployment) error {
+	key, ok := c.keyFunc(deployment)
+	if !ok {
+		return fmt.Errorf("Could not get the key of Deployment %s", deployment.Name)
+	}
+	scaledDown := deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 &&
+		deployment.Status.ObservedGeneration >= deployment.Generation && deployment.Status.Replicas == 0
+	if !scaledDown {
+		waited, _ := c.orphans.observe(teardownKind, key)
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0 {
+			scaleClient := c.kclient.Extensions().Scales(deployment.Namespace)
+			if _, err := scaleClient.Update("deployment", &v1beta1.Scale{
+				ObjectMeta: v1.ObjectMeta{
+					Namespace: deployment.Namespace,
+					Name:      deployment.Name,
+				},
+				Spec: v1beta1.ScaleSpec{
+					Replicas: 0,
+				},
+			}); err != nil {
+				return err
+			}
 		}
-		return updatedDeployment.Status.ObservedGeneration >= currentGeneration &&
-			updatedDeployment.Status.Replicas == 0, nil
-	}); err != nil {
-		return err
+		if waited < teardownTimeout {
+			return errTeardownPending
+		}
+		c.logger.Log("msg", "timed out waiting for deployment to scale down, deleting it anyway", "name", deployment.Name, "namespace", deployment.Namespace)
 	}
 
-	// Let's get ready for proper GC by ensuring orphans are not left behind.
 	orphan := false
-	return deploymentClient.Delete(deployment.ObjectMeta.Name, &api.DeleteOptions{OrphanDependents: &orphan})
+	deploymentClient := c.kclient.Extensions().Deployments(deployment.Namespace)
+	if err := deploymentClient.Delete(deployment.ObjectMeta.Name, &api.DeleteOptions{OrphanDependents: &orphan}); err != nil {
+		return err
+	}
+	c.orphans.forget(teardownKind, key)
+	return nil
 }
 
-func (c *Operator) destroyService(key string) error {
-	obj, exists, err := c.serviceInf.GetStore().GetByKey(key)
+// destroyService removes the Service of a deleted Function or Flow. Services owned by the
+// ConfigMap are left for the garbage collector unless they are still around after a timeout
+func (c *Operator) destroyService(key string, ownerKind string) error {
+	objs, err := managedBy(c.serviceInf, ownerKind, key)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return nil
+	if len(objs) == 0 {
+		c.orphans.forget(ServiceKind, key)
 	}
-	service := obj.(*v1.Service)
-
-	serviceClient := c.kclient.Services(service.Namespace)
-	// Let's get ready for proper GC by ensuring orphans are not left behind.
-	orphan := false
-	return serviceClient.Delete(service.ObjectMeta.Name, &api.DeleteOptions{OrphanDependents: &orphan})
+	for _, obj := range objs {
+		service := obj.(*v1.Service)
+		if c.awaitGarbageCollection(ServiceKind, key, ownerKind, key, service.ObjectMeta) {
+			continue
+		}
+		serviceClient := c.kclient.Core().Services(service.Namespace)
+		orphan := false
+		if err := serviceClient.Delete(service.ObjectMeta.Name, &api.DeleteOptions{OrphanDependents: &orphan}); err != nil {
+			c.recorder.Eventf(keyReference(key), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete Service %s: %s", service.Name, err)
+			return err
+		}
+		c.orphans.forget(ServiceKind, key)
+		c.recorder.Eventf(keyReference(key), v1.EventTypeNormal, ReasonDeleted, "Deleted Service %s", service.Name)
+	}
+	return nil
 }
 
 func (c *Operator) syncFunction(key string) error {
@@ -613,81 +1083,263 @@ func (c *Operator) syncFunction(key string) error {
 		return err
 	}
 	if !exists {
-		err = c.destroyDeployment(key)
-		if err != nil {
+		c.statuses.forget(FunctionKind + "/" + key)
+		return c.destroyFunction(key)
+	}
+	function := obj.(*v1.ConfigMap)
+	if function.DeletionTimestamp != nil {
+		if err := c.destroyFunction(key); err != nil {
 			return err
 		}
-		return c.destroyService(key)
+		return c.releaseFinalizer(function)
 	}
-	function := obj.(*v1.ConfigMap)
+	if err := c.ensureFinalizer(function); err != nil {
+		return err
+	}
+
+	deployment, service, err := c.reconcileFunction(function)
+	return c.updateStatus(function, deployment, service, err)
+}
 
+// destroyFunction removes the resources created for a deleted Function
+func (c *Operator) destroyFunction(key string) error {
+	// lets scale down the Deployments of every revision at once and only remove the
+	// Service once they have all gone
+	pending := false
+	if err := c.destroyDeployment(key, FunctionKind); err == errTeardownPending {
+		pending = true
+	} else if err != nil {
+		return err
+	}
+	if err := c.destroyRevisionDeployments(key); err == errTeardownPending {
+		pending = true
+	} else if err != nil {
+		return err
+	}
+	if pending {
+		return errTeardownPending
+	}
+	if err := c.destroyService(key, FunctionKind); err != nil {
+		return err
+	}
+	if err := c.destroyAutoscaler(key); err != nil {
+		return err
+	}
+	if err := c.destroyRevisions(key); err != nil {
+		return err
+	}
+	return c.destroyDataConfigMap(key)
+}
+
+// reconcileFunction creates or updates the Deployment and Service for the given Function
+func (c *Operator) reconcileFunction(function *v1.ConfigMap) (*v1beta1.Deployment, *v1.Service, error) {
+	key, ok := c.keyFunc(function)
+	if !ok {
+		return nil, nil, fmt.Errorf("Could not create key for Function %s/%s", function.Namespace, function.Name)
+	}
+	if err := c.reconcileDataConfigMap(function); err != nil {
+		return nil, nil, err
+	}
 	runtimeName := function.Labels[RuntimeLabel]
 	if len(runtimeName) == 0 {
-		return fmt.Errorf("Function %s/%s does not have label %s", function.Namespace, function.Name, RuntimeLabel)
-	}
-	ns := function.Namespace
-	runtimeKey := runtimeName
-	if len(ns) > 0 && !strings.Contains(runtimeName, "/") {
-		runtimeKey = ns + "/" + runtimeKey
+		return nil, nil, fmt.Errorf("Function %s/%s does not have label %s", function.Namespace, function.Name, RuntimeLabel)
 	}
-	obj, exists, err = c.runtimeInf.GetIndexer().GetByKey(runtimeKey)
+	runtimeKey := referenceKey(function.Namespace, runtimeName)
+	obj, exists, err := c.runtimeInf.GetIndexer().GetByKey(runtimeKey)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if !exists {
-		return fmt.Errorf("Runtime %s does not exist for Function %s/%s current connector keys are %v", runtimeKey, function.Namespace, function.Name, c.connectorInf.GetIndexer().ListKeys())
+		return nil, nil, fmt.Errorf("Runtime %s does not exist for Function %s/%s current runtime keys are %v", runtimeKey, function.Namespace, function.Name, c.runtimeInf.GetIndexer().ListKeys())
 	}
 	runtime := obj.(*v1.ConfigMap)
 	if runtime == nil {
-		return fmt.Errorf("Runtime %s does not exist for Function %s/%s", runtimeKey, function.Namespace, function.Name)
+		return nil, nil, fmt.Errorf("Runtime %s does not exist for Function %s/%s", runtimeKey, function.Namespace, function.Name)
 	}
 
-	deploymentClient := c.kclient.Extensions().Deployments(function.Namespace)
-	obj, exists, err = c.deploymentInf.GetIndexer().GetByKey(key)
+	revisions, err := c.reconcileRevisions(function)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	traffic, err := trafficForFunction(function, revisions)
+	if err != nil {
+		return nil, nil, err
+	}
+	settings, err := autoscalingForFunction(function)
+	if err != nil {
+		return nil, nil, err
+	}
+	primary := primaryTarget(traffic)
+	if primary == nil {
+		return nil, nil, fmt.Errorf("Function %s/%s does not send traffic to any revision", function.Namespace, function.Name)
 	}
 
+	// lets run the primary revision first so that we know how many pods the Function has in total
+	// and then split them between the revisions in proportion to their traffic
 	var d2 *v1beta1.Deployment
-	if !exists {
-		d, err := makeFunctionDeployment(function, runtime, nil)
+	deployments := []*v1beta1.Deployment{}
+	active := map[string]bool{}
+	total := int32(0)
+	for _, target := range append([]TrafficTarget{*primary}, withoutRevision(traffic, primary.Revision)...) {
+		if target.Percent == 0 {
+			continue
+		}
+		revision := &functionRevision{
+			Number: target.Revision,
+			Data:   findRevision(revisions, target.Revision),
+		}
+		if target.Revision != primary.Revision {
+			replicas := replicasForTraffic(total, target.Percent)
+			revision.Replicas = &replicas
+		} else if settings == nil && target.Percent < 100 {
+			template, err := makeFunctionDeployment(function, runtime, revision, nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("make deployment: %s", err)
+			}
+			total = deploymentReplicas(template)
+			replicas := replicasForTraffic(total, target.Percent)
+			revision.Replicas = &replicas
+		}
+		d, err := c.reconcileFunctionDeployment(function, runtime, revision)
 		if err != nil {
-			return fmt.Errorf("make deployment: %s", err)
+			return d2, nil, err
 		}
-		if d2, err = deploymentClient.Create(d); err != nil {
-			return fmt.Errorf("create deployment: %s", err)
+		if target.Revision == primary.Revision {
+			d2 = d
+			if settings != nil {
+				total = deploymentReplicas(d) * 100 / int32(target.Percent)
+			}
 		}
-	} else {
-		d, err := makeFunctionDeployment(function, runtime, obj.(*v1beta1.Deployment))
+		deployments = append(deployments, d)
+		active[d.Name] = true
+	}
+	if err := c.removeUnusedDeployments(function, key, deployments); err != nil {
+		return d2, nil, err
+	}
+	if err := c.pruneRevisions(function, revisions, traffic); err != nil {
+		return d2, nil, err
+	}
+
+	s2, err := c.reconcileService(function, key, func(old *v1.Service) (*v1.Service, error) {
+		return makeFunctionService(function, runtime, old, d2)
+	})
+	if err != nil {
+		return d2, s2, err
+	}
+	if err := c.reconcileAutoscaler(function, key, d2); err != nil {
+		return d2, s2, err
+	}
+	for _, d := range deployments {
+		if err := c.reconcileIdle(function, runtime, d); err != nil {
+			return d2, s2, err
+		}
+	}
+	return d2, s2, nil
+}
+
+// reconcileFunctionDeployment creates or updates the Deployment running the given revision of a Function
+func (c *Operator) reconcileFunctionDeployment(function *v1.ConfigMap, runtime *v1.ConfigMap, revision *functionRevision) (*v1beta1.Deployment, error) {
+	deploymentClient := c.kclient.Extensions().Deployments(function.Namespace)
+	old, name, err := c.findDeployment(function, RevisionName(function.Name, revision.Number), revision.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	if old == nil {
+		d, err := makeFunctionDeployment(function, runtime, revision, nil)
 		if err != nil {
-			return fmt.Errorf("update deployment: %s", err)
+			return nil, fmt.Errorf("make deployment: %s", err)
 		}
-		if d2, err = deploymentClient.Update(d); err != nil {
-			return err
+		d.Name = name
+		hash, err := specHash(d)
+		if err != nil {
+			return nil, err
 		}
+		setSpecHash(&d.ObjectMeta, hash)
+		d2, err := deploymentClient.Create(d)
+		if err != nil {
+			return nil, fmt.Errorf("create deployment: %s", err)
+		}
+		c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonCreated, "Created Deployment %s", d2.Name)
+		return d2, nil
+	}
+
+	// lets only update the Deployment if the desired state has changed
+	desired, err := makeFunctionDeployment(function, runtime, revision, nil)
+	if err != nil {
+		return old, fmt.Errorf("update deployment: %s", err)
 	}
-	serviceClient := c.kclient.Services(function.Namespace)
-	obj, exists, err = c.serviceInf.GetIndexer().GetByKey(key)
+	desired.Name = name
+	hash, err := specHash(desired)
 	if err != nil {
-		c.logger.Log("msg", "==== failed to find service", "key", key)
-		return err
+		return old, err
 	}
+	d, err := makeFunctionDeployment(function, runtime, revision, old)
+	if err != nil {
+		return old, fmt.Errorf("update deployment: %s", err)
+	}
+	d.Name = name
+	// the activator wakes an idle Function with a single replica so the replicas are compared on their own
+	if hasSpecHash(old.ObjectMeta, hash) && deploymentReplicas(d) == deploymentReplicas(old) {
+		return old, nil
+	}
+	setSpecHash(&d.ObjectMeta, hash)
+	d2, err := deploymentClient.Update(d)
+	if err != nil {
+		return old, err
+	}
+	c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonUpdated, "Updated Deployment %s", d2.Name)
+	return d2, nil
+}
 
-	if !exists {
-		s, err := makeFunctionService(function, runtime, nil, d2)
+// reconcileService creates or updates the Service of the given Function or Flow using makeService
+// to render it from the owner's template. The existing Service is passed to makeService when updating
+func (c *Operator) reconcileService(owner *v1.ConfigMap, key string, makeService func(old *v1.Service) (*v1.Service, error)) (*v1.Service, error) {
+	serviceClient := c.kclient.Core().Services(owner.Namespace)
+	old, name, err := c.findService(owner)
+	if err != nil {
+		c.logger.Log("msg", "failed to find service", "key", key)
+		return nil, err
+	}
+
+	if old == nil {
+		s, err := makeService(nil)
 		if err != nil {
-			return fmt.Errorf("make service: %s", err)
+			return nil, fmt.Errorf("make service: %s", err)
 		}
-		if _, err := serviceClient.Create(s); err != nil {
-			return fmt.Errorf("create service: %s", err)
+		s.Name = name
+		hash, err := specHash(s)
+		if err != nil {
+			return nil, err
 		}
-		return nil
+		setSpecHash(&s.ObjectMeta, hash)
+		s2, err := serviceClient.Create(s)
+		if err != nil {
+			return nil, fmt.Errorf("create service: %s", err)
+		}
+		c.recorder.Eventf(resourceReference(owner), v1.EventTypeNormal, ReasonCreated, "Created Service %s", s2.Name)
+		return s2, nil
 	}
-	old := obj.(*v1.Service)
-	s, err := makeFunctionService(function, runtime, old, d2)
+
+	// lets only update the Service if the desired state has changed
+	desired, err := makeService(nil)
 	if err != nil {
-		return fmt.Errorf("update service: %s", err)
+		return old, fmt.Errorf("update service: %s", err)
 	}
+	desired.Name = name
+	hash, err := specHash(desired)
+	if err != nil {
+		return old, err
+	}
+	if hasSpecHash(old.ObjectMeta, hash) {
+		return old, nil
+	}
+	s, err := makeService(old)
+	if err != nil {
+		return old, fmt.Errorf("update service: %s", err)
+	}
+	s.Name = name
+	setSpecHash(&s.ObjectMeta, hash)
 
 	// lets copy any missing annotations
 	if old.Annotations != nil {
@@ -700,7 +1352,7 @@ func (c *Operator) syncFunction(key string) error {
 	// lets copy across any missing NodePorts
 	s.Spec.Type = old.Spec.Type
 	oldPortCount := len(old.Spec.Ports)
-	for i, _ := range s.Spec.Ports {
+	for i := range s.Spec.Ports {
 		if i < oldPortCount {
 			s.Spec.Ports[i].NodePort = old.Spec.Ports[i].NodePort
 		}
@@ -710,9 +1362,34 @@ func (c *Operator) syncFunction(key string) error {
 	s.ResourceVersion = old.ResourceVersion
 	s.Spec.ClusterIP = old.Spec.ClusterIP
 
-	if _, err := serviceClient.Update(s); err != nil {
-		c.logger.Log("msg", "failed to update service", "name", s.Name, "namespace", function.Namespace)
+	s2, err := serviceClient.Update(s)
+	if err != nil {
+		c.logger.Log("msg", "failed to update service", "name", s.Name, "namespace", owner.Namespace)
+		return old, err
+	}
+	c.recorder.Eventf(resourceReference(owner), v1.EventTypeNormal, ReasonUpdated, "Updated Service %s", s2.Name)
+	return s2, nil
+}
+
+// removeFlowService deletes the Service we created for a Flow whose Connector no longer has a service template.
+// Services which are not owned by the Flow are left alone
+func (c *Operator) removeFlowService(flow *v1.ConfigMap, key string) error {
+	objs, err := managedBy(c.serviceInf, FlowKind, key)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return nil
+	}
+	service := objs[0].(*v1.Service)
+	if !isOwnedBy(service.ObjectMeta, flow) {
+		return nil
+	}
+	orphan := false
+	if err := c.kclient.Core().Services(service.Namespace).Delete(service.Name, &api.DeleteOptions{OrphanDependents: &orphan}); err != nil {
+		c.recorder.Eventf(resourceReference(flow), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete Service %s: %s", service.Name, err)
 		return err
 	}
+	c.recorder.Eventf(resourceReference(flow), v1.EventTypeNormal, ReasonDeleted, "Deleted Service %s", service.Name)
 	return nil
 }
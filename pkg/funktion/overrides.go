@@ -0,0 +1,223 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/client-go/1.5/pkg/api/resource"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/util/validation"
+)
+
+const (
+	// CPURequestProperty is the data key for the CPU request of a Function such as `250m`
+	CPURequestProperty = "cpuRequest"
+	// CPULimitProperty is the data key for the CPU limit of a Function
+	CPULimitProperty = "cpuLimit"
+	// MemoryRequestProperty is the data key for the memory request of a Function such as `256Mi`
+	MemoryRequestProperty = "memoryRequest"
+	// MemoryLimitProperty is the data key for the memory limit of a Function
+	MemoryLimitProperty = "memoryLimit"
+	// ReplicasProperty is the data key for the number of replicas of a Function which is not autoscaled
+	ReplicasProperty = "replicas"
+	// NodeSelectorProperty is the data key for the newline separated `KEY=VALUE` node labels a Function must run on
+	NodeSelectorProperty = "nodeSelector"
+	// TolerationsProperty is the data key for the YAML list of the tolerations of a Function
+	TolerationsProperty = "tolerations"
+	// AffinityProperty is the data key for the YAML affinity of a Function
+	AffinityProperty = "affinity"
+	// ImagePullSecretsProperty is the data key for the comma separated names of the Secrets used to pull the image of a Function
+	ImagePullSecretsProperty = "imagePullSecrets"
+
+	// the scheduler reads the tolerations and affinity of a pod from these annotations
+	tolerationsAnnotation = "scheduler.alpha.kubernetes.io/tolerations"
+	affinityAnnotation    = "scheduler.alpha.kubernetes.io/affinity"
+)
+
+// podProperties are the data keys of a Function which override the pod template of its Runtime
+var podProperties = []string{
+	CPURequestProperty, CPULimitProperty, MemoryRequestProperty, MemoryLimitProperty,
+	NodeSelectorProperty, TolerationsProperty, AffinityProperty, ImagePullSecretsProperty,
+}
+
+// podOverrides holds the settings of a Function which are merged onto the pod template of its Runtime
+type podOverrides struct {
+	Requests         v1.ResourceList
+	Limits           v1.ResourceList
+	NodeSelector     map[string]string
+	Tolerations      []v1.Toleration
+	Affinity         *v1.Affinity
+	ImagePullSecrets []v1.LocalObjectReference
+}
+
+// podOverridesForFunction parses the pod overrides from the data of the given Function or one of its revisions
+func podOverridesForFunction(name string, data map[string]string) (*podOverrides, error) {
+	overrides := &podOverrides{
+		Requests: v1.ResourceList{},
+		Limits:   v1.ResourceList{},
+	}
+	quantities := []struct {
+		key  string
+		list v1.ResourceList
+		name v1.ResourceName
+	}{
+		{CPURequestProperty, overrides.Requests, v1.ResourceCPU},
+		{CPULimitProperty, overrides.Limits, v1.ResourceCPU},
+		{MemoryRequestProperty, overrides.Requests, v1.ResourceMemory},
+		{MemoryLimitProperty, overrides.Limits, v1.ResourceMemory},
+	}
+	for _, q := range quantities {
+		text := strings.TrimSpace(data[q.key])
+		if len(text) == 0 {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(text)
+		if err != nil {
+			return nil, fmt.Errorf("Function %s has invalid `%s`: %s", name, q.key, err)
+		}
+		q.list[q.name] = quantity
+	}
+	for _, resourceName := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		request, hasRequest := overrides.Requests[resourceName]
+		limit, hasLimit := overrides.Limits[resourceName]
+		if hasRequest && hasLimit && request.Cmp(limit) > 0 {
+			return nil, fmt.Errorf("Function %s requests more %s than its limit", name, resourceName)
+		}
+	}
+
+	for i, line := range strings.Split(data[NodeSelectorProperty], "\n") {
+		l := strings.TrimSpace(line)
+		if len(l) == 0 {
+			continue
+		}
+		pair := strings.SplitN(l, "=", 2)
+		if len(pair) != 2 || len(validation.IsQualifiedName(pair[0])) > 0 || len(validation.IsValidLabelValue(pair[1])) > 0 {
+			return nil, fmt.Errorf("Function %s has invalid `%s` line %d. Expecting `KEY=VALUE` but got: %s", name, NodeSelectorProperty, i+1, l)
+		}
+		if overrides.NodeSelector == nil {
+			overrides.NodeSelector = map[string]string{}
+		}
+		overrides.NodeSelector[pair[0]] = pair[1]
+	}
+
+	if text := data[TolerationsProperty]; len(strings.TrimSpace(text)) > 0 {
+		if err := yaml.Unmarshal([]byte(text), &overrides.Tolerations); err != nil {
+			return nil, fmt.Errorf("Function %s has invalid `%s` YAML: %s", name, TolerationsProperty, err)
+		}
+	}
+	if text := data[AffinityProperty]; len(strings.TrimSpace(text)) > 0 {
+		overrides.Affinity = &v1.Affinity{}
+		if err := yaml.Unmarshal([]byte(text), overrides.Affinity); err != nil {
+			return nil, fmt.Errorf("Function %s has invalid `%s` YAML: %s", name, AffinityProperty, err)
+		}
+	}
+
+	for _, secret := range strings.Split(data[ImagePullSecretsProperty], ",") {
+		secret = strings.TrimSpace(secret)
+		if len(secret) == 0 {
+			continue
+		}
+		if len(validation.IsDNS1123Subdomain(secret)) > 0 {
+			return nil, fmt.Errorf("Function %s has invalid `%s`. `%s` is not a valid Secret name", name, ImagePullSecretsProperty, secret)
+		}
+		overrides.ImagePullSecrets = append(overrides.ImagePullSecrets, v1.LocalObjectReference{Name: secret})
+	}
+	return overrides, nil
+}
+
+// apply merges the overrides onto the given pod template of a Runtime. The resources apply to the
+// first container which runs the function
+func (o *podOverrides) apply(template *v1.PodTemplateSpec) error {
+	podSpec := &template.Spec
+	if len(podSpec.Containers) > 0 {
+		resources := &podSpec.Containers[0].Resources
+		if len(o.Requests) > 0 && resources.Requests == nil {
+			resources.Requests = v1.ResourceList{}
+		}
+		for k, v := range o.Requests {
+			resources.Requests[k] = v
+		}
+		if len(o.Limits) > 0 && resources.Limits == nil {
+			resources.Limits = v1.ResourceList{}
+		}
+		for k, v := range o.Limits {
+			resources.Limits[k] = v
+		}
+	}
+	if len(o.NodeSelector) > 0 && podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = map[string]string{}
+	}
+	for k, v := range o.NodeSelector {
+		podSpec.NodeSelector[k] = v
+	}
+	for _, secret := range o.ImagePullSecrets {
+		found := false
+		for _, s := range podSpec.ImagePullSecrets {
+			if s.Name == secret.Name {
+				found = true
+			}
+		}
+		if !found {
+			podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, secret)
+		}
+	}
+
+	if o.Tolerations != nil {
+		if err := setJSONAnnotation(&template.ObjectMeta, tolerationsAnnotation, o.Tolerations); err != nil {
+			return err
+		}
+	}
+	if o.Affinity != nil {
+		if err := setJSONAnnotation(&template.ObjectMeta, affinityAnnotation, o.Affinity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setJSONAnnotation(meta *v1.ObjectMeta, key string, obj interface{}) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal annotation `%s`: %v", key, err)
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = string(b)
+	return nil
+}
+
+// replicasForFunction returns the number of replicas of the given Function or nil if it uses the replicas
+// of its Runtime. The replicas of an autoscaled Function are left to its HorizontalPodAutoscaler
+func replicasForFunction(function *v1.ConfigMap) (*int32, error) {
+	text := strings.TrimSpace(function.Data[ReplicasProperty])
+	if len(text) == 0 {
+		return nil, nil
+	}
+	if len(function.Data[MaxReplicasProperty]) > 0 {
+		return nil, fmt.Errorf("Function %s cannot have both `%s` and `%s`", function.Name, ReplicasProperty, MaxReplicasProperty)
+	}
+	value, err := strconv.ParseInt(text, 10, 32)
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("Function %s has invalid `%s`: %s", function.Name, ReplicasProperty, text)
+	}
+	replicas := int32(value)
+	return &replicas, nil
+}
@@ -0,0 +1,110 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const overridesTestDeployment = `
+spec:
+  replicas: 2
+  template:
+    spec:
+      nodeSelector:
+        zone: east
+      containers:
+      - image: funktion/nodejs
+        resources:
+          requests:
+            cpu: 100m
+`
+
+func TestMakeFunctionDeploymentOverrides(t *testing.T) {
+	runtime := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "nodejs"},
+		Data:       map[string]string{DeploymentProperty: overridesTestDeployment},
+	}
+	function := functionConfigMap("nodejs", map[string]string{
+		SourceProperty:           "module.exports = function(context, callback) {}",
+		ReplicasProperty:         "3",
+		MemoryRequestProperty:    "256Mi",
+		MemoryLimitProperty:      "512Mi",
+		NodeSelectorProperty:     "disk=ssd\n",
+		TolerationsProperty:      "- key: dedicated\n  operator: Equal\n  value: functions\n  effect: NoSchedule\n",
+		ImagePullSecretsProperty: "registry, registry",
+	})
+	revision := &functionRevision{Number: 1, Data: makeRevision(function, 1, "")}
+	d, err := makeFunctionDeployment(function, runtime, revision, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := deploymentReplicas(d); actual != 3 {
+		t.Errorf("expected 3 replicas but got %d", actual)
+	}
+	podSpec := d.Spec.Template.Spec
+	resources := podSpec.Containers[0].Resources
+	if actual := resourceString(resources.Requests, v1.ResourceMemory); actual != "256Mi" {
+		t.Errorf("expected a memory request of 256Mi but got %s", actual)
+	}
+	if actual := resourceString(resources.Requests, v1.ResourceCPU); actual != "100m" {
+		t.Errorf("expected the cpu request of the runtime to be kept but got %s", actual)
+	}
+	if actual := resourceString(resources.Limits, v1.ResourceMemory); actual != "512Mi" {
+		t.Errorf("expected a memory limit of 512Mi but got %s", actual)
+	}
+	if podSpec.NodeSelector["zone"] != "east" || podSpec.NodeSelector["disk"] != "ssd" {
+		t.Errorf("expected the node selectors to be merged but got %v", podSpec.NodeSelector)
+	}
+	if len(podSpec.ImagePullSecrets) != 1 || podSpec.ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("expected a single image pull secret but got %v", podSpec.ImagePullSecrets)
+	}
+	if actual := d.Spec.Template.Annotations[tolerationsAnnotation]; !strings.Contains(actual, `"key":"dedicated"`) {
+		t.Errorf("expected the tolerations annotation to be set but got %s", actual)
+	}
+}
+
+func resourceString(list v1.ResourceList, name v1.ResourceName) string {
+	quantity, ok := list[name]
+	if !ok {
+		return ""
+	}
+	return quantity.String()
+}
+
+func TestInvalidOverrides(t *testing.T) {
+	for _, data := range []map[string]string{
+		{CPURequestProperty: "lots"},
+		{MemoryRequestProperty: "1Gi", MemoryLimitProperty: "512Mi"},
+		{NodeSelectorProperty: "disk"},
+		{TolerationsProperty: "key: dedicated"},
+		{ImagePullSecretsProperty: "My_Registry"},
+	} {
+		if _, err := podOverridesForFunction("hello", data); err == nil {
+			t.Errorf("expected an error for %v", data)
+		}
+	}
+	for _, data := range []map[string]string{
+		{ReplicasProperty: "-1"},
+		{ReplicasProperty: "2", MaxReplicasProperty: "5"},
+	} {
+		if _, err := replicasForFunction(autoscaledFunction(data)); err == nil {
+			t.Errorf("expected an error for %v", data)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/funktionio/funktion/pkg/client"
+	"github.com/funktionio/funktion/pkg/spec"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/labels"
+)
+
+// ResourceClient reads and writes the ConfigMap form of the Functions, Flows, Runtimes or Connectors
+// in a namespace whether they are stored as custom resources or label based ConfigMaps
+type ResourceClient struct {
+	kclient   kubernetes.Interface
+	tclient   *client.Client
+	namespace string
+	kind      string
+}
+
+// NewResourceClient creates a ResourceClient for the resources of the given kind. If tclient is nil then
+// the custom resources are not registered on the cluster and only label based ConfigMaps are used
+func NewResourceClient(kclient kubernetes.Interface, tclient *client.Client, namespace string, kind string) *ResourceClient {
+	return &ResourceClient{
+		kclient:   kclient,
+		tclient:   tclient,
+		namespace: namespace,
+		kind:      kind,
+	}
+}
+
+// List returns the resources matching the given options. A custom resource hides
+// a label based ConfigMap of the same name
+func (r *ResourceClient) List(opts api.ListOptions) (*v1.ConfigMapList, error) {
+	cms, err := r.kclient.Core().ConfigMaps(r.namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.tclient == nil {
+		return cms, nil
+	}
+	custom, err := ListCustomResources(r.tclient, r.kind, r.namespace, api.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	selector := opts.LabelSelector
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	answer := &v1.ConfigMapList{}
+	names := map[string]bool{}
+	for _, item := range custom.Items {
+		if selector.Matches(labels.Set(item.Labels)) {
+			answer.Items = append(answer.Items, item)
+			names[item.Namespace+"/"+item.Name] = true
+		}
+	}
+	for _, item := range cms.Items {
+		if !names[item.Namespace+"/"+item.Name] {
+			answer.Items = append(answer.Items, item)
+		}
+	}
+	return answer, nil
+}
+
+// Get returns the resource with the given name preferring the custom resource over a label based ConfigMap
+func (r *ResourceClient) Get(name string) (*v1.ConfigMap, error) {
+	if r.tclient != nil {
+		cm, err := r.getCustomResource(name)
+		if err == nil || !errors.IsNotFound(err) {
+			return cm, err
+		}
+	}
+	cm, err := r.kclient.Core().ConfigMaps(r.namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if cm.Labels[KindLabel] != r.kind {
+		return nil, errors.NewNotFound(api.Resource(strings.ToLower(r.kind)+"s"), name)
+	}
+	return cm, nil
+}
+
+// Create creates a custom resource from the given label based ConfigMap if the custom resources are registered
+// or the ConfigMap itself otherwise
+func (r *ResourceClient) Create(cm *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if r.tclient == nil {
+		return r.kclient.Core().ConfigMaps(r.namespace).Create(cm)
+	}
+	obj, err := FromConfigMap(cm)
+	if err != nil {
+		return nil, err
+	}
+	switch o := obj.(type) {
+	case *spec.Function:
+		obj, err = r.tclient.Functions(r.namespace).Create(o)
+	case *spec.Flow:
+		obj, err = r.tclient.Flows(r.namespace).Create(o)
+	case *spec.Runtime:
+		obj, err = r.tclient.Runtimes(r.namespace).Create(o)
+	case *spec.Connector:
+		obj, err = r.tclient.Connectors(r.namespace).Create(o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToConfigMap(obj)
+}
+
+// Update updates the custom resource or label based ConfigMap the given ConfigMap form was read from.
+// A ConfigMap which was not read from the cluster updates the custom resource of the same name if there is one
+func (r *ResourceClient) Update(cm *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if !IsCustomResource(cm) && r.tclient != nil && len(cm.ResourceVersion) == 0 {
+		if _, err := r.getCustomResource(cm.Name); err == nil {
+			form := *cm
+			form.APIVersion = spec.APIVersion
+			form.Kind = r.kind
+			cm = &form
+		}
+	}
+	if !IsCustomResource(cm) {
+		cms := r.kclient.Core().ConfigMaps(r.namespace)
+		if len(cm.ResourceVersion) == 0 && len(cm.Finalizers) == 0 {
+			// lets not drop the finalizer of the operator when replacing the ConfigMap
+			if old, err := cms.Get(cm.Name); err == nil {
+				cm.Finalizers = old.Finalizers
+			}
+		}
+		return cms.Update(cm)
+	}
+	if r.tclient == nil {
+		return nil, fmt.Errorf("Cannot update %s %s as the custom resources are not registered", cm.Kind, cm.Name)
+	}
+	obj, err := FromConfigMap(cm)
+	if err != nil {
+		return nil, err
+	}
+	switch o := obj.(type) {
+	case *spec.Function:
+		obj, err = r.tclient.Functions(r.namespace).Update(o)
+	case *spec.Flow:
+		obj, err = r.tclient.Flows(r.namespace).Update(o)
+	case *spec.Runtime:
+		obj, err = r.tclient.Runtimes(r.namespace).Update(o)
+	case *spec.Connector:
+		obj, err = r.tclient.Connectors(r.namespace).Update(o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToConfigMap(obj)
+}
+
+// Delete deletes the resource with the given name
+func (r *ResourceClient) Delete(name string) error {
+	if r.tclient != nil {
+		err := r.deleteCustomResource(name)
+		if err == nil || !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return r.kclient.Core().ConfigMaps(r.namespace).Delete(name, &api.DeleteOptions{})
+}
+
+func (r *ResourceClient) getCustomResource(name string) (*v1.ConfigMap, error) {
+	var obj interface{}
+	var err error
+	switch r.kind {
+	case FunctionKind:
+		obj, err = r.tclient.Functions(r.namespace).Get(name)
+	case FlowKind:
+		obj, err = r.tclient.Flows(r.namespace).Get(name)
+	case RuntimeKind:
+		obj, err = r.tclient.Runtimes(r.namespace).Get(name)
+	case ConnectorKind:
+		obj, err = r.tclient.Connectors(r.namespace).Get(name)
+	default:
+		err = fmt.Errorf("Unknown kind %s", r.kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ToConfigMap(obj)
+}
+
+func (r *ResourceClient) deleteCustomResource(name string) error {
+	opts := &api.DeleteOptions{}
+	switch r.kind {
+	case FunctionKind:
+		return r.tclient.Functions(r.namespace).Delete(name, opts)
+	case FlowKind:
+		return r.tclient.Flows(r.namespace).Delete(name, opts)
+	case RuntimeKind:
+		return r.tclient.Runtimes(r.namespace).Delete(name, opts)
+	case ConnectorKind:
+		return r.tclient.Connectors(r.namespace).Delete(name, opts)
+	default:
+		return fmt.Errorf("Unknown kind %s", r.kind)
+	}
+}
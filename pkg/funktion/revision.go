@@ -0,0 +1,597 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/labels"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+const (
+	// RevisionLabel is the label holding the revision number on the snapshot ConfigMap,
+	// the Deployment and the pods of a revision of a Function
+	RevisionLabel = "funktion.fabric8.io/revision"
+
+	// TrafficProperty is the data key for how the requests of a Function are split between its revisions
+	// as a comma separated list of `REVISION=PERCENT` such as `3=90,4=10`. The latest revision receives
+	// all the traffic if it is not specified
+	TrafficProperty = "traffic"
+
+	// revisionHistoryLimit is the number of revisions kept when they no longer receive traffic
+	revisionHistoryLimit = 10
+
+	// revisionIndex is the name of the index of the snapshot ConfigMaps and Deployments of revisions by the key of their Function
+	revisionIndex = "revision"
+
+	// rolloutPollInterval is how often we check whether the Deployments receiving the traffic of a Function
+	// are available so that the Deployments of its previous revisions can be removed
+	rolloutPollInterval = 5 * time.Second
+)
+
+// revisionProperties are the data keys of a Function which make up a revision along with its files. Changing any of them creates a new revision
+var revisionProperties = append([]string{SourceProperty, EnvVarsProperty, DebugProperty}, podProperties...)
+
+// TrafficTarget is the percentage of the requests of a Function sent to one of its revisions
+type TrafficTarget struct {
+	Revision int
+	Percent  int
+}
+
+// functionRevision is a revision of a Function to be run by a Deployment
+type functionRevision struct {
+	Number int
+	// Data is the immutable snapshot ConfigMap of the revision
+	Data *v1.ConfigMap
+	// Replicas is the number of pods for the traffic split or nil to use the Runtime template or the autoscaler
+	Replicas *int32
+}
+
+// RevisionName returns the name of the snapshot ConfigMap and the Deployment of the given revision of a Function
+func RevisionName(function string, revision int) string {
+	return fmt.Sprintf("%s-v%d", function, revision)
+}
+
+// ParseTraffic parses the `REVISION=PERCENT` pairs of the traffic property. The percentages must add up to 100
+func ParseTraffic(text string) ([]TrafficTarget, error) {
+	answer := []TrafficTarget{}
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return answer, nil
+	}
+	total := 0
+	seen := map[int]bool{}
+	for _, item := range strings.Split(text, ",") {
+		pair := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("Invalid `%s`. Expecting `REVISION=PERCENT` but got: %s", TrafficProperty, item)
+		}
+		revision, err := strconv.Atoi(strings.TrimSpace(pair[0]))
+		if err != nil || revision < 1 {
+			return nil, fmt.Errorf("Invalid `%s` revision: %s", TrafficProperty, pair[0])
+		}
+		percent, err := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("Invalid `%s` percentage for revision %d: %s", TrafficProperty, revision, pair[1])
+		}
+		if seen[revision] {
+			return nil, fmt.Errorf("Invalid `%s`. Revision %d is listed more than once", TrafficProperty, revision)
+		}
+		seen[revision] = true
+		total += percent
+		answer = append(answer, TrafficTarget{Revision: revision, Percent: percent})
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("Invalid `%s`. The percentages must add up to 100 but add up to %d", TrafficProperty, total)
+	}
+	return answer, nil
+}
+
+// FormatTraffic formats the traffic split as the value of the traffic property
+func FormatTraffic(traffic []TrafficTarget) string {
+	items := []string{}
+	for _, t := range traffic {
+		items = append(items, fmt.Sprintf("%d=%d", t.Revision, t.Percent))
+	}
+	return strings.Join(items, ",")
+}
+
+// RolloutTraffic returns the traffic split sending the given percentage of requests to the given revision
+// and the rest to the revision currently receiving the most traffic. If the revision already receives all the
+// traffic the rest is sent to the previous revision
+func RolloutTraffic(current []TrafficTarget, revisions []int, revision int, percent int) ([]TrafficTarget, error) {
+	if percent < 0 || percent > 100 {
+		return nil, fmt.Errorf("The percentage must be between 0 and 100 but was %d", percent)
+	}
+	found := false
+	previous := 0
+	for _, r := range revisions {
+		if r == revision {
+			found = true
+		} else if r < revision && r > previous {
+			previous = r
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Revision %d does not exist", revision)
+	}
+	if percent == 100 {
+		return []TrafficTarget{{Revision: revision, Percent: 100}}, nil
+	}
+	stable := 0
+	if primary := primaryTarget(withoutRevision(current, revision)); primary != nil {
+		stable = primary.Revision
+	} else {
+		stable = previous
+	}
+	if stable == 0 {
+		return nil, fmt.Errorf("There is no other revision to send the remaining traffic to")
+	}
+	return []TrafficTarget{
+		{Revision: stable, Percent: 100 - percent},
+		{Revision: revision, Percent: percent},
+	}, nil
+}
+
+// PromoteTraffic returns the traffic split sending all requests to the revision being rolled out
+// which is the latest revision receiving traffic
+func PromoteTraffic(current []TrafficTarget) ([]TrafficTarget, error) {
+	canary := canaryTarget(current)
+	if canary == nil {
+		return nil, fmt.Errorf("There is no rollout in progress")
+	}
+	return []TrafficTarget{{Revision: canary.Revision, Percent: 100}}, nil
+}
+
+// AbortTraffic returns the traffic split sending all requests back to the revision receiving the most
+// traffic other than the revision being rolled out
+func AbortTraffic(current []TrafficTarget) ([]TrafficTarget, error) {
+	canary := canaryTarget(current)
+	if canary == nil {
+		return nil, fmt.Errorf("There is no rollout in progress")
+	}
+	stable := primaryTarget(withoutRevision(current, canary.Revision))
+	return []TrafficTarget{{Revision: stable.Revision, Percent: 100}}, nil
+}
+
+// canaryTarget returns the latest revision of a traffic split across more than one revision
+func canaryTarget(traffic []TrafficTarget) *TrafficTarget {
+	var answer *TrafficTarget
+	count := 0
+	for i, t := range traffic {
+		if t.Percent == 0 {
+			continue
+		}
+		count++
+		if answer == nil || t.Revision > answer.Revision {
+			answer = &traffic[i]
+		}
+	}
+	if count < 2 {
+		return nil
+	}
+	return answer
+}
+
+// primaryTarget returns the revision receiving the most traffic preferring the latest revision on a tie
+func primaryTarget(traffic []TrafficTarget) *TrafficTarget {
+	var answer *TrafficTarget
+	for i, t := range traffic {
+		if t.Percent == 0 {
+			continue
+		}
+		if answer == nil || t.Percent > answer.Percent || (t.Percent == answer.Percent && t.Revision > answer.Revision) {
+			answer = &traffic[i]
+		}
+	}
+	return answer
+}
+
+func withoutRevision(traffic []TrafficTarget, revision int) []TrafficTarget {
+	answer := []TrafficTarget{}
+	for _, t := range traffic {
+		if t.Revision != revision {
+			answer = append(answer, t)
+		}
+	}
+	return answer
+}
+
+// replicasForTraffic returns the number of pods of a revision receiving the given percentage of the
+// requests of a Function running the given total number of pods. As the Service balances requests
+// across pods the split is only as accurate as the number of pods allows
+func replicasForTraffic(total int32, percent int) int32 {
+	replicas := (total*int32(percent) + 50) / 100
+	if replicas < 1 {
+		replicas = 1
+	}
+	return replicas
+}
+
+// ListRevisions returns the snapshot ConfigMaps of the revisions of the given Function ordered by revision
+func ListRevisions(kclient kubernetes.Interface, namespace string, name string) ([]*v1.ConfigMap, error) {
+	selector, err := labels.Parse(fmt.Sprintf("%s=%s,%s", NameLabel, name, RevisionLabel))
+	if err != nil {
+		return nil, err
+	}
+	list, err := kclient.Core().ConfigMaps(namespace).List(api.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	answer := []*v1.ConfigMap{}
+	for i := range list.Items {
+		item := &list.Items[i]
+		if RevisionNumber(item.ObjectMeta) > 0 && item.Labels[NameLabel] == name && len(item.Labels[KindLabel]) == 0 {
+			answer = append(answer, item)
+		}
+	}
+	sort.Sort(revisionsByNumber(answer))
+	return answer, nil
+}
+
+type revisionsByNumber []*v1.ConfigMap
+
+func (r revisionsByNumber) Len() int      { return len(r) }
+func (r revisionsByNumber) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r revisionsByNumber) Less(i, j int) bool {
+	return RevisionNumber(r[i].ObjectMeta) < RevisionNumber(r[j].ObjectMeta)
+}
+
+// RevisionNumber returns the revision of a snapshot ConfigMap or Deployment or 0 if it is not a revision
+func RevisionNumber(meta v1.ObjectMeta) int {
+	if meta.Labels == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(meta.Labels[RevisionLabel])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// revisionData returns the data of the given Function which makes up a revision
+func revisionData(function *v1.ConfigMap) map[string]string {
+	data := map[string]string{}
+	for key, value := range function.Data {
+		if IsFileProperty(key) {
+			data[key] = value
+		}
+	}
+	for _, key := range revisionProperties {
+		if value, ok := function.Data[key]; ok {
+			data[key] = value
+		}
+	}
+	return data
+}
+
+// makeRevision returns the snapshot ConfigMap of the given revision of a Function
+func makeRevision(function *v1.ConfigMap, number int, hash string) *v1.ConfigMap {
+	revision := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      RevisionName(function.Name, number),
+			Namespace: function.Namespace,
+			Labels: map[string]string{
+				NameLabel:     function.Name,
+				RevisionLabel: strconv.Itoa(number),
+			},
+		},
+		Data: revisionData(function),
+	}
+	setSpecHash(&revision.ObjectMeta, hash)
+	setManagedBy(&revision.ObjectMeta, function)
+	setOwnerReference(&revision.ObjectMeta, ownerReference(function))
+	return revision
+}
+
+// trafficForFunction returns the traffic split of the Function across the given revisions
+func trafficForFunction(function *v1.ConfigMap, revisions []*v1.ConfigMap) ([]TrafficTarget, error) {
+	traffic, err := ParseTraffic(function.Data[TrafficProperty])
+	if err != nil {
+		return nil, fmt.Errorf("Function %s: %s", function.Name, err)
+	}
+	if len(traffic) == 0 {
+		if len(revisions) == 0 {
+			return traffic, nil
+		}
+		latest := revisions[len(revisions)-1]
+		return []TrafficTarget{{Revision: RevisionNumber(latest.ObjectMeta), Percent: 100}}, nil
+	}
+	for _, t := range traffic {
+		if findRevision(revisions, t.Revision) == nil {
+			return nil, fmt.Errorf("Function %s sends traffic to revision %d which does not exist", function.Name, t.Revision)
+		}
+	}
+	return traffic, nil
+}
+
+func findRevision(revisions []*v1.ConfigMap, number int) *v1.ConfigMap {
+	for _, r := range revisions {
+		if RevisionNumber(r.ObjectMeta) == number {
+			return r
+		}
+	}
+	return nil
+}
+
+// revisionIndexFunc indexes the snapshot ConfigMaps and Deployments of Function revisions by the key of their Function
+func revisionIndexFunc(obj interface{}) ([]string, error) {
+	var objectMeta v1.ObjectMeta
+	switch o := obj.(type) {
+	case *v1beta1.Deployment:
+		objectMeta = o.ObjectMeta
+	case *v1.ConfigMap:
+		if len(o.Labels[KindLabel]) > 0 {
+			return []string{}, nil
+		}
+		objectMeta = o.ObjectMeta
+	default:
+		return []string{}, nil
+	}
+	if RevisionNumber(objectMeta) == 0 {
+		return []string{}, nil
+	}
+	return []string{referenceKey(objectMeta.Namespace, objectMeta.Labels[NameLabel])}, nil
+}
+
+// cachedRevisions returns the snapshot ConfigMaps of the revisions of the Function with the given key
+// from the informer cache ordered by revision
+func (c *Operator) cachedRevisions(key string) ([]*v1.ConfigMap, error) {
+	objs, err := c.revisionInf.GetIndexer().ByIndex(revisionIndex, key)
+	if err != nil {
+		return nil, err
+	}
+	answer := []*v1.ConfigMap{}
+	for _, obj := range objs {
+		answer = append(answer, obj.(*v1.ConfigMap))
+	}
+	sort.Sort(revisionsByNumber(answer))
+	return answer, nil
+}
+
+// reconcileRevisions creates a new revision of the Function if its revision data has changed since
+// the latest revision, returning all the revisions of the Function ordered by revision
+func (c *Operator) reconcileRevisions(function *v1.ConfigMap) ([]*v1.ConfigMap, error) {
+	revisions, err := c.cachedRevisions(referenceKey(function.Namespace, function.Name))
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %s", err)
+	}
+	hash, err := specHash(revisionData(function))
+	if err != nil {
+		return nil, err
+	}
+	latest := 0
+	if len(revisions) > 0 {
+		last := revisions[len(revisions)-1]
+		if hasSpecHash(last.ObjectMeta, hash) {
+			return revisions, nil
+		}
+		latest = RevisionNumber(last.ObjectMeta)
+	}
+	name := RevisionName(function.Name, latest+1)
+	revision, err := c.kclient.Core().ConfigMaps(function.Namespace).Create(makeRevision(function, latest+1, hash))
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			// the cache may not have seen the revision created by an earlier sync yet
+			existing, getErr := c.kclient.Core().ConfigMaps(function.Namespace).Get(name)
+			if getErr == nil && RevisionNumber(existing.ObjectMeta) == latest+1 && existing.Labels[NameLabel] == function.Name && len(existing.Labels[KindLabel]) == 0 {
+				if hasSpecHash(existing.ObjectMeta, hash) {
+					return append(revisions, existing), nil
+				}
+				return nil, fmt.Errorf("create revision: revision %d of Function %s is not cached yet", latest+1, function.Name)
+			}
+			return nil, fmt.Errorf("create revision: ConfigMap %s already exists and is not a revision of Function %s", name, function.Name)
+		}
+		return nil, fmt.Errorf("create revision: %s", err)
+	}
+	c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonCreated, "Created revision %d", latest+1)
+	return append(revisions, revision), nil
+}
+
+// pruneRevisions deletes the oldest revisions which no longer receive traffic beyond the history limit.
+// Revisions of the Function which are not owned by it are adopted first
+func (c *Operator) pruneRevisions(function *v1.ConfigMap, revisions []*v1.ConfigMap, traffic []TrafficTarget) error {
+	inUse := map[int]bool{}
+	for _, t := range traffic {
+		inUse[t.Revision] = true
+	}
+	cms := c.kclient.Core().ConfigMaps(function.Namespace)
+	for i, revision := range revisions {
+		if len(function.UID) == 0 || isOwnedBy(revision.ObjectMeta, function) {
+			continue
+		}
+		adopted, err := c.adoptRevision(function, revision)
+		if err != nil {
+			return err
+		}
+		revisions[i] = adopted
+	}
+	for i := 0; i < len(revisions)-revisionHistoryLimit; i++ {
+		revision := revisions[i]
+		if inUse[RevisionNumber(revision.ObjectMeta)] || !isOwnedBy(revision.ObjectMeta, function) {
+			continue
+		}
+		if err := cms.Delete(revision.Name, nil); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete revision: %s", err)
+		}
+	}
+	return nil
+}
+
+// adoptRevision makes the Function the owner of one of its revisions which was written before the Function had
+// a uid or which belongs to the form of the Function that existed before it was migrated to or from a custom resource
+func (c *Operator) adoptRevision(function *v1.ConfigMap, revision *v1.ConfigMap) (*v1.ConfigMap, error) {
+	adopted := *revision
+	adopted.Labels = copyStringMap(revision.Labels)
+	adopted.Annotations = copyStringMap(revision.Annotations)
+	setManagedBy(&adopted.ObjectMeta, function)
+	setOwnerReference(&adopted.ObjectMeta, ownerReference(function))
+	updated, err := c.kclient.Core().ConfigMaps(function.Namespace).Update(&adopted)
+	if err != nil {
+		return nil, fmt.Errorf("adopt revision: %s", err)
+	}
+	c.logger.Log("msg", "adopted revision", "name", revision.Name, "namespace", revision.Namespace)
+	return updated, nil
+}
+
+// destroyRevisions removes the snapshot ConfigMaps of a deleted Function if the garbage collector has not already done so
+func (c *Operator) destroyRevisions(key string) error {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	revisions, err := c.cachedRevisions(key)
+	if err != nil {
+		return err
+	}
+	cms := c.kclient.Core().ConfigMaps(namespace)
+	for _, revision := range revisions {
+		if !hasFunktionOwner(revision.ObjectMeta) {
+			continue
+		}
+		if err := cms.Delete(revision.Name, nil); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// destroyRevisionDeployments removes the Deployments of the revisions of a deleted Function. Deployments owned
+// by the Function are left for the garbage collector unless they are still around after a timeout
+func (c *Operator) destroyRevisionDeployments(key string) error {
+	objs, err := c.deploymentInf.GetIndexer().ByIndex(revisionIndex, key)
+	if err != nil {
+		return err
+	}
+	pending := false
+	for _, obj := range objs {
+		deployment := obj.(*v1beta1.Deployment)
+		dkey, ok := c.keyFunc(deployment)
+		if !ok {
+			continue
+		}
+		if c.awaitGarbageCollection(DeploymentKind, dkey, FunctionKind, key, deployment.ObjectMeta) {
+			continue
+		}
+		if err := c.deleteDeployment(deployment); err == errTeardownPending {
+			pending = true
+			continue
+		} else if err != nil {
+			c.recorder.Eventf(keyReference(key), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete Deployment %s: %s", deployment.Name, err)
+			return err
+		}
+		c.orphans.forget(DeploymentKind, dkey)
+		c.recorder.Eventf(keyReference(key), v1.EventTypeNormal, ReasonDeleted, "Deleted Deployment %s", deployment.Name)
+	}
+	if pending {
+		return errTeardownPending
+	}
+	return nil
+}
+
+// adoptLegacyDeployments labels the Deployments created for Functions before they had revisions as managed by
+// the operator. They are named after their Function and, as they predate the managed-by label, are not in the
+// informer cache. Once adopted removeUnusedDeployments deletes them as soon as the Deployments of the revisions
+// receiving the traffic are available rather than leaving them running alongside
+func (c *Operator) adoptLegacyDeployments() {
+	for _, obj := range c.functionInf.GetStore().List() {
+		function := obj.(*v1.ConfigMap)
+		deployments := c.kclient.Extensions().Deployments(function.Namespace)
+		deployment, err := deployments.Get(function.Name)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				c.logger.Log("msg", "failed to get deployment", "name", function.Name, "namespace", function.Namespace, "err", err)
+			}
+			continue
+		}
+		if RevisionNumber(deployment.ObjectMeta) > 0 || !isLegacyResource(deployment.ObjectMeta, function) {
+			continue
+		}
+		setManagedBy(&deployment.ObjectMeta, function)
+		setOwnerReference(&deployment.ObjectMeta, ownerReference(function))
+		if _, err := deployments.Update(deployment); err != nil {
+			c.logger.Log("msg", "failed to adopt deployment", "name", deployment.Name, "namespace", deployment.Namespace, "err", err)
+			continue
+		}
+		c.logger.Log("msg", "adopted deployment created before revisions", "name", deployment.Name, "namespace", deployment.Namespace)
+	}
+}
+
+// removeUnusedDeployments deletes the Deployments of the Function's revisions which no longer receive
+// traffic along with any Deployment created for the Function before it had revisions. They are kept
+// until the Deployments which receive traffic are available so that the Function never goes offline
+func (c *Operator) removeUnusedDeployments(function *v1.ConfigMap, key string, deployments []*v1beta1.Deployment) error {
+	objs, err := c.deploymentInf.GetIndexer().ByIndex(revisionIndex, key)
+	if err != nil {
+		return err
+	}
+	owned, err := managedBy(c.deploymentInf, kindOf(function), key)
+	if err != nil {
+		return err
+	}
+	for _, obj := range owned {
+		if RevisionNumber(obj.(*v1beta1.Deployment).ObjectMeta) == 0 {
+			objs = append(objs, obj)
+		}
+	}
+	active := map[string]bool{}
+	available := true
+	for _, d := range deployments {
+		active[d.Name] = true
+		if deploymentPhase(d) != PhaseReady {
+			available = false
+		}
+	}
+	unused := []*v1beta1.Deployment{}
+	for _, obj := range objs {
+		deployment := obj.(*v1beta1.Deployment)
+		if !active[deployment.Name] && isOwnedBy(deployment.ObjectMeta, function) {
+			unused = append(unused, deployment)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	if !available {
+		c.queue.AddAfter(ResourceKey{Kind: FunctionKind, Key: key}, rolloutPollInterval)
+		return nil
+	}
+
+	pending := false
+	for _, deployment := range unused {
+		if err := c.deleteDeployment(deployment); err == errTeardownPending {
+			pending = true
+			continue
+		} else if err != nil {
+			c.recorder.Eventf(resourceReference(function), v1.EventTypeWarning, ReasonFailedDelete, "Failed to delete Deployment %s: %s", deployment.Name, err)
+			return err
+		}
+		c.recorder.Eventf(resourceReference(function), v1.EventTypeNormal, ReasonDeleted, "Deleted Deployment %s", deployment.Name)
+	}
+	if pending {
+		c.queue.AddAfter(ResourceKey{Kind: FunctionKind, Key: key}, teardownPollInterval)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/1.5/pkg/labels"
+	"k8s.io/client-go/1.5/pkg/runtime"
+)
+
+func TestParseTraffic(t *testing.T) {
+	traffic, err := ParseTraffic(" 3=90, 4=10 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := FormatTraffic(traffic); actual != "3=90,4=10" {
+		t.Errorf("expected 3=90,4=10 but got %s", actual)
+	}
+	for _, text := range []string{"3", "3=90", "3=50,3=50", "0=100", "3=110,4=-10", "three=100"} {
+		if _, err := ParseTraffic(text); err == nil {
+			t.Errorf("expected an error parsing traffic %s", text)
+		}
+	}
+}
+
+func TestRolloutTraffic(t *testing.T) {
+	revisions := []int{1, 2, 3, 4}
+	tests := []struct {
+		current  string
+		revision int
+		percent  int
+		expected string
+	}{
+		{"3=100", 4, 10, "3=90,4=10"},
+		{"3=90,4=10", 4, 50, "3=50,4=50"},
+		{"3=90,4=10", 4, 100, "4=100"},
+		// the latest revision already receives all the traffic when the function has no traffic split
+		{"", 4, 20, "3=80,4=20"},
+		{"4=100", 4, 20, "3=80,4=20"},
+	}
+	for _, test := range tests {
+		current, err := ParseTraffic(test.current)
+		if err != nil {
+			t.Fatal(err)
+		}
+		traffic, err := RolloutTraffic(current, revisions, test.revision, test.percent)
+		if err != nil {
+			t.Fatalf("rollout of %d%% to %d from %s: %s", test.percent, test.revision, test.current, err)
+		}
+		if actual := FormatTraffic(traffic); actual != test.expected {
+			t.Errorf("rollout of %d%% to %d from %s: expected %s but got %s", test.percent, test.revision, test.current, test.expected, actual)
+		}
+	}
+	if _, err := RolloutTraffic(nil, revisions, 5, 10); err == nil {
+		t.Errorf("expected an error rolling out an unknown revision")
+	}
+	if _, err := RolloutTraffic(nil, []int{1}, 1, 10); err == nil {
+		t.Errorf("expected an error rolling out the only revision")
+	}
+}
+
+func TestPromoteAndAbortTraffic(t *testing.T) {
+	current, err := ParseTraffic("3=90,4=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	promoted, err := PromoteTraffic(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := FormatTraffic(promoted); actual != "4=100" {
+		t.Errorf("expected promote to give 4=100 but got %s", actual)
+	}
+	aborted, err := AbortTraffic(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := FormatTraffic(aborted); actual != "3=100" {
+		t.Errorf("expected abort to give 3=100 but got %s", actual)
+	}
+	if _, err := PromoteTraffic(promoted); err == nil {
+		t.Errorf("expected an error promoting without a rollout in progress")
+	}
+}
+
+func TestReplicasForTraffic(t *testing.T) {
+	tests := []struct {
+		total    int32
+		percent  int
+		expected int32
+	}{
+		{10, 90, 9},
+		{10, 10, 1},
+		{4, 25, 1},
+		{1, 10, 1},
+		{3, 50, 2},
+	}
+	for _, test := range tests {
+		if actual := replicasForTraffic(test.total, test.percent); actual != test.expected {
+			t.Errorf("expected %d replicas for %d%% of %d but got %d", test.expected, test.percent, test.total, actual)
+		}
+	}
+}
+
+func TestListRevisions(t *testing.T) {
+	function := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	var objects []*v1.ConfigMap
+	for i, source := range []string{"v1", "v2", "v3"} {
+		function.Data[SourceProperty] = source
+		objects = append(objects, makeRevision(function, 3-i, source))
+	}
+	other := functionConfigMap("nodejs", map[string]string{SourceProperty: "other"})
+	other.Name = "other"
+	objects = append(objects, makeRevision(other, 1, "other"))
+
+	kclient := fake.NewSimpleClientset(objects[0], objects[1], objects[2], objects[3])
+	revisions, err := ListRevisions(kclient, "default", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions but got %d", len(revisions))
+	}
+	for i, revision := range revisions {
+		if actual := RevisionNumber(revision.ObjectMeta); actual != i+1 {
+			t.Errorf("expected revision %d at index %d but got %d", i+1, i, actual)
+		}
+		if revision.Name != RevisionName("hello", i+1) {
+			t.Errorf("expected revision named %s but got %s", RevisionName("hello", i+1), revision.Name)
+		}
+	}
+	if _, ok := revisions[0].Data[SourceProperty]; !ok {
+		t.Errorf("expected the revision to hold the source of the function")
+	}
+}
+
+func TestRevisionIndexFunc(t *testing.T) {
+	function := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	function.Labels[NameLabel] = function.Name
+	function.Labels[RevisionLabel] = "1"
+	keys, err := revisionIndexFunc(function)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected the Function not to be indexed but got %v", keys)
+	}
+
+	keys, err = revisionIndexFunc(makeRevision(function, 2, "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "default/hello" {
+		t.Errorf("expected the revision to be indexed by default/hello but got %v", keys)
+	}
+}
+
+func TestFunctionServiceSelectsAllRevisions(t *testing.T) {
+	runtime := &v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "nodejs"},
+		Data: map[string]string{
+			DeploymentProperty: overridesTestDeployment,
+			ServiceProperty:    "spec:\n  ports:\n  - port: 80\n",
+		},
+	}
+	function := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	primary, err := makeFunctionDeployment(function, runtime, &functionRevision{Number: 1, Data: makeRevision(function, 1, "")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	function.Data[SourceProperty] = "v2"
+	canary, err := makeFunctionDeployment(function, runtime, &functionRevision{Number: 2, Data: makeRevision(function, 2, "")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := makeFunctionService(function, runtime, nil, primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selector := labels.SelectorFromSet(labels.Set(svc.Spec.Selector))
+	for _, d := range []*v1beta1.Deployment{primary, canary} {
+		if !selector.Matches(labels.Set(d.Spec.Template.Labels)) {
+			t.Errorf("expected the Service selector %v to match the pods of %s with labels %v", svc.Spec.Selector, d.Name, d.Spec.Template.Labels)
+		}
+	}
+}
+
+func TestPruneRevisionsAdoptsUnownedRevisions(t *testing.T) {
+	// the revisions were written by the ConfigMap form of the Function before it was migrated
+	old := namedFunction("hello", "old")
+	objects := []runtime.Object{}
+	revisions := []*v1.ConfigMap{}
+	for i := 1; i <= revisionHistoryLimit+2; i++ {
+		revision := makeRevision(old, i, "")
+		objects = append(objects, revision)
+		revisions = append(revisions, revision)
+	}
+	c, kclient := newGCTestOperator(objects...)
+	function := namedFunction("hello", "new")
+
+	traffic := []TrafficTarget{{Revision: revisionHistoryLimit + 2, Percent: 100}}
+	if err := c.pruneRevisions(function, revisions, traffic); err != nil {
+		t.Fatal(err)
+	}
+	cms := kclient.Core().ConfigMaps("default")
+	for i := 1; i <= revisionHistoryLimit+2; i++ {
+		revision, err := cms.Get(RevisionName("hello", i))
+		if i <= 2 {
+			if err == nil {
+				t.Errorf("expected revision %d to be pruned", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected revision %d to be kept but got %v", i, err)
+			continue
+		}
+		if !isOwnedBy(revision.ObjectMeta, function) || isOwnedBy(revision.ObjectMeta, old) {
+			t.Errorf("expected revision %d to be adopted but it is owned by %v", i, revision.OwnerReferences)
+		}
+	}
+}
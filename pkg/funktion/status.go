@@ -0,0 +1,162 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"reflect"
+	"sync"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// StatusPhaseAnnotation is the annotation on a Function or Flow ConfigMap holding the reconcile phase
+	StatusPhaseAnnotation = "funktion.fabric8.io/status.phase"
+	// StatusMessageAnnotation is the annotation holding the last reconcile error message
+	StatusMessageAnnotation = "funktion.fabric8.io/status.message"
+	// StatusObservedVersionAnnotation is the annotation holding the resourceVersion of the ConfigMap last reconciled
+	StatusObservedVersionAnnotation = "funktion.fabric8.io/status.observedResourceVersion"
+	// StatusDeploymentAnnotation is the annotation holding the name of the Deployment created for the resource
+	StatusDeploymentAnnotation = "funktion.fabric8.io/status.deployment"
+	// StatusServiceAnnotation is the annotation holding the name of the Service created for the resource
+	StatusServiceAnnotation = "funktion.fabric8.io/status.service"
+
+	// PhasePending indicates the Deployment has been created or updated but is not yet available
+	PhasePending = "Pending"
+	// PhaseReady indicates the Deployment is available
+	PhaseReady = "Ready"
+	// PhaseFailed indicates the last reconcile failed; see the status message for details
+	PhaseFailed = "Failed"
+)
+
+// Status is the reconcile status the operator writes onto a Function or Flow ConfigMap
+type Status struct {
+	Phase                   string
+	Message                 string
+	ObservedResourceVersion string
+	Deployment              string
+	Service                 string
+}
+
+// GetStatus returns the reconcile status stored on the given Function or Flow ConfigMap
+func GetStatus(cm *v1.ConfigMap) Status {
+	a := cm.Annotations
+	if a == nil {
+		return Status{}
+	}
+	return Status{
+		Phase:                   a[StatusPhaseAnnotation],
+		Message:                 a[StatusMessageAnnotation],
+		ObservedResourceVersion: a[StatusObservedVersionAnnotation],
+		Deployment:              a[StatusDeploymentAnnotation],
+		Service:                 a[StatusServiceAnnotation],
+	}
+}
+
+// setStatus stores the given status on the ConfigMap annotations returning true if anything changed
+func setStatus(cm *v1.ConfigMap, status Status) bool {
+	if GetStatus(cm) == status {
+		return false
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	setStatusAnnotations(cm.Annotations, status)
+	return true
+}
+
+func setStatusAnnotations(annotations map[string]string, status Status) {
+	setOrRemoveAnnotation(annotations, StatusPhaseAnnotation, status.Phase)
+	setOrRemoveAnnotation(annotations, StatusMessageAnnotation, status.Message)
+	setOrRemoveAnnotation(annotations, StatusObservedVersionAnnotation, status.ObservedResourceVersion)
+	setOrRemoveAnnotation(annotations, StatusDeploymentAnnotation, status.Deployment)
+	setOrRemoveAnnotation(annotations, StatusServiceAnnotation, status.Service)
+}
+
+func setOrRemoveAnnotation(annotations map[string]string, key string, value string) {
+	if len(value) == 0 {
+		delete(annotations, key)
+	} else {
+		annotations[key] = value
+	}
+}
+
+// isStatusUpdate returns true if only the status of the Function or Flow changed between the two versions
+// so that the operator writing the status does not trigger another reconcile. Periodic resyncs, which
+// keep the same resourceVersion, are not status updates
+func isStatusUpdate(old *v1.ConfigMap, cur *v1.ConfigMap) bool {
+	if old.ResourceVersion == cur.ResourceVersion {
+		return false
+	}
+	return reflect.DeepEqual(withoutStatus(old), withoutStatus(cur))
+}
+
+func withoutStatus(cm *v1.ConfigMap) v1.ConfigMap {
+	answer := *cm
+	answer.ResourceVersion = ""
+	answer.Annotations = copyStringMap(cm.Annotations)
+	for _, key := range statusAnnotations {
+		delete(answer.Annotations, key)
+	}
+	return answer
+}
+
+// statusVersions remembers the resourceVersion produced by the last status update of each Function or Flow.
+// Writing the status changes the resourceVersion so the observed resourceVersion is always one behind
+// after it even though nothing but the status changed
+type statusVersions struct {
+	lock     sync.Mutex
+	versions map[string]string
+}
+
+func (s *statusVersions) set(key string, resourceVersion string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.versions == nil {
+		s.versions = map[string]string{}
+	}
+	s.versions[key] = resourceVersion
+}
+
+// wrote returns true if the given resourceVersion was produced by the last status update
+func (s *statusVersions) wrote(key string, resourceVersion string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(resourceVersion) > 0 && s.versions[key] == resourceVersion
+}
+
+func (s *statusVersions) forget(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.versions, key)
+}
+
+// deploymentPhase returns the phase for a resource based on the availability of its Deployment
+func deploymentPhase(deployment *v1beta1.Deployment) string {
+	if deployment == nil {
+		return PhasePending
+	}
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas >= replicas &&
+		deployment.Status.AvailableReplicas >= replicas {
+		return PhaseReady
+	}
+	return PhasePending
+}
@@ -0,0 +1,116 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package funktion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/funktionio/funktion/pkg/queue"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+func TestSetStatusComparesObservedVersion(t *testing.T) {
+	cm := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	status := Status{Phase: PhaseReady, ObservedResourceVersion: "1"}
+	if !setStatus(cm, status) {
+		t.Errorf("expected the status to be set")
+	}
+	if setStatus(cm, status) {
+		t.Errorf("expected the same status not to change anything")
+	}
+	status.ObservedResourceVersion = "2"
+	if !setStatus(cm, status) {
+		t.Errorf("expected a new observed resourceVersion to be set")
+	}
+	if actual := GetStatus(cm).ObservedResourceVersion; actual != "2" {
+		t.Errorf("expected the observed resourceVersion 2 but got %s", actual)
+	}
+}
+
+func TestIsStatusUpdate(t *testing.T) {
+	old := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	old.ResourceVersion = "1"
+	if isStatusUpdate(old, old) {
+		t.Errorf("expected a resync not to be a status update")
+	}
+
+	cur := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	cur.ResourceVersion = "2"
+	setStatus(cur, Status{Phase: PhaseReady, ObservedResourceVersion: "1"})
+	if !isStatusUpdate(old, cur) {
+		t.Errorf("expected writing the status to be a status update")
+	}
+
+	changed := functionConfigMap("nodejs", map[string]string{SourceProperty: "v2"})
+	changed.ResourceVersion = "3"
+	changed.Annotations = cur.Annotations
+	if isStatusUpdate(cur, changed) {
+		t.Errorf("expected changing the source not to be a status update")
+	}
+
+	finalized := functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"})
+	finalized.ResourceVersion = "3"
+	finalized.Finalizers = []string{CleanupFinalizer}
+	if isStatusUpdate(old, finalized) {
+		t.Errorf("expected adding a finalizer not to be a status update")
+	}
+}
+
+func TestStatusVersions(t *testing.T) {
+	s := statusVersions{}
+	if s.wrote("Function/default/hello", "") {
+		t.Errorf("expected an empty resourceVersion never to be written")
+	}
+	s.set("Function/default/hello", "2")
+	if !s.wrote("Function/default/hello", "2") || s.wrote("Function/default/hello", "3") {
+		t.Errorf("expected only resourceVersion 2 to be written")
+	}
+	s.forget("Function/default/hello")
+	if s.wrote("Function/default/hello", "2") {
+		t.Errorf("expected the resourceVersion to be forgotten")
+	}
+}
+
+func TestDeploymentUpdateEnqueuesItsFunction(t *testing.T) {
+	functions := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	functions.Add(functionConfigMap("nodejs", map[string]string{SourceProperty: "v1"}))
+	c := &Operator{
+		logger:      log.NewNopLogger(),
+		functionInf: &testInformer{synced: true, indexer: functions},
+		flowInf:     &testInformer{synced: true, indexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})},
+		queue:       queue.New(),
+	}
+	old := functionDeployment(1, idleTestNow)
+	old.ResourceVersion = "1"
+	cur := functionDeployment(1, idleTestNow.Add(time.Minute))
+	cur.ResourceVersion = "2"
+	cur.Status.AvailableReplicas = 1
+
+	c.handleUpdateDeployment(old, old)
+	if c.queue.Len() != 0 {
+		t.Fatalf("expected a resync of the Deployment not to enqueue anything")
+	}
+	c.handleUpdateDeployment(old, cur)
+	if c.queue.Len() != 1 {
+		t.Fatalf("expected the Function to be enqueued but the queue has %d items", c.queue.Len())
+	}
+	item, _ := c.queue.Get()
+	if expected := (ResourceKey{Kind: FunctionKind, Key: "default/hello"}); item != expected {
+		t.Errorf("expected %v to be enqueued but got %v", expected, item)
+	}
+}
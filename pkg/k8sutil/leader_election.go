@@ -0,0 +1,276 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package k8sutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes"
+	"k8s.io/client-go/1.5/pkg/api/errors"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+const (
+	// LeaderAnnotation is the annotation on the lock ConfigMap which holds the LeaderElectionRecord
+	LeaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+)
+
+// LeaderElectionRecord is the record stored on the lock ConfigMap describing the current leader
+type LeaderElectionRecord struct {
+	HolderIdentity       string           `json:"holderIdentity"`
+	LeaseDurationSeconds int              `json:"leaseDurationSeconds"`
+	AcquireTime          unversioned.Time `json:"acquireTime"`
+	RenewTime            unversioned.Time `json:"renewTime"`
+}
+
+// LeaderElectionConfig configures the lease held on a ConfigMap
+type LeaderElectionConfig struct {
+	// Namespace and Name of the ConfigMap used as the lock
+	Namespace string
+	Name      string
+
+	// Identity is the unique name of this candidate; usually the pod name
+	Identity string
+
+	// LeaseDuration is how long followers wait since last observing a renewal before
+	// they try to take over the lease
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader keeps retrying to renew the lease before giving up leadership
+	RenewDeadline time.Duration
+	// RetryPeriod is how long candidates wait between attempts to acquire or renew the lease
+	RetryPeriod time.Duration
+}
+
+// LeaderCallbacks are invoked when this candidate starts or stops leading
+type LeaderCallbacks struct {
+	// OnStartedLeading is invoked in a new goroutine when the lease is acquired.
+	// The channel is closed when leadership is lost
+	OnStartedLeading func(stop <-chan struct{})
+	// OnStoppedLeading is invoked when the lease is lost or is about to be released.
+	// The lease is only released once it returns so it should wait for the work started by OnStartedLeading to stop
+	OnStoppedLeading func()
+}
+
+// LeaderElector acquires and renews a lease on a ConfigMap so that only one
+// replica of a process is active at any time
+type LeaderElector struct {
+	client kubernetes.Interface
+	config LeaderElectionConfig
+	logger log.Logger
+
+	observedRecord LeaderElectionRecord
+	observedTime   time.Time
+}
+
+// NewLeaderElector creates a new LeaderElector validating the given configuration
+func NewLeaderElector(client kubernetes.Interface, config LeaderElectionConfig, logger log.Logger) (*LeaderElector, error) {
+	if len(config.Namespace) == 0 || len(config.Name) == 0 {
+		return nil, fmt.Errorf("leader election requires the namespace and name of the lock ConfigMap")
+	}
+	if len(config.Identity) == 0 {
+		return nil, fmt.Errorf("leader election requires an identity")
+	}
+	if config.LeaseDuration <= config.RenewDeadline {
+		return nil, fmt.Errorf("leader election lease duration %v must be greater than the renew deadline %v", config.LeaseDuration, config.RenewDeadline)
+	}
+	if config.RenewDeadline <= config.RetryPeriod {
+		return nil, fmt.Errorf("leader election renew deadline %v must be greater than the retry period %v", config.RenewDeadline, config.RetryPeriod)
+	}
+	if config.RetryPeriod <= 0 {
+		return nil, fmt.Errorf("leader election retry period must be positive")
+	}
+	return &LeaderElector{
+		client: client,
+		config: config,
+		logger: logger,
+	}, nil
+}
+
+// Run blocks until the lease is acquired, invokes the callbacks and then keeps renewing the lease.
+// It returns when the lease could not be renewed within the renew deadline or when stopc is closed
+func (le *LeaderElector) Run(stopc <-chan struct{}, callbacks LeaderCallbacks) {
+	if !le.acquire(stopc) {
+		return
+	}
+	leaderStop := make(chan struct{})
+	if callbacks.OnStartedLeading != nil {
+		go callbacks.OnStartedLeading(leaderStop)
+	}
+	stopped := le.renew(stopc)
+	close(leaderStop)
+	if callbacks.OnStoppedLeading != nil {
+		callbacks.OnStoppedLeading()
+	}
+	if stopped {
+		le.release()
+	}
+}
+
+// IsLeader returns true if the last observed record is held by this candidate
+func (le *LeaderElector) IsLeader() bool {
+	return le.observedRecord.HolderIdentity == le.config.Identity
+}
+
+func (le *LeaderElector) acquire(stopc <-chan struct{}) bool {
+	le.logger.Log("msg", "attempting to acquire leader lease", "namespace", le.config.Namespace, "name", le.config.Name)
+	for {
+		if le.tryAcquireOrRenew() {
+			le.logger.Log("msg", "acquired leader lease", "identity", le.config.Identity)
+			return true
+		}
+		select {
+		case <-stopc:
+			return false
+		case <-time.After(le.config.RetryPeriod):
+		}
+	}
+}
+
+// renew keeps renewing the lease until it fails to do so within the renew deadline, returning false,
+// or until stopc is closed, returning true
+func (le *LeaderElector) renew(stopc <-chan struct{}) bool {
+	for {
+		deadline := time.Now().Add(le.config.RenewDeadline)
+		renewed := false
+		for !renewed && time.Now().Before(deadline) {
+			if renewed = le.tryAcquireOrRenew(); renewed {
+				break
+			}
+			select {
+			case <-stopc:
+				return true
+			case <-time.After(le.config.RetryPeriod):
+			}
+		}
+		if !renewed {
+			le.logger.Log("msg", "failed to renew leader lease", "identity", le.config.Identity)
+			return false
+		}
+		select {
+		case <-stopc:
+			return true
+		case <-time.After(le.config.RetryPeriod):
+		}
+	}
+}
+
+// tryAcquireOrRenew creates or updates the lock ConfigMap if the lease is free, expired
+// or already held by this candidate. The resourceVersion of the ConfigMap ensures only
+// one candidate can win a race for the lease
+func (le *LeaderElector) tryAcquireOrRenew() bool {
+	now := unversioned.Now()
+	record := LeaderElectionRecord{
+		HolderIdentity:       le.config.Identity,
+		LeaseDurationSeconds: int(le.config.LeaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+	cms := le.client.Core().ConfigMaps(le.config.Namespace)
+	cm, err := cms.Get(le.config.Name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			le.logger.Log("msg", "failed to get leader lock", "err", err)
+			return false
+		}
+		data, err := json.Marshal(&record)
+		if err != nil {
+			return false
+		}
+		_, err = cms.Create(&v1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      le.config.Name,
+				Namespace: le.config.Namespace,
+				Annotations: map[string]string{
+					LeaderAnnotation: string(data),
+				},
+			},
+		})
+		if err != nil {
+			le.logger.Log("msg", "failed to create leader lock", "err", err)
+			return false
+		}
+		le.observedRecord = record
+		le.observedTime = time.Now()
+		return true
+	}
+
+	old := LeaderElectionRecord{}
+	if cm.Annotations != nil {
+		if text := cm.Annotations[LeaderAnnotation]; len(text) > 0 {
+			if err := json.Unmarshal([]byte(text), &old); err != nil {
+				le.logger.Log("msg", "failed to parse leader lock", "err", err)
+				old = LeaderElectionRecord{}
+			}
+		}
+	}
+	if !reflect.DeepEqual(old, le.observedRecord) {
+		le.observedRecord = old
+		le.observedTime = time.Now()
+	}
+	// we use the time we observed the record rather than its renew time to be resilient against clock skew
+	if len(old.HolderIdentity) > 0 && old.HolderIdentity != le.config.Identity &&
+		le.observedTime.Add(le.config.LeaseDuration).After(time.Now()) {
+		return false
+	}
+	if old.HolderIdentity == le.config.Identity {
+		record.AcquireTime = old.AcquireTime
+	}
+	if !le.updateRecord(cm, &record) {
+		return false
+	}
+	le.observedRecord = record
+	le.observedTime = time.Now()
+	return true
+}
+
+// release gives up the lease so that another candidate can take over without waiting for it to expire
+func (le *LeaderElector) release() {
+	if !le.IsLeader() {
+		return
+	}
+	cm, err := le.client.Core().ConfigMaps(le.config.Namespace).Get(le.config.Name)
+	if err != nil {
+		return
+	}
+	record := LeaderElectionRecord{
+		LeaseDurationSeconds: 1,
+		RenewTime:            unversioned.Now(),
+	}
+	if le.updateRecord(cm, &record) {
+		le.observedRecord = record
+		le.logger.Log("msg", "released leader lease", "identity", le.config.Identity)
+	}
+}
+
+func (le *LeaderElector) updateRecord(cm *v1.ConfigMap, record *LeaderElectionRecord) bool {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[LeaderAnnotation] = string(data)
+	if _, err := le.client.Core().ConfigMaps(le.config.Namespace).Update(cm); err != nil {
+		le.logger.Log("msg", "failed to update leader lock", "err", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package k8sutil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"k8s.io/client-go/1.5/kubernetes/fake"
+	"k8s.io/client-go/1.5/pkg/api/unversioned"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+func newTestLeaderElector(t *testing.T, client *fake.Clientset, identity string) *LeaderElector {
+	le, err := NewLeaderElector(client, LeaderElectionConfig{
+		Namespace:     "default",
+		Name:          "funktion-operator",
+		Identity:      identity,
+		LeaseDuration: 300 * time.Millisecond,
+		RenewDeadline: 200 * time.Millisecond,
+		RetryPeriod:   10 * time.Millisecond,
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return le
+}
+
+func leaderRecord(t *testing.T, client *fake.Clientset) LeaderElectionRecord {
+	cm, err := client.Core().ConfigMaps("default").Get("funktion-operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := LeaderElectionRecord{}
+	if err := json.Unmarshal([]byte(cm.Annotations[LeaderAnnotation]), &record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestNewLeaderElectorValidatesDurations(t *testing.T) {
+	_, err := NewLeaderElector(fake.NewSimpleClientset(), LeaderElectionConfig{
+		Namespace:     "default",
+		Name:          "funktion-operator",
+		Identity:      "a",
+		LeaseDuration: time.Second,
+		RenewDeadline: time.Second,
+		RetryPeriod:   time.Millisecond,
+	}, log.NewNopLogger())
+	if err == nil {
+		t.Errorf("expected a renew deadline as long as the lease duration to be rejected")
+	}
+}
+
+func TestLeaderElectorAcquireAndRenew(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	le := newTestLeaderElector(t, client, "a")
+
+	if !le.tryAcquireOrRenew() {
+		t.Fatalf("expected the lease to be acquired when there is no lock")
+	}
+	if !le.IsLeader() {
+		t.Errorf("expected a to be the leader")
+	}
+	acquired := leaderRecord(t, client)
+	if acquired.HolderIdentity != "a" {
+		t.Fatalf("expected the lock to be held by a but got %s", acquired.HolderIdentity)
+	}
+
+	if !le.tryAcquireOrRenew() {
+		t.Fatalf("expected the leader to renew its lease")
+	}
+	renewed := leaderRecord(t, client)
+	if renewed.HolderIdentity != "a" || !renewed.AcquireTime.Equal(acquired.AcquireTime) {
+		t.Errorf("expected the renewal to keep the holder and acquire time but got %+v", renewed)
+	}
+}
+
+func TestLeaderElectorTakesOverExpiredLease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	a := newTestLeaderElector(t, client, "a")
+	b := newTestLeaderElector(t, client, "b")
+
+	if !a.tryAcquireOrRenew() {
+		t.Fatalf("expected a to acquire the lease")
+	}
+	if b.tryAcquireOrRenew() {
+		t.Fatalf("expected b not to acquire a lease held by a")
+	}
+	if b.IsLeader() {
+		t.Errorf("expected b not to be the leader")
+	}
+
+	// lets pretend b observed the record of a longer than the lease duration ago
+	b.observedTime = time.Now().Add(-2 * b.config.LeaseDuration)
+	if !b.tryAcquireOrRenew() {
+		t.Fatalf("expected b to take over the expired lease")
+	}
+	if holder := leaderRecord(t, client).HolderIdentity; holder != "b" {
+		t.Errorf("expected the lock to be held by b but got %s", holder)
+	}
+	if a.tryAcquireOrRenew() {
+		t.Errorf("expected a not to renew a lease taken over by b")
+	}
+}
+
+func TestLeaderElectorReleasesLeaseOnStop(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	le := newTestLeaderElector(t, client, "a")
+
+	started := make(chan struct{})
+	stoppedLeading := false
+	stopc := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		le.Run(stopc, LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				close(started)
+			},
+			OnStoppedLeading: func() {
+				// the lease must still be held while the work of the leader stops
+				if holder := leaderRecord(t, client).HolderIdentity; holder != "a" {
+					t.Errorf("expected the lease to be held until the leader stopped but got %s", holder)
+				}
+				stoppedLeading = true
+			},
+		})
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting to start leading")
+	}
+	close(stopc)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the elector to stop")
+	}
+
+	if !stoppedLeading {
+		t.Errorf("expected OnStoppedLeading to be invoked")
+	}
+	if le.IsLeader() {
+		t.Errorf("expected a to no longer be the leader")
+	}
+	if holder := leaderRecord(t, client).HolderIdentity; len(holder) > 0 {
+		t.Errorf("expected the lease to be released but it is held by %s", holder)
+	}
+
+	// another candidate can take over straight away without waiting for the lease to expire
+	b := newTestLeaderElector(t, client, "b")
+	if !b.tryAcquireOrRenew() {
+		t.Errorf("expected b to acquire the released lease")
+	}
+}
+
+func TestLeaderElectorDoesNotReleaseLeaseItDoesNotHold(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "funktion-operator",
+			Namespace: "default",
+			Annotations: map[string]string{
+				LeaderAnnotation: `{"holderIdentity":"b","leaseDurationSeconds":30}`,
+			},
+		},
+	})
+	le := newTestLeaderElector(t, client, "a")
+	le.observedRecord = LeaderElectionRecord{HolderIdentity: "b", RenewTime: unversioned.Now()}
+
+	le.release()
+
+	if holder := leaderRecord(t, client).HolderIdentity; holder != "b" {
+		t.Errorf("expected the lease of b to be kept but it is held by %s", holder)
+	}
+}
@@ -0,0 +1,263 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/1.5/pkg/api"
+	"k8s.io/client-go/1.5/pkg/api/meta"
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/runtime"
+	"k8s.io/client-go/1.5/pkg/watch"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+// ListFunc lists the resources in a namespace
+type ListFunc func(namespace string, options api.ListOptions) (runtime.Object, error)
+
+// WatchFunc watches the resources in a namespace
+type WatchFunc func(namespace string, options api.ListOptions) (watch.Interface, error)
+
+// NewNamespacedListWatch returns a ListerWatcher for the resources in the given namespaces using the
+// given selector. If no namespaces are specified then all namespaces are used
+func NewNamespacedListWatch(namespaces []string, listOpts api.ListOptions, listFunc ListFunc, watchFunc WatchFunc) cache.ListerWatcher {
+	return NewMultiListWatch(NamespacedListWatches(namespaces, listOpts, listFunc, watchFunc)...)
+}
+
+// NamespacedListWatches returns a ListerWatcher per namespace for the resources in the given namespaces
+// using the given selector. If no namespaces are specified then a single ListerWatcher for all namespaces is returned
+func NamespacedListWatches(namespaces []string, listOpts api.ListOptions, listFunc ListFunc, watchFunc WatchFunc) []cache.ListerWatcher {
+	if len(namespaces) == 0 {
+		namespaces = []string{api.NamespaceAll}
+	}
+	lws := []cache.ListerWatcher{}
+	for _, ns := range namespaces {
+		namespace := ns
+		lws = append(lws, &cache.ListWatch{
+			ListFunc: func(options api.ListOptions) (runtime.Object, error) {
+				return listFunc(namespace, listOpts)
+			},
+			WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
+				opts := listOpts
+				opts.ResourceVersion = options.ResourceVersion
+				return watchFunc(namespace, opts)
+			},
+		})
+	}
+	return lws
+}
+
+// NewMultiListWatch combines the given ListerWatchers into one so that a single informer can watch
+// several namespaces or several kinds of resource. The ListerWatchers must not be combined themselves
+func NewMultiListWatch(lws ...cache.ListerWatcher) cache.ListerWatcher {
+	if len(lws) == 1 {
+		return lws[0]
+	}
+	return &multiListerWatcher{
+		lws:              lws,
+		resourceVersions: make([]string, len(lws)),
+	}
+}
+
+// multiListerWatcher combines several ListerWatchers into one.
+// The resourceVersion of the combined list encodes the resourceVersion of each ListerWatcher
+// so that each watch can be started from the right place. The objects of the watch events only
+// carry the resourceVersion of their own ListerWatcher so the last resourceVersion delivered by
+// each watch is remembered and used when the watch is restarted from the resourceVersion of an event
+type multiListerWatcher struct {
+	lws []cache.ListerWatcher
+
+	lock             sync.Mutex
+	resourceVersions []string
+	current          *multiWatch
+}
+
+func (mlw *multiListerWatcher) List(options api.ListOptions) (runtime.Object, error) {
+	mlw.stopCurrent()
+
+	l := v1.List{}
+	resourceVersions := []string{}
+	for _, lw := range mlw.lws {
+		list, err := lw.List(options)
+		if err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			l.Items = append(l.Items, runtime.RawExtension{Object: item})
+		}
+		resourceVersions = append(resourceVersions, listMeta.GetResourceVersion())
+	}
+	mlw.lock.Lock()
+	mlw.resourceVersions = resourceVersions
+	mlw.lock.Unlock()
+	l.ListMeta.ResourceVersion = encodeResourceVersions(resourceVersions)
+	return &l, nil
+}
+
+func (mlw *multiListerWatcher) Watch(options api.ListOptions) (watch.Interface, error) {
+	// the previous watch must have delivered its last event before we know where to start from
+	mlw.stopCurrent()
+
+	resourceVersions, err := mlw.watchResourceVersions(options.ResourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	mw := &multiWatch{
+		result:  make(chan watch.Event),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i, lw := range mlw.lws {
+		opts := options
+		opts.ResourceVersion = resourceVersions[i]
+		w, err := lw.Watch(opts)
+		if err != nil {
+			mw.Stop()
+			close(mw.done)
+			return nil, err
+		}
+		mw.watchers = append(mw.watchers, w)
+	}
+	var wg sync.WaitGroup
+	for i, w := range mw.watchers {
+		wg.Add(1)
+		go func(i int, w watch.Interface) {
+			defer wg.Done()
+			// when any of the watches ends we end them all so that the reflector starts again
+			defer mw.Stop()
+			for {
+				select {
+				case event, ok := <-w.ResultChan():
+					if !ok {
+						return
+					}
+					select {
+					case mw.result <- event:
+						mlw.observe(i, event)
+					case <-mw.stopped:
+						return
+					}
+				case <-mw.stopped:
+					return
+				}
+			}
+		}(i, w)
+	}
+	go func() {
+		wg.Wait()
+		close(mw.result)
+		close(mw.done)
+	}()
+
+	mlw.lock.Lock()
+	mlw.current = mw
+	mlw.lock.Unlock()
+	return mw, nil
+}
+
+// watchResourceVersions returns the resourceVersion to start each watch from. An empty resourceVersion
+// starts every watch from the current state, the resourceVersion of the combined list starts each watch
+// from its part and the resourceVersion of an event starts each watch after the last event it delivered.
+// Any other resourceVersion fails the watch which makes the reflector list again
+func (mlw *multiListerWatcher) watchResourceVersions(resourceVersion string) ([]string, error) {
+	if len(resourceVersion) == 0 {
+		return make([]string, len(mlw.lws)), nil
+	}
+	if resourceVersions, ok := decodeResourceVersions(resourceVersion, len(mlw.lws)); ok {
+		return resourceVersions, nil
+	}
+	mlw.lock.Lock()
+	defer mlw.lock.Unlock()
+	for _, rv := range mlw.resourceVersions {
+		if rv == resourceVersion {
+			return append([]string{}, mlw.resourceVersions...), nil
+		}
+	}
+	return nil, fmt.Errorf("resource version %s was not delivered by any of the %d watches", resourceVersion, len(mlw.lws))
+}
+
+// observe remembers the resourceVersion of an event delivered by the watch with the given index
+func (mlw *multiListerWatcher) observe(i int, event watch.Event) {
+	if event.Type == watch.Error {
+		return
+	}
+	accessor, err := meta.Accessor(event.Object)
+	if err != nil {
+		return
+	}
+	mlw.lock.Lock()
+	mlw.resourceVersions[i] = accessor.GetResourceVersion()
+	mlw.lock.Unlock()
+}
+
+// stopCurrent stops the current watch and waits for it to deliver its last event
+func (mlw *multiListerWatcher) stopCurrent() {
+	mlw.lock.Lock()
+	mw := mlw.current
+	mlw.current = nil
+	mlw.lock.Unlock()
+	if mw != nil {
+		mw.Stop()
+		<-mw.done
+	}
+}
+
+// encodeResourceVersions encodes the resourceVersion of each ListerWatcher as the resourceVersion of the combined list
+func encodeResourceVersions(resourceVersions []string) string {
+	return strings.Join(resourceVersions, "/")
+}
+
+// decodeResourceVersions decodes the resourceVersion of a combined list of the given number of ListerWatchers
+func decodeResourceVersions(resourceVersion string, count int) ([]string, bool) {
+	resourceVersions := strings.Split(resourceVersion, "/")
+	if len(resourceVersions) != count {
+		return nil, false
+	}
+	return resourceVersions, true
+}
+
+// multiWatch merges the events of several watches
+type multiWatch struct {
+	result  chan watch.Event
+	stopped chan struct{}
+	// done is closed once the result channel is closed
+	done     chan struct{}
+	stopOnce sync.Once
+	watchers []watch.Interface
+}
+
+func (mw *multiWatch) ResultChan() <-chan watch.Event {
+	return mw.result
+}
+
+func (mw *multiWatch) Stop() {
+	mw.stopOnce.Do(func() {
+		close(mw.stopped)
+		for _, w := range mw.watchers {
+			w.Stop()
+		}
+	})
+}
@@ -0,0 +1,72 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/1.5/pkg/api/v1"
+	"k8s.io/client-go/1.5/pkg/watch"
+	"k8s.io/client-go/1.5/tools/cache"
+)
+
+func TestEncodeDecodeResourceVersions(t *testing.T) {
+	resourceVersions := []string{"12", "", "34"}
+	encoded := encodeResourceVersions(resourceVersions)
+	if encoded != "12//34" {
+		t.Errorf("expected 12//34 but got %s", encoded)
+	}
+	decoded, ok := decodeResourceVersions(encoded, 3)
+	if !ok || !reflect.DeepEqual(decoded, resourceVersions) {
+		t.Errorf("expected %v but got %v", resourceVersions, decoded)
+	}
+	if _, ok := decodeResourceVersions(encoded, 2); ok {
+		t.Errorf("expected %s not to decode into 2 resource versions", encoded)
+	}
+	if _, ok := decodeResourceVersions("12", 2); ok {
+		t.Errorf("expected a plain resource version not to decode")
+	}
+}
+
+func TestWatchResourceVersions(t *testing.T) {
+	mlw := NewMultiListWatch(&cache.ListWatch{}, &cache.ListWatch{}).(*multiListerWatcher)
+	mlw.resourceVersions = []string{"10", "20"}
+
+	expectWatchResourceVersions(t, mlw, "", []string{"", ""})
+	expectWatchResourceVersions(t, mlw, "5/6", []string{"5", "6"})
+
+	mlw.observe(1, watch.Event{
+		Type:   watch.Modified,
+		Object: &v1.ConfigMap{ObjectMeta: v1.ObjectMeta{ResourceVersion: "25"}},
+	})
+	// the reflector restarts the watch from the resourceVersion of the last event it received
+	expectWatchResourceVersions(t, mlw, "25", []string{"10", "25"})
+
+	if _, err := mlw.watchResourceVersions("99"); err == nil {
+		t.Errorf("expected an unknown resource version to fail the watch")
+	}
+}
+
+func expectWatchResourceVersions(t *testing.T, mlw *multiListerWatcher, resourceVersion string, expected []string) {
+	actual, err := mlw.watchResourceVersions(resourceVersion)
+	if err != nil {
+		t.Errorf("unexpected error for resource version %s: %v", resourceVersion, err)
+		return
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected resource version %s to watch from %v but got %v", resourceVersion, expected, actual)
+	}
+}
@@ -14,13 +14,34 @@
 
 package queue
 
-import "sync"
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBaseDelay is the delay before the first retry of a failed item
+	DefaultBaseDelay = 5 * time.Millisecond
+	// DefaultMaxDelay is the maximum delay between retries of a failed item
+	DefaultMaxDelay = 1000 * time.Second
+)
 
 // New constructs a new workqueue.
 func New() *Queue {
+	return NewWithBackoff(DefaultBaseDelay, DefaultMaxDelay)
+}
+
+// NewWithBackoff constructs a new workqueue which retries failed items
+// with an exponential backoff between baseDelay and maxDelay.
+func NewWithBackoff(baseDelay time.Duration, maxDelay time.Duration) *Queue {
 	return &Queue{
 		dirty:      set{},
 		processing: set{},
+		waiting:    map[t]time.Time{},
+		failures:   map[t]int{},
+		baseDelay:  baseDelay,
+		maxDelay:   maxDelay,
 		cond:       sync.NewCond(&sync.Mutex{}),
 	}
 }
@@ -41,6 +62,16 @@ type Queue struct {
 	// it's in the dirty set, and if so, add it to the queue.
 	processing set
 
+	// waiting holds the time at which items added with AddAfter will be added.
+	waiting map[t]time.Time
+
+	// failures counts how often each item has been re-added with AddRateLimited
+	// since it was last forgotten.
+	failures map[t]int
+
+	baseDelay time.Duration
+	maxDelay  time.Duration
+
 	cond *sync.Cond
 
 	shuttingDown bool
@@ -145,3 +176,69 @@ func (q *Queue) ShuttingDown() bool {
 
 	return q.shuttingDown
 }
+
+// AddAfter adds the item once the given duration has passed. If the item is
+// already waiting to be added earlier, the later add is ignored.
+func (q *Queue) AddAfter(item interface{}, duration time.Duration) {
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	readyAt := time.Now().Add(duration)
+	if existing, ok := q.waiting[item]; ok && !existing.After(readyAt) {
+		return
+	}
+	q.waiting[item] = readyAt
+	time.AfterFunc(duration, func() {
+		q.cond.L.Lock()
+		at, ok := q.waiting[item]
+		if !ok || !at.Equal(readyAt) {
+			// superseded by an earlier add
+			q.cond.L.Unlock()
+			return
+		}
+		delete(q.waiting, item)
+		q.cond.L.Unlock()
+		q.Add(item)
+	})
+}
+
+// AddRateLimited adds the item after a delay which grows exponentially with the
+// number of times the item has been re-added since it was last forgotten.
+func (q *Queue) AddRateLimited(item interface{}) {
+	q.AddAfter(item, q.nextDelay(item))
+}
+
+func (q *Queue) nextDelay(item interface{}) time.Duration {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	exp := q.failures[item]
+	q.failures[item] = exp + 1
+
+	backoff := float64(q.baseDelay) * math.Pow(2, float64(exp))
+	if backoff > float64(q.maxDelay) {
+		return q.maxDelay
+	}
+	return time.Duration(backoff)
+}
+
+// NumRequeues returns how many times the item has been re-added with
+// AddRateLimited since it was last forgotten.
+func (q *Queue) NumRequeues(item interface{}) int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.failures[item]
+}
+
+// Forget resets the backoff of the item. Call it once an item has been
+// processed successfully. It does not remove the item from the queue.
+func (q *Queue) Forget(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.failures, item)
+}
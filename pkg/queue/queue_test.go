@@ -0,0 +1,221 @@
+//  Copyright 2016 Red Hat, Inc.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddDeduplicates(t *testing.T) {
+	q := New()
+	q.Add("foo")
+	q.Add("foo")
+	q.Add("bar")
+	if l := q.Len(); l != 2 {
+		t.Errorf("expected 2 items in the queue but got %d", l)
+	}
+}
+
+func TestAddWhileProcessing(t *testing.T) {
+	q := New()
+	q.Add("foo")
+	item, _ := q.Get()
+	q.Add("foo")
+	if l := q.Len(); l != 0 {
+		t.Errorf("expected item being processed not to be queued again but queue length is %d", l)
+	}
+	q.Done(item)
+	if l := q.Len(); l != 1 {
+		t.Errorf("expected item to be queued again once done but queue length is %d", l)
+	}
+}
+
+func TestAddAfter(t *testing.T) {
+	q := New()
+	q.AddAfter("foo", 50*time.Millisecond)
+	if l := q.Len(); l != 0 {
+		t.Errorf("expected the item not to be added yet but queue length is %d", l)
+	}
+	waitForLen(t, q, 1, time.Second)
+}
+
+func TestAddAfterKeepsEarliest(t *testing.T) {
+	q := New()
+	q.AddAfter("foo", time.Hour)
+	q.AddAfter("foo", 20*time.Millisecond)
+	waitForLen(t, q, 1, time.Second)
+}
+
+func TestAddRateLimitedBacksOff(t *testing.T) {
+	q := NewWithBackoff(time.Millisecond, 8*time.Millisecond)
+	expected := []time.Duration{1, 2, 4, 8, 8}
+	for i, e := range expected {
+		if d := q.nextDelay("foo"); d != e*time.Millisecond {
+			t.Errorf("retry %d expected delay %v but got %v", i, e*time.Millisecond, d)
+		}
+	}
+	if n := q.NumRequeues("foo"); n != len(expected) {
+		t.Errorf("expected %d requeues but got %d", len(expected), n)
+	}
+	if n := q.NumRequeues("bar"); n != 0 {
+		t.Errorf("expected other items to have no requeues but got %d", n)
+	}
+
+	q.Forget("foo")
+	if n := q.NumRequeues("foo"); n != 0 {
+		t.Errorf("expected no requeues after Forget but got %d", n)
+	}
+	if d := q.nextDelay("foo"); d != time.Millisecond {
+		t.Errorf("expected the base delay after Forget but got %v", d)
+	}
+}
+
+func TestAddRateLimited(t *testing.T) {
+	q := NewWithBackoff(10*time.Millisecond, time.Second)
+	q.AddRateLimited("foo")
+	waitForLen(t, q, 1, time.Second)
+}
+
+func TestShutDownIgnoresDelayedAdds(t *testing.T) {
+	q := New()
+	q.AddAfter("foo", 10*time.Millisecond)
+	q.ShutDown()
+	time.Sleep(50 * time.Millisecond)
+	if l := q.Len(); l != 0 {
+		t.Errorf("expected no items after shut down but queue length is %d", l)
+	}
+}
+
+func TestConcurrentWorkersNeverShareAnItem(t *testing.T) {
+	q := New()
+	const (
+		workers = 8
+		keys    = 4
+		adds    = 2000
+	)
+
+	var lock sync.Mutex
+	inFlight := map[interface{}]bool{}
+	processed := 0
+	var failed error
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, quit := q.Get()
+				if quit {
+					return
+				}
+				lock.Lock()
+				if inFlight[item] && failed == nil {
+					failed = fmt.Errorf("item %v is being processed by two workers", item)
+				}
+				inFlight[item] = true
+				processed++
+				lock.Unlock()
+
+				time.Sleep(100 * time.Microsecond)
+
+				lock.Lock()
+				delete(inFlight, item)
+				lock.Unlock()
+				q.Done(item)
+			}
+		}()
+	}
+
+	for i := 0; i < adds; i++ {
+		q.Add(fmt.Sprintf("key-%d", i%keys))
+	}
+	waitForLen(t, q, 0, 5*time.Second)
+	q.ShutDown()
+	wg.Wait()
+
+	if failed != nil {
+		t.Error(failed)
+	}
+	if processed < keys {
+		t.Errorf("expected every key to be processed at least once but only %d items were processed", processed)
+	}
+}
+
+func TestConcurrentWorkersProcessDistinctItemsInParallel(t *testing.T) {
+	q := New()
+	const workers = 4
+	for i := 0; i < workers; i++ {
+		q.Add(i)
+	}
+
+	// each worker blocks until all of them hold an item which only completes if they run in parallel
+	var started sync.WaitGroup
+	started.Add(workers)
+	done := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			item, _ := q.Get()
+			started.Done()
+			started.Wait()
+			q.Done(item)
+		}()
+	}
+	go func() {
+		started.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected %d workers to process distinct items at the same time", workers)
+	}
+}
+
+func TestAddDuringProcessingIsRequeuedOnce(t *testing.T) {
+	q := New()
+	q.Add("foo")
+	item, _ := q.Get()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Add("foo")
+		}()
+	}
+	wg.Wait()
+
+	q.Done(item)
+	if l := q.Len(); l != 1 {
+		t.Errorf("expected the item to be queued once after concurrent adds but queue length is %d", l)
+	}
+}
+
+func waitForLen(t *testing.T, q *Queue, expected int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if q.Len() == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("expected queue length %d within %v but got %d", expected, timeout, q.Len())
+}
@@ -20,6 +20,15 @@ import (
 	"k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
 )
 
+const (
+	// Group is the API group of the funktion custom resources
+	Group = "funktion.fabric8.io"
+	// Version is the API version of the funktion custom resources
+	Version = "v1alpha1"
+	// APIVersion is the apiVersion of the funktion custom resources
+	APIVersion = Group + "/" + Version
+)
+
 const (
 	EndpointKind   = "endpoint"
 	FunctionKind   = "function"
@@ -27,6 +36,152 @@ const (
 	SetHeadersKind = "setHeaders"
 )
 
+// Function is a function which is deployed using a Runtime
+type Function struct {
+	unversioned.TypeMeta `json:",inline"`
+	v1.ObjectMeta        `json:"metadata,omitempty"`
+	Spec                 FunctionSpec   `json:"spec"`
+	Status               ResourceStatus `json:"status,omitempty"`
+}
+
+// FunctionList is a list of Functions.
+type FunctionList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+
+	Items []*Function `json:"items"`
+}
+
+// FunctionSpec holds the source code of a Function along with the Runtime used to run it.
+type FunctionSpec struct {
+	// Runtime is the name of the Runtime in the same namespace used to run the function
+	Runtime string `json:"runtime"`
+	// Source is the source code of the function
+	Source string `json:"source"`
+	// Files are the other files of the function such as helper modules or a package.json
+	// keyed by their path relative to the folder the source is mounted in
+	Files map[string]string `json:"files,omitempty"`
+	// Debug enables the debug deployment of the Runtime
+	Debug bool `json:"debug,omitempty"`
+	// Env are the environment variables passed to the function. Values may come from
+	// the keys of Secrets or ConfigMaps but other value sources are not supported
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Autoscaling scales the function with its load when specified
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+	// IdleTimeout is the duration such as `15m` without requests after which the function is scaled to zero.
+	// Defaults to the IdleTimeout of the Runtime
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// Traffic splits the requests between the revisions of the function.
+	// The latest revision receives all the requests when not specified
+	Traffic []TrafficTarget `json:"traffic,omitempty"`
+	// Replicas is the number of pods running the function when it is not autoscaled.
+	// Defaults to the replicas of the Runtime
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Resources are the CPU and memory requests and limits of the function container which
+	// override those of the Runtime. Other resources are ignored
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+	// NodeSelector are the labels of the nodes the function must run on
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations allow the function to run on nodes with matching taints
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Affinity constrains the nodes the function runs on based on the labels of nodes and pods
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// ImagePullSecrets are the Secrets used to pull the image of the Runtime
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// TrafficTarget is the percentage of the requests of a Function sent to one of its revisions
+type TrafficTarget struct {
+	Revision int `json:"revision"`
+	Percent  int `json:"percent"`
+}
+
+// AutoscalingSpec holds the settings of the HorizontalPodAutoscaler of a Function
+type AutoscalingSpec struct {
+	// MinReplicas is the minimum number of replicas. Defaults to 1
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the maximum number of replicas
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUPercentage is the average CPU utilization of the pods to scale at
+	TargetCPUPercentage int32 `json:"targetCPUPercentage,omitempty"`
+	// CustomMetric is the custom metric to scale on
+	CustomMetric *CustomMetricSpec `json:"customMetric,omitempty"`
+}
+
+// CustomMetricSpec holds a custom metric and the average value per pod to scale at
+type CustomMetricSpec struct {
+	Name        string `json:"name"`
+	TargetValue string `json:"targetValue"`
+}
+
+// Flow binds a Connector to a sequence of steps such as invoking a Function
+type Flow struct {
+	unversioned.TypeMeta `json:",inline"`
+	v1.ObjectMeta        `json:"metadata,omitempty"`
+	Spec                 FlowSpec       `json:"spec"`
+	Status               ResourceStatus `json:"status,omitempty"`
+}
+
+// FlowList is a list of Flows.
+type FlowList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+
+	Items []*Flow `json:"items"`
+}
+
+// FlowSpec holds the steps of a Flow and the configuration of its Connector.
+type FlowSpec struct {
+	// Connector is the name of the Connector in the same namespace used to run the flow
+	Connector string `json:"connector"`
+	// Flows are the routes written to the funktion.yml file
+	Flows []FunktionFlow `json:"flows,omitempty"`
+	// ApplicationProperties is the spring boot application.properties file
+	ApplicationProperties string `json:"applicationProperties,omitempty"`
+	// ApplicationYml is the spring boot application.yml file
+	ApplicationYml string `json:"applicationYml,omitempty"`
+}
+
+// Runtime defines how to create a Deployment and Service for a Function
+type Runtime struct {
+	unversioned.TypeMeta `json:",inline"`
+	v1.ObjectMeta        `json:"metadata,omitempty"`
+	Spec                 RuntimeSpec `json:"spec"`
+}
+
+// RuntimeList is a list of Runtimes.
+type RuntimeList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata,omitempty"`
+
+	Items []*Runtime `json:"items"`
+}
+
+// RuntimeSpec holds the templates used to create the resources of a Function.
+type RuntimeSpec struct {
+	// Deployment is the template of the Deployment of each Function
+	Deployment *v1beta1.Deployment `json:"deployment"`
+	// DebugDeployment is the template of the Deployment of each Function with debugging enabled
+	DebugDeployment *v1beta1.Deployment `json:"debugDeployment,omitempty"`
+	// Service is the template of the Service of each Function
+	Service *v1.Service `json:"service"`
+	// DebugPort is the port used to debug a Function
+	DebugPort int `json:"debugPort,omitempty"`
+	// FileExtensions are the extensions (without the dot) of the source files handled by this runtime
+	FileExtensions []string `json:"fileExtensions,omitempty"`
+	// SourceMountPath is the path in the container where the source code is mounted
+	SourceMountPath string `json:"sourceMountPath,omitempty"`
+	// IdleTimeout is the default IdleTimeout of the Functions using this runtime
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// DependencyFiles are the files of a Function such as `package.json` which declare its dependencies
+	DependencyFiles []string `json:"dependencyFiles,omitempty"`
+	// DependencyInstallCommand is the shell command run by an init container to install the dependencies
+	// of a Function which has one of the DependencyFiles
+	DependencyInstallCommand string `json:"dependencyInstallCommand,omitempty"`
+	// DependencyInstallImage is the image used to install the dependencies. Defaults to the image of the Deployment
+	DependencyInstallImage string `json:"dependencyInstallImage,omitempty"`
+}
+
 // Connector defines how to create a Deployment for a Flow
 type Connector struct {
 	unversioned.TypeMeta `json:",inline"`
@@ -34,7 +189,7 @@ type Connector struct {
 	Spec                 ConnectorSpec `json:"spec"`
 }
 
-// ConnectorList is a list of Funktion.
+// ConnectorList is a list of Connectors.
 type ConnectorList struct {
 	unversioned.TypeMeta `json:",inline"`
 	unversioned.ListMeta `json:"metadata,omitempty"`
@@ -42,11 +197,25 @@ type ConnectorList struct {
 	Items []*Connector `json:"items"`
 }
 
-// ConnectorSpec holds specification parameters of a Flow deployment along with configuration metadata.
+// ConnectorSpec holds the templates used to create the resources of a Flow along with configuration metadata.
 type ConnectorSpec struct {
-	DeploymentSpec *v1beta1.DeploymentSpec `json:"deploymentSpec"`
+	// Deployment is the template of the Deployment of each Flow
+	Deployment *v1beta1.Deployment `json:"deployment"`
+	// Service is the optional template of the Service of each Flow for connectors which accept inbound HTTP
+	Service *v1.Service `json:"service,omitempty"`
+	// Schema describes the properties used to configure the connector
+	Schema *ConnectorSchema `json:"schema,omitempty"`
+	// ApplicationProperties is the default spring boot application.properties file of new Flows
+	ApplicationProperties string `json:"applicationProperties,omitempty"`
+}
 
-	// TODO lets add a JSON Schema for how to configure the endpoints?
+// ResourceStatus is the reconcile status the operator writes onto a Function or Flow
+type ResourceStatus struct {
+	Phase                   string `json:"phase,omitempty"`
+	Message                 string `json:"message,omitempty"`
+	ObservedResourceVersion string `json:"observedResourceVersion,omitempty"`
+	Deployment              string `json:"deployment,omitempty"`
+	Service                 string `json:"service,omitempty"`
 }
 
 // ComponentSpec holds the component metadata in a ConnectorSchema